@@ -5,13 +5,17 @@ go 1.24.4
 toolchain go1.24.11
 
 require (
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/go-telegram/bot v1.15.0
 	github.com/ipfans/fxlogger v0.2.0
 	github.com/jackc/pgx/v5 v5.7.2
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/pkoukk/tiktoken-go v0.1.6
+	github.com/pkoukk/tiktoken-go-loader v0.0.2
 	github.com/rs/zerolog v1.34.0
 	github.com/tmc/langchaingo v0.1.14
 	go.uber.org/fx v1.23.0
+	mvdan.cc/sh/v3 v3.12.0
 )
 
 require (
@@ -23,7 +27,6 @@ require (
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/pkoukk/tiktoken-go v0.1.6 // indirect
 	go.uber.org/dig v1.18.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
@@ -1,18 +1,31 @@
 package agent
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // ActionType represents the type of action to execute.
 type ActionType string
 
 const (
-	ActionTypeBash ActionType = "bash"
+	ActionTypeBash      ActionType = "bash"
+	ActionTypeReadFile  ActionType = "read_file"
+	ActionTypeWriteFile ActionType = "write_file"
+	ActionTypeHTTPFetch ActionType = "http_fetch"
+	ActionTypeSQLQuery  ActionType = "sql_query"
 )
 
 // Action represents a parsed command to execute.
 type Action struct {
 	Type    ActionType
-	Command string
+	Command string // Bash command, or a readable summary for other tools
+
+	// Args holds the raw JSON arguments of a tool call.
+	Args json.RawMessage
+
+	// ToolCallID is set when the action came from a native tool call.
+	ToolCallID string
 }
 
 // String returns a string representation of the action for debugging.
@@ -26,6 +39,10 @@ type Output struct {
 	Stderr   string
 	ExitCode int
 	TimedOut bool
+
+	// Edited holds the replacement command when a human edited the action
+	// before approving it.
+	Edited string
 }
 
 // String formats the output for display to the LLM.
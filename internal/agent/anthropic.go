@@ -0,0 +1,383 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// AnthropicBaseURL is the default endpoint of the Anthropic API.
+	AnthropicBaseURL = "https://api.anthropic.com/v1"
+
+	// anthropicVersion is the API version sent with every request.
+	anthropicVersion = "2023-06-01"
+
+	// anthropicMaxTokens caps each response, which the Messages API requires.
+	anthropicMaxTokens = 4096
+)
+
+// AnthropicQuerier implements Querier using Anthropic's Messages API. The
+// system prompt and the conversation so far are marked for prompt caching,
+// so the long agent prompt and context are only paid for in full once every
+// few minutes, and each step of an agentic run reads the previous ones from
+// the cache.
+type AnthropicQuerier struct {
+	client  *http.Client
+	apiKey  string
+	baseURL string
+	model   string
+}
+
+// NewAnthropicQuerier creates a querier for the Anthropic API at baseURL.
+func NewAnthropicQuerier(apiKey, baseURL, model string) (*AnthropicQuerier, error) {
+	if apiKey == "" {
+		return nil, errors.New("anthropic API key is required")
+	}
+	if baseURL == "" {
+		baseURL = AnthropicBaseURL
+	}
+
+	return &AnthropicQuerier{
+		client:  http.DefaultClient,
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+	}, nil
+}
+
+// Query sends messages to the model and returns the response with token usage.
+func (q *AnthropicQuerier) Query(ctx context.Context, messages []Message, opts ...QueryOption) (QueryResult, error) {
+	return q.generate(ctx, messages, nil, opts)
+}
+
+// QueryStream sends messages to the model and streams the response chunks to onChunk.
+func (q *AnthropicQuerier) QueryStream(ctx context.Context, messages []Message, onChunk func(chunk string) error, opts ...QueryOption) (QueryResult, error) {
+	return q.generate(ctx, messages, onChunk, opts)
+}
+
+// anthropicRequest is the body of a Messages API request.
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	System      []anthropicBlock   `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a content block of any type; unused fields are omitted.
+type anthropicBlock struct {
+	Type         string                 `json:"type"`
+	Text         string                 `json:"text,omitempty"`
+	ID           string                 `json:"id,omitempty"`
+	Name         string                 `json:"name,omitempty"`
+	Input        json.RawMessage        `json:"input,omitempty"`
+	ToolUseID    string                 `json:"tool_use_id,omitempty"`
+	Content      string                 `json:"content,omitempty"`
+	Source       *anthropicImageSource  `json:"source,omitempty"`
+	CacheControl *anthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+// anthropicImageSource is the data of an image block.
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicCacheControl struct {
+	Type string `json:"type"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+type anthropicResponse struct {
+	Content []anthropicBlock `json:"content"`
+	Usage   anthropicUsage   `json:"usage"`
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// generate performs the request shared by Query and QueryStream. A nil
+// onChunk makes a plain request.
+func (q *AnthropicQuerier) generate(ctx context.Context, messages []Message, onChunk func(chunk string) error, opts []QueryOption) (QueryResult, error) {
+	var options QueryOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	model := q.model
+	if options.Model != "" {
+		model = options.Model
+	}
+
+	request := anthropicRequest{
+		Model:       model,
+		MaxTokens:   anthropicMaxTokens,
+		Temperature: options.Temperature,
+		Stream:      onChunk != nil,
+	}
+	request.System, request.Messages = anthropicMessages(messages)
+	for _, tool := range options.Tools {
+		request.Tools = append(request.Tools, anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.Parameters,
+		})
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", q.apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return QueryResult{}, anthropicStatusError(resp)
+	}
+
+	var response anthropicResponse
+	if onChunk != nil {
+		response, err = readAnthropicStream(resp.Body, onChunk)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&response)
+	}
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	result := QueryResult{Model: model}
+	var text strings.Builder
+	for _, block := range response.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			result.ToolCalls = append(result.ToolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: arguments})
+		}
+	}
+	result.Content = text.String()
+
+	// input_tokens only counts what came after the last cache breakpoint
+	usage := response.Usage
+	result.CacheWriteTokens = usage.CacheCreationInputTokens
+	result.CacheReadTokens = usage.CacheReadInputTokens
+	result.InputTokens = usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	result.OutputTokens = usage.OutputTokens
+	result.TotalTokens = result.InputTokens + result.OutputTokens
+
+	return result, nil
+}
+
+// anthropicMessages converts messages to the Messages API's system blocks and
+// alternating turns. Tool results travel in user turns and consecutive turns
+// of the same role are merged. The system prompt and the last turn carry
+// cache breakpoints, so each request caches everything before it.
+func anthropicMessages(messages []Message) ([]anthropicBlock, []anthropicMessage) {
+	var system []anthropicBlock
+	var turns []anthropicMessage
+
+	add := func(role string, blocks ...anthropicBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(turns); n > 0 && turns[n-1].Role == role {
+			turns[n-1].Content = append(turns[n-1].Content, blocks...)
+			return
+		}
+		turns = append(turns, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			if msg.Content != "" {
+				system = append(system, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+		case RoleUser:
+			// Images go before the text about them
+			var blocks []anthropicBlock
+			for _, image := range msg.Images {
+				if image.Loaded() {
+					blocks = append(blocks, anthropicBlock{Type: "image", Source: &anthropicImageSource{
+						Type:      "base64",
+						MediaType: image.MIMEType,
+						Data:      base64.StdEncoding.EncodeToString(image.Data),
+					}})
+				}
+			}
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			add("user", blocks...)
+		case RoleAssistant:
+			var blocks []anthropicBlock
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+			}
+			add("assistant", blocks...)
+		case RoleTool:
+			add("user", anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		}
+	}
+
+	ephemeral := &anthropicCacheControl{Type: "ephemeral"}
+	if n := len(system); n > 0 {
+		system[n-1].CacheControl = ephemeral
+	}
+	if n := len(turns); n > 0 {
+		content := turns[n-1].Content
+		content[len(content)-1].CacheControl = ephemeral
+	}
+	return system, turns
+}
+
+// anthropicStatusError turns an error response into a QueryError.
+func anthropicStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	message := strings.TrimSpace(string(body))
+	var apiErr anthropicError
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error.Message != "" {
+		message = apiErr.Error.Type + ": " + apiErr.Error.Message
+	}
+	return &QueryError{StatusCode: resp.StatusCode, Err: fmt.Errorf("anthropic: %s", message)}
+}
+
+// anthropicEvent is a server-sent event of a streamed response.
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readAnthropicStream assembles a streamed response, passing text deltas to
+// onChunk as they arrive.
+func readAnthropicStream(body io.Reader, onChunk func(chunk string) error) (anthropicResponse, error) {
+	var response anthropicResponse
+	var inputs []strings.Builder // Tool input JSON, per content block
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return response, fmt.Errorf("failed to decode event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			response.Usage = event.Message.Usage
+		case "content_block_start":
+			for len(response.Content) <= event.Index {
+				response.Content = append(response.Content, anthropicBlock{})
+				inputs = append(inputs, strings.Builder{})
+			}
+			response.Content[event.Index] = event.ContentBlock
+			response.Content[event.Index].Input = nil
+		case "content_block_delta":
+			if event.Index >= len(response.Content) {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				response.Content[event.Index].Text += event.Delta.Text
+				if err := onChunk(event.Delta.Text); err != nil {
+					return response, err
+				}
+			case "input_json_delta":
+				inputs[event.Index].WriteString(event.Delta.PartialJSON)
+			}
+		case "message_delta":
+			if event.Usage != nil {
+				response.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return response, fmt.Errorf("anthropic: %s: %s", event.Error.Type, event.Error.Message)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return response, err
+	}
+
+	for i := range response.Content {
+		if response.Content[i].Type == "tool_use" && inputs[i].Len() > 0 {
+			response.Content[i].Input = json.RawMessage(inputs[i].String())
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ApprovalDecision is a human's answer to an approval request.
+type ApprovalDecision string
+
+const (
+	ApprovalApproved ApprovalDecision = "approved"
+	ApprovalDenied   ApprovalDecision = "denied"
+	ApprovalEdited   ApprovalDecision = "edited"
+)
+
+// Approval is the outcome of an approval request.
+type Approval struct {
+	Decision ApprovalDecision
+	Command  string // Replacement command (or JSON arguments) when edited
+}
+
+// Approver asks a human whether an action may run. RequestApproval blocks
+// until the request is answered or ctx is done.
+type Approver interface {
+	RequestApproval(ctx context.Context, action Action) (Approval, error)
+}
+
+// ApprovalPolicy decides which actions need human approval.
+type ApprovalPolicy interface {
+	RequiresApproval(action Action) bool
+}
+
+// PatternApprovalPolicy requires approval for commands matching any pattern,
+// or for every action in strict mode.
+type PatternApprovalPolicy struct {
+	strict   bool
+	patterns []*regexp.Regexp
+}
+
+// NewPatternApprovalPolicy creates a policy from regex patterns.
+func NewPatternApprovalPolicy(patterns []string, strict bool) (*PatternApprovalPolicy, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid approval pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return &PatternApprovalPolicy{strict: strict, patterns: compiled}, nil
+}
+
+// RequiresApproval reports whether the action must be approved before it runs.
+func (p *PatternApprovalPolicy) RequiresApproval(action Action) bool {
+	if p.strict {
+		return true
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(action.Command) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovingExecutor pauses actions that need approval until a human answers.
+// Denied or unanswered actions are reported back to the model as ProcessErr.
+type ApprovingExecutor struct {
+	executor Executor
+	policy   ApprovalPolicy
+	approver Approver
+	timeout  time.Duration
+}
+
+// NewApprovingExecutor wraps executor with an approval step.
+func NewApprovingExecutor(executor Executor, policy ApprovalPolicy, approver Approver, timeout time.Duration) *ApprovingExecutor {
+	return &ApprovingExecutor{
+		executor: executor,
+		policy:   policy,
+		approver: approver,
+		timeout:  timeout,
+	}
+}
+
+// Execute asks for approval when the policy requires it, then runs the action.
+func (e *ApprovingExecutor) Execute(ctx context.Context, action Action) (Output, error) {
+	if !e.policy.RequiresApproval(action) {
+		return e.executor.Execute(ctx, action)
+	}
+
+	approvalCtx := ctx
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		approvalCtx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	approval, err := e.approver.RequestApproval(approvalCtx, action)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Output{}, fmt.Errorf("approval cancelled: %w", ctx.Err())
+		}
+		if errors.Is(approvalCtx.Err(), context.DeadlineExceeded) {
+			return Output{}, &ProcessErr{
+				Type:    ProcessErrDenied,
+				Message: fmt.Sprintf("Command was not approved within %s and did not run. Try a different approach or ask the user to confirm.", e.timeout),
+			}
+		}
+		return Output{}, fmt.Errorf("approval request failed: %w", err)
+	}
+
+	switch approval.Decision {
+	case ApprovalApproved:
+		return e.executor.Execute(ctx, action)
+	case ApprovalEdited:
+		edited, err := editAction(action, approval.Command)
+		if err != nil {
+			return Output{}, err
+		}
+		output, err := e.executor.Execute(ctx, edited)
+		output.Edited = edited.Command
+		return output, err
+	default:
+		return Output{}, &ProcessErr{
+			Type:    ProcessErrDenied,
+			Message: "The user denied this command and it did not run. Do not retry it; choose a different approach or explain what you need.",
+		}
+	}
+}
+
+// editAction applies a human's edit. Bash commands are replaced verbatim,
+// other tools expect replacement JSON arguments.
+func editAction(action Action, replacement string) (Action, error) {
+	replacement = strings.TrimSpace(replacement)
+	if replacement == "" {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrDenied,
+			Message: "The user replaced this command with an empty one, so nothing ran. Choose a different approach.",
+		}
+	}
+
+	edited := action
+	if action.Type == ActionTypeBash {
+		edited.Command = replacement
+		return edited, nil
+	}
+
+	if !json.Valid([]byte(replacement)) {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrDenied,
+			Message: "The user's edited arguments were not valid JSON, so nothing ran. Choose a different approach.",
+		}
+	}
+	edited.Args = json.RawMessage(replacement)
+	edited.Command = fmt.Sprintf("%s %s", action.Type, replacement)
+	return edited, nil
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/j0lvera/banray/internal/config"
+)
+
+// Allowance is what a user may still spend before hitting a budget.
+type Allowance struct {
+	Tokens    int     // Tokens left (math.MaxInt when unlimited)
+	CostUSD   float64 // Dollars left (+Inf when unlimited)
+	Exhausted string  // The limit that ran out, e.g. "daily token"; empty while there's room
+}
+
+// Unlimited reports whether no budget applies.
+func (a Allowance) Unlimited() bool {
+	return a.Tokens == math.MaxInt && math.IsInf(a.CostUSD, 1)
+}
+
+// RunBudget caps what a single agentic run may spend. Zero fields are
+// unlimited.
+type RunBudget struct {
+	Tokens  int     // Tokens the run may use
+	CostUSD float64 // Dollars the run may spend
+}
+
+// RunBudget returns the run budget left by the allowance.
+func (a Allowance) RunBudget() RunBudget {
+	var budget RunBudget
+	if a.Tokens != math.MaxInt {
+		budget.Tokens = a.Tokens
+	}
+	if !math.IsInf(a.CostUSD, 1) {
+		budget.CostUSD = a.CostUSD
+	}
+	return budget
+}
+
+// Budgets enforces per-role daily and monthly limits on tokens and dollars.
+// Days and months start at midnight UTC.
+type Budgets struct {
+	store  *Store
+	limits map[UserRole]config.Budget
+	now    func() time.Time
+}
+
+// NewBudgets creates budgets from per-role limits. Roles without limits are
+// unlimited.
+func NewBudgets(store *Store, limits map[string]config.Budget) (*Budgets, error) {
+	byRole := make(map[UserRole]config.Budget, len(limits))
+	for name, limit := range limits {
+		role, err := ParseUserRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget: %w", err)
+		}
+		byRole[role] = limit
+	}
+
+	return &Budgets{
+		store:  store,
+		limits: byRole,
+		now:    time.Now,
+	}, nil
+}
+
+// Remaining returns what a user with role may still spend today and this
+// month, whichever is tighter.
+func (b *Budgets) Remaining(ctx context.Context, userID int64, role UserRole) (Allowance, error) {
+	allowance := Allowance{Tokens: math.MaxInt, CostUSD: math.Inf(1)}
+	limit, ok := b.limits[role]
+	if !ok || limit == (config.Budget{}) {
+		return allowance, nil
+	}
+
+	now := b.now().UTC()
+	periods := []struct {
+		name   string
+		since  time.Time
+		tokens int
+		usd    float64
+	}{
+		{"daily", time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), limit.DailyTokens, limit.DailyUSD},
+		{"monthly", time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), limit.MonthlyTokens, limit.MonthlyUSD},
+	}
+
+	for _, period := range periods {
+		if period.tokens == 0 && period.usd == 0 {
+			continue
+		}
+
+		tokens, cost, err := b.spent(ctx, userID, period.since)
+		if err != nil {
+			return Allowance{}, err
+		}
+
+		if period.tokens > 0 {
+			if left := period.tokens - tokens; left < allowance.Tokens {
+				allowance.Tokens = left
+				if left <= 0 && allowance.Exhausted == "" {
+					allowance.Exhausted = period.name + " token"
+				}
+			}
+		}
+		if period.usd > 0 {
+			if left := period.usd - cost; left < allowance.CostUSD {
+				allowance.CostUSD = left
+				if left <= 0 && allowance.Exhausted == "" {
+					allowance.Exhausted = period.name + " spending"
+				}
+			}
+		}
+	}
+
+	return allowance, nil
+}
+
+// spent returns the tokens a user has used and what they cost since a
+// point in time.
+func (b *Budgets) spent(ctx context.Context, userID int64, since time.Time) (int, float64, error) {
+	usage, err := b.store.GetUserUsageSince(ctx, userID, since)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return int(usage.TotalTokens), usage.TotalCostUsd, nil
+}
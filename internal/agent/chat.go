@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/j0lvera/banray/internal/db"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ChatStore manages the Telegram chats the bot talks in using PostgreSQL
+type ChatStore struct {
+	client *db.Client
+}
+
+// NewChatStore creates a new chat store
+func NewChatStore(client *db.Client) *ChatStore {
+	return &ChatStore{client: client}
+}
+
+// UpsertChat creates or updates a chat by its Telegram ID
+func (s *ChatStore) UpsertChat(ctx context.Context, telegramID int64, chatType, title string) (*dbgen.DataChat, error) {
+	return s.client.Queries.UpsertChat(ctx, dbgen.UpsertChatParams{
+		TelegramID: telegramID,
+		Type:       chatType,
+		Title:      pgtype.Text{String: title, Valid: title != ""},
+	})
+}
+
+// SetChatProfile stores the profile picked for a chat; an empty profile restores the default
+func (s *ChatStore) SetChatProfile(ctx context.Context, chatID int64, profile string) (*dbgen.DataChat, error) {
+	return s.client.Queries.SetChatProfile(ctx, dbgen.SetChatProfileParams{
+		ID:      chatID,
+		Profile: pgtype.Text{String: profile, Valid: profile != ""},
+	})
+}
+
+// SpeakerContent prefixes a message with the name of who sent it, so the
+// model can tell the speakers of a group chat apart.
+func SpeakerContent(speaker, content string) string {
+	return speaker + ": " + content
+}
@@ -0,0 +1,245 @@
+package agent
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/j0lvera/banray/internal/config"
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+const (
+	// DefaultContextWindow is the context window assumed for models without
+	// a configured one, in tokens.
+	DefaultContextWindow = 128000
+
+	// responseReserve is kept free in the window for the model's response.
+	responseReserve = 4096
+
+	// messageOverhead is what each message costs beyond its content: role
+	// and delimiters.
+	messageOverhead = 4
+
+	// imageTokens is what an image is assumed to cost. Providers count
+	// images by their size, and Telegram photos are at most 1280 pixels
+	// across, which comes to about this many.
+	imageTokens = 1600
+
+	// compressMinTokens is the size from which an old message is worth
+	// compressing, and compressKeepTokens how much of it is kept.
+	compressMinTokens  = 512
+	compressKeepTokens = 256
+
+	compressedMarker = "\n[... trimmed to fit the context window]"
+)
+
+// encoding is the tokenizer shared by every ContextManager. cl100k_base is
+// exact for OpenAI models and close enough for others, which the response
+// reserve absorbs. Its ranks are embedded, so counting never hits the
+// network; nil means they failed to load and counts are estimated.
+var encoding = sync.OnceValue(func() *tiktoken.Tiktoken {
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+	enc, err := tiktoken.GetEncoding("cl100k_base")
+	if err != nil {
+		return nil
+	}
+	return enc
+})
+
+// ContextManager budgets prompts in tokens. It knows each model's context
+// window and fits conversations into it, compressing and then dropping the
+// oldest messages first.
+type ContextManager struct {
+	windows       map[string]int // Context window per model, in tokens
+	defaultWindow int            // Context window of models missing from windows
+	historyTokens int            // Cap on the history sent with each message (0 = whatever fits)
+}
+
+// NewContextManager creates a context manager from the [context_windows] of
+// config.toml, CONTEXT_WINDOW and HISTORY_TOKENS.
+func NewContextManager(cfg *config.Config) *ContextManager {
+	defaultWindow := cfg.ContextWindow
+	if defaultWindow <= 0 {
+		defaultWindow = DefaultContextWindow
+	}
+	return &ContextManager{
+		windows:       cfg.ContextWindows,
+		defaultWindow: defaultWindow,
+		historyTokens: cfg.HistoryTokens,
+	}
+}
+
+// Window returns the context window of model in tokens.
+func (m *ContextManager) Window(model string) int {
+	if window, ok := m.windows[model]; ok {
+		return window
+	}
+	return m.defaultWindow
+}
+
+// Available returns the tokens a prompt to model may take up, leaving room
+// for the response.
+func (m *ContextManager) Available(model string) int {
+	window := m.Window(model)
+	return max(window-responseReserve, window/2)
+}
+
+// Count returns the number of tokens in text.
+func (m *ContextManager) Count(text string) int {
+	if enc := encoding(); enc != nil {
+		return len(enc.EncodeOrdinary(text))
+	}
+	// Roughly four characters per token in English text
+	return (len(text) + 3) / 4
+}
+
+// CountMessages returns the number of tokens messages take up in a prompt.
+func (m *ContextManager) CountMessages(messages []Message) int {
+	total := 3 // Every response is primed with the assistant role
+	for _, msg := range messages {
+		total += m.countMessage(msg)
+	}
+	return total
+}
+
+// countMessage returns the number of tokens of one message.
+func (m *ContextManager) countMessage(msg Message) int {
+	total := messageOverhead + m.Count(msg.Content) + len(msg.Images)*imageTokens
+	for _, call := range msg.ToolCalls {
+		total += m.Count(call.Name) + m.Count(call.Arguments)
+	}
+	return total
+}
+
+// Trim describes what fitting a conversation took.
+type Trim struct {
+	Compressed int // Messages cut down to their beginning
+	Dropped    int // Messages left out
+	Tokens     int // Tokens of the fitted conversation
+}
+
+// Trimmed reports whether any message was compressed or dropped.
+func (t Trim) Trimmed() bool {
+	return t.Compressed > 0 || t.Dropped > 0
+}
+
+// HistoryBudget returns the tokens the history of a conversation may take
+// up: what the window of model leaves after systemPrompt, and at most
+// HISTORY_TOKENS.
+func (m *ContextManager) HistoryBudget(model, systemPrompt string) int {
+	budget := m.Available(model) - m.Count(systemPrompt) - messageOverhead
+	if m.historyTokens > 0 {
+		budget = min(budget, m.historyTokens)
+	}
+	return budget
+}
+
+// FitHistory fits the history of a conversation into its HistoryBudget.
+func (m *ContextManager) FitHistory(model, systemPrompt string, history []Message) ([]Message, Trim) {
+	return m.Fit(history, m.HistoryBudget(model, systemPrompt))
+}
+
+// Fit returns messages cut down to at most limit tokens. Old messages are
+// compressed first, oldest first, then dropped in the same order, along with
+// the tool results answering them. Leading system messages, the last message
+// and the messages at the pinned indexes are kept intact. messages is not
+// modified.
+func (m *ContextManager) Fit(messages []Message, limit int, pinned ...int) ([]Message, Trim) {
+	trim := Trim{Tokens: m.CountMessages(messages)}
+	if trim.Tokens <= limit || len(messages) == 0 {
+		return messages, trim
+	}
+
+	fitted := slices.Clone(messages)
+	keep := make([]bool, len(fitted))
+	for i := 0; i < len(fitted) && fitted[i].Role == RoleSystem; i++ {
+		keep[i] = true
+	}
+	for _, i := range pinned {
+		if i >= 0 && i < len(keep) {
+			keep[i] = true
+		}
+	}
+	keep[len(keep)-1] = true
+
+	for i := range fitted {
+		if trim.Tokens <= limit {
+			break
+		}
+		if keep[i] {
+			continue
+		}
+		content, saved := m.compress(fitted[i].Content)
+		if saved > 0 {
+			fitted[i].Content = content
+			trim.Tokens -= saved
+			trim.Compressed++
+		}
+	}
+
+	dropped := make([]bool, len(fitted))
+	conversationStart := true // Nothing but system messages kept so far
+	for i := 0; i < len(fitted); i++ {
+		if keep[i] {
+			conversationStart = conversationStart && fitted[i].Role == RoleSystem
+			continue
+		}
+		// Providers reject a conversation that opens with the assistant
+		if trim.Tokens <= limit && !(conversationStart && fitted[i].Role != RoleUser) {
+			conversationStart = false
+			continue
+		}
+
+		// Tool results go with the call they answer
+		if fitted[i].Role == RoleTool {
+			continue
+		}
+		end := i + 1
+		for end < len(fitted) && fitted[end].Role == RoleTool && !keep[end] {
+			end++
+		}
+		if end < len(fitted) && fitted[end].Role == RoleTool {
+			conversationStart = false
+			continue
+		}
+
+		for j := i; j < end; j++ {
+			dropped[j] = true
+			trim.Tokens -= m.countMessage(fitted[j])
+			trim.Dropped++
+		}
+		i = end - 1
+	}
+
+	result := make([]Message, 0, len(fitted)-trim.Dropped)
+	for i, msg := range fitted {
+		if !dropped[i] {
+			result = append(result, msg)
+		}
+	}
+	return result, trim
+}
+
+// compress cuts content down to its first compressKeepTokens tokens. It
+// returns the content unchanged and zero savings when it's already short.
+func (m *ContextManager) compress(content string) (string, int) {
+	if strings.HasSuffix(content, compressedMarker) {
+		return content, 0
+	}
+
+	tokens := m.Count(content)
+	if tokens < compressMinTokens {
+		return content, 0
+	}
+
+	var head string
+	if enc := encoding(); enc != nil {
+		head = enc.Decode(enc.EncodeOrdinary(content)[:compressKeepTokens])
+	} else {
+		head = content[:compressKeepTokens*4]
+	}
+	compressed := strings.ToValidUTF8(head, "") + compressedMarker
+	return compressed, tokens - m.Count(compressed)
+}
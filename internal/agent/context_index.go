@@ -0,0 +1,287 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/j0lvera/banray/internal/config"
+	"github.com/j0lvera/banray/internal/db"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+)
+
+// DefaultChunkTokens is the chunk size used when CONTEXT_CHUNK_TOKENS isn't
+// positive.
+const DefaultChunkTokens = 400
+
+// headingPattern matches a markdown ATX heading, capturing its level and title.
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+// ContextIndex splits the .md files of CONTEXT_DIR and the context
+// directories of profiles, subdirectories included, into chunks of about
+// CONTEXT_CHUNK_TOKENS and indexes them for full-text search in Postgres, so
+// prompts carry only the parts of the context relevant to each message.
+type ContextIndex struct {
+	client      *db.Client
+	contexts    *ContextManager
+	cfg         *config.Config
+	chunkTokens int
+	mu          sync.Mutex // Serializes reindexing
+}
+
+// NewContextIndex creates a context index over the context directories.
+func NewContextIndex(client *db.Client, contexts *ContextManager, cfg *config.Config) *ContextIndex {
+	chunkTokens := cfg.ContextChunkTokens
+	if chunkTokens <= 0 {
+		chunkTokens = DefaultChunkTokens
+	}
+	return &ContextIndex{
+		client:      client,
+		contexts:    contexts,
+		cfg:         cfg,
+		chunkTokens: chunkTokens,
+	}
+}
+
+// IndexStats describes what a reindex stored.
+type IndexStats struct {
+	Dirs   int // Context directories read
+	Files  int // Files read
+	Chunks int // Chunks stored
+	Tokens int // Tokens across all chunks
+}
+
+// contextChunk is a piece of a context file, under the headings it falls in.
+type contextChunk struct {
+	heading string
+	content string
+}
+
+// contextFile is a context file split into chunks.
+type contextFile struct {
+	dir    string
+	path   string
+	chunks []contextChunk
+}
+
+// Reindex reads the context directories again and replaces the whole index
+// in one transaction, so searches see either the old chunks or the new ones.
+// Missing directories have no chunks.
+func (x *ContextIndex) Reindex(ctx context.Context) (IndexStats, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var files []contextFile
+	dirs := x.cfg.ContextDirs()
+	for _, dir := range dirs {
+		dirFiles, err := x.read(dir)
+		if err != nil {
+			return IndexStats{}, err
+		}
+		files = append(files, dirFiles...)
+	}
+
+	tx, err := x.client.Pool.Begin(ctx)
+	if err != nil {
+		return IndexStats{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	queries := x.client.Queries.WithTx(tx)
+	if _, err := queries.DeleteContextChunks(ctx); err != nil {
+		return IndexStats{}, fmt.Errorf("failed to clear context index: %w", err)
+	}
+	stats := IndexStats{Dirs: len(dirs)}
+	for _, file := range files {
+		stats.Files++
+		for i, chunk := range file.chunks {
+			tokens := x.contexts.Count(chunk.content)
+			err := queries.CreateContextChunk(ctx, dbgen.CreateContextChunkParams{
+				Dir:        file.dir,
+				Path:       file.path,
+				ChunkIndex: int32(i),
+				Heading:    chunk.heading,
+				Content:    chunk.content,
+				Tokens:     int32(tokens),
+			})
+			if err != nil {
+				return IndexStats{}, fmt.Errorf("failed to index chunk %d of %s: %w", i, filepath.Join(file.dir, file.path), err)
+			}
+			stats.Chunks++
+			stats.Tokens += tokens
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return IndexStats{}, err
+	}
+	return stats, nil
+}
+
+// read splits the .md files of a context directory into chunks. A missing
+// directory has none.
+func (x *ContextIndex) read(dir string) ([]contextFile, error) {
+	var files []contextFile
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip hidden files and directories such as .git, but not the root
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read context file %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, contextFile{dir: dir, path: filepath.ToSlash(rel), chunks: x.chunk(string(content))})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to walk context directory %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// Search returns up to limit chunks of a context directory sharing words
+// with text, most relevant first.
+func (x *ContextIndex) Search(ctx context.Context, dir string, text string, limit int) ([]*dbgen.DataContextChunk, error) {
+	return x.client.Queries.SearchContextChunks(ctx, dbgen.SearchContextChunksParams{
+		Dir:        dir,
+		Query:      text,
+		MaxResults: int32(limit),
+	})
+}
+
+// chunk splits a markdown file into sections at its headings, then packs the
+// paragraphs of each section into chunks of at most chunkTokens. Fenced code
+// blocks are never split at their blank lines or read as headings. A single
+// line longer than chunkTokens becomes a chunk of its own.
+func (x *ContextIndex) chunk(content string) []contextChunk {
+	var chunks []contextChunk
+	var titles [6]string
+	var heading string
+	var blocks []string
+	var block []string
+	inFence := false
+
+	flushBlock := func() {
+		if text := strings.TrimSpace(strings.Join(block, "\n")); text != "" {
+			blocks = append(blocks, text)
+		}
+		block = nil
+	}
+	flushSection := func() {
+		flushBlock()
+		chunks = append(chunks, x.pack(heading, blocks)...)
+		blocks = nil
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence {
+			if match := headingPattern.FindStringSubmatch(line); match != nil {
+				flushSection()
+				level := len(match[1])
+				titles[level-1] = match[2]
+				clear(titles[level:])
+				heading = joinTitles(titles[:])
+				continue
+			}
+			if trimmed == "" {
+				flushBlock()
+				continue
+			}
+		}
+		block = append(block, line)
+	}
+	flushSection()
+
+	return chunks
+}
+
+// pack groups the blocks of one section into chunks of at most chunkTokens,
+// splitting blocks too large on their own at line breaks.
+func (x *ContextIndex) pack(heading string, blocks []string) []contextChunk {
+	var chunks []contextChunk
+	var current string
+
+	flush := func() {
+		if current != "" {
+			chunks = append(chunks, contextChunk{heading: heading, content: current})
+		}
+		current = ""
+	}
+	add := func(text, sep string) {
+		if current == "" {
+			current = text
+			return
+		}
+		if x.contexts.Count(current+sep+text) > x.chunkTokens {
+			flush()
+			current = text
+			return
+		}
+		current += sep + text
+	}
+
+	for _, block := range blocks {
+		if x.contexts.Count(block) <= x.chunkTokens {
+			add(block, "\n\n")
+			continue
+		}
+		flush()
+		for _, line := range strings.Split(block, "\n") {
+			add(line, "\n")
+		}
+		flush()
+	}
+	flush()
+
+	return chunks
+}
+
+// joinTitles joins the headings a section falls under, outermost first.
+func joinTitles(titles []string) string {
+	var parts []string
+	for _, title := range titles {
+		if title != "" {
+			parts = append(parts, title)
+		}
+	}
+	return strings.Join(parts, " > ")
+}
+
+// ContextPrompt formats retrieved chunks for a system prompt, each under its
+// file and headings, or returns an empty string when there are none.
+func ContextPrompt(chunks []*dbgen.DataContextChunk) string {
+	parts := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		source := chunk.Path
+		if chunk.Heading != "" {
+			source += " > " + chunk.Heading
+		}
+		parts = append(parts, fmt.Sprintf("### %s\n\n%s", source, chunk.Content))
+	}
+	return strings.Join(parts, "\n\n---\n\n")
+}
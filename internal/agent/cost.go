@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/j0lvera/banray/internal/config"
+)
+
+// Pricing turns token counts into dollars using per-model prices.
+type Pricing map[string]config.ModelPrice
+
+// Cost returns what a request to model cost in USD. Models without a price
+// cost nothing.
+func (p Pricing) Cost(model string, inputTokens, outputTokens int) float64 {
+	price, ok := p[model]
+	if !ok {
+		return 0
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1e6
+}
+
+// QueryCost returns what a query cost: the provider's figure when it
+// reported one, otherwise the price table's. Prompt cache reads and writes
+// are billed at their own rates when the model has them.
+func (p Pricing) QueryCost(model string, result QueryResult) float64 {
+	if result.CostReported {
+		return result.CostUSD
+	}
+
+	price, ok := p[model]
+	if !ok {
+		return 0
+	}
+	cacheRead, cacheWrite := price.Input, price.Input
+	if price.CacheRead > 0 {
+		cacheRead = price.CacheRead
+	}
+	if price.CacheWrite > 0 {
+		cacheWrite = price.CacheWrite
+	}
+
+	uncached := result.InputTokens - result.CacheReadTokens - result.CacheWriteTokens
+	return (float64(uncached)*price.Input +
+		float64(result.CacheReadTokens)*cacheRead +
+		float64(result.CacheWriteTokens)*cacheWrite +
+		float64(result.OutputTokens)*price.Output) / 1e6
+}
+
+// responseRecorder receives what the HTTP client saw of the response to one
+// request: its status and the cost the provider reported.
+type responseRecorder struct {
+	mu       sync.Mutex
+	status   int
+	cost     float64
+	reported bool
+}
+
+func (r *responseRecorder) recordStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *responseRecorder) record(cost float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cost = cost
+	r.reported = true
+}
+
+func (r *responseRecorder) get() (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cost, r.reported
+}
+
+func (r *responseRecorder) statusCode() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+type responseRecorderKey struct{}
+
+// withResponseRecorder attaches a recorder for the request made with ctx.
+func withResponseRecorder(ctx context.Context, r *responseRecorder) context.Context {
+	return context.WithValue(ctx, responseRecorderKey{}, r)
+}
+
+// usageCostClient is an HTTP client that picks the cost out of the usage
+// object of chat completion responses, which langchaingo drops. OpenRouter
+// reports it as usage.cost, in plain and streamed responses alike. It also
+// records the response status, which langchaingo only puts in error text.
+type usageCostClient struct {
+	client       *http.Client
+	requestUsage bool // Ask for usage accounting in each request (OpenRouter)
+}
+
+// newUsageCostClient creates a client for the API at baseURL.
+func newUsageCostClient(baseURL string) *usageCostClient {
+	return &usageCostClient{
+		client:       http.DefaultClient,
+		requestUsage: strings.Contains(baseURL, "openrouter.ai"),
+	}
+}
+
+// Do sends the request, recording the status and reported cost in the
+// request context's recorder, if it has one.
+func (c *usageCostClient) Do(req *http.Request) (*http.Response, error) {
+	recorder, _ := req.Context().Value(responseRecorderKey{}).(*responseRecorder)
+	if recorder == nil {
+		return c.client.Do(req)
+	}
+
+	if c.requestUsage {
+		if err := includeUsage(req); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	recorder.recordStatus(resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &usageStreamReader{body: resp.Body, recorder: recorder}
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	recordUsageCost(body, recorder)
+	return resp, nil
+}
+
+// includeUsage adds "usage": {"include": true} to a JSON request body.
+func includeUsage(req *http.Request) error {
+	if req.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err == nil {
+		if _, ok := payload["usage"]; !ok {
+			payload["usage"] = map[string]any{"include": true}
+			if encoded, err := json.Marshal(payload); err == nil {
+				body = encoded
+			}
+		}
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return nil
+}
+
+// recordUsageCost records usage.cost from a JSON response, if present.
+func recordUsageCost(data []byte, recorder *responseRecorder) {
+	var resp struct {
+		Usage *struct {
+			Cost *float64 `json:"cost"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Usage == nil || resp.Usage.Cost == nil {
+		return
+	}
+	recorder.record(*resp.Usage.Cost)
+}
+
+// usageStreamReader passes a server-sent event stream through, recording
+// usage.cost from the chunk that carries it.
+type usageStreamReader struct {
+	body     io.ReadCloser
+	recorder *responseRecorder
+	line     []byte // Partial line carried over between reads
+}
+
+func (r *usageStreamReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.line = append(r.line, p[:n]...)
+	for {
+		i := bytes.IndexByte(r.line, '\n')
+		if i < 0 {
+			break
+		}
+		r.scan(r.line[:i])
+		r.line = r.line[i+1:]
+	}
+	return n, err
+}
+
+// scan inspects one line of the stream.
+func (r *usageStreamReader) scan(line []byte) {
+	data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+	if !ok || !bytes.Contains(data, []byte(`"cost"`)) {
+		return
+	}
+	recordUsageCost(bytes.TrimSpace(data), r.recorder)
+}
+
+func (r *usageStreamReader) Close() error {
+	return r.body.Close()
+}
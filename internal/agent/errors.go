@@ -6,8 +6,9 @@ import "fmt"
 type TerminationReason string
 
 const (
-	ReasonComplete  TerminationReason = "complete"
-	ReasonStepLimit TerminationReason = "step_limit"
+	ReasonComplete       TerminationReason = "complete"
+	ReasonStepLimit      TerminationReason = "step_limit"
+	ReasonBudgetExceeded TerminationReason = "budget_exceeded"
 )
 
 // TerminatingErr signals the agent should stop the loop.
@@ -27,6 +28,7 @@ const (
 	ProcessErrFormat    ProcessErrType = "format"
 	ProcessErrTimeout   ProcessErrType = "timeout"
 	ProcessErrExecution ProcessErrType = "execution"
+	ProcessErrDenied    ProcessErrType = "denied"
 )
 
 // ProcessErr signals a recoverable error. The agent should add
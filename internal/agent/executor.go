@@ -15,6 +15,12 @@ type Executor interface {
 	Execute(ctx context.Context, action Action) (Output, error)
 }
 
+// Workspace is implemented by executors that confine commands to their own
+// directory.
+type Workspace interface {
+	WorkDir() string
+}
+
 // CommandValidator checks if a command is safe to execute.
 type CommandValidator interface {
 	Validate(command string) error
@@ -155,11 +161,24 @@ func (e *BashExecutor) Execute(ctx context.Context, action Action) (Output, erro
 		cmd.Dir = e.workingDir
 	}
 
+	return runCommand(timeoutCtx, cmd, e.timeout)
+}
+
+// runCommand runs cmd, whose context carries the timeout, and converts
+// failures into feedback for the model.
+func runCommand(timeoutCtx context.Context, cmd *exec.Cmd, timeout time.Duration) (Output, error) {
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
+	// Don't wait forever on pipes held open by background processes
+	cmd.WaitDelay = time.Second
+
 	err := cmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// The command exited cleanly but left a background process behind
+		err = nil
+	}
 
 	output := Output{
 		Stdout: stdout.String(),
@@ -172,7 +191,7 @@ func (e *BashExecutor) Execute(ctx context.Context, action Action) (Output, erro
 			output.TimedOut = true
 			return output, &ProcessErr{
 				Type:    ProcessErrTimeout,
-				Message: fmt.Sprintf("Command timed out after %s. Partial output:\n%s", e.timeout, output.String()),
+				Message: fmt.Sprintf("Command timed out after %s. Partial output:\n%s", timeout, output.String()),
 			}
 		}
 
@@ -0,0 +1,330 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/j0lvera/banray/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// ErrorClass groups query errors by how they should be handled.
+type ErrorClass string
+
+const (
+	ErrorClassRateLimit ErrorClass = "rate_limit" // 429: back off and retry
+	ErrorClassServer    ErrorClass = "server"     // 5xx and unexplained failures: retry, then fall back
+	ErrorClassNetwork   ErrorClass = "network"    // Timeouts and connection errors: retry, then fall back
+	ErrorClassClient    ErrorClass = "client"     // Other 4xx: retrying won't help, fall back
+	ErrorClassCanceled  ErrorClass = "canceled"   // The caller gave up: stop
+)
+
+// ClassifyError returns the class of an error returned by a Querier.
+func ClassifyError(err error) ErrorClass {
+	var queryErr *QueryError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.As(err, &queryErr):
+		switch {
+		case queryErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorClassRateLimit
+		case queryErr.StatusCode == http.StatusRequestTimeout || queryErr.StatusCode >= 500:
+			return ErrorClassServer
+		default:
+			return ErrorClassClient
+		}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return ErrorClassNetwork
+	default:
+		return ErrorClassServer
+	}
+}
+
+// FallbackModel is one model of a fallback chain.
+type FallbackModel struct {
+	Name      string  // Model ID sent with each query
+	Querier   Querier // Client for the model's provider
+	Auxiliary bool    // Only tried when asked for by name, never as a fallback
+}
+
+// FallbackQuerier sends each query down an ordered chain of models. Failed
+// queries are retried with exponential backoff as the retry policy allows for
+// their error class, then handed to the next model. A model that keeps failing
+// is skipped until its circuit breaker cools down.
+type FallbackQuerier struct {
+	models   []FallbackModel
+	breakers []*circuitBreaker
+	policy   config.Retry
+	logger   *zerolog.Logger
+}
+
+// NewFallbackQuerier creates a querier for a chain of models, the first being
+// the primary.
+func NewFallbackQuerier(models []FallbackModel, policy config.Retry, logger *zerolog.Logger) (*FallbackQuerier, error) {
+	if len(models) == 0 {
+		return nil, errors.New("fallback chain has no models")
+	}
+
+	breakers := make([]*circuitBreaker, len(models))
+	for i := range breakers {
+		breakers[i] = &circuitBreaker{threshold: policy.BreakerThreshold, cooldown: policy.BreakerCooldown}
+	}
+
+	return &FallbackQuerier{
+		models:   models,
+		breakers: breakers,
+		policy:   policy,
+		logger:   logger,
+	}, nil
+}
+
+// Query sends messages down the chain until a model answers.
+func (q *FallbackQuerier) Query(ctx context.Context, messages []Message, opts ...QueryOption) (QueryResult, error) {
+	return q.query(ctx, opts, func(ctx context.Context, querier Querier, opts []QueryOption) (QueryResult, error) {
+		return querier.Query(ctx, messages, opts...)
+	}, func() bool { return false })
+}
+
+// QueryStream sends messages down the chain until a model answers, streaming
+// the response. Once a chunk has reached onChunk the query is no longer
+// retried, since the output can't be taken back.
+func (q *FallbackQuerier) QueryStream(ctx context.Context, messages []Message, onChunk func(chunk string) error, opts ...QueryOption) (QueryResult, error) {
+	streamed := false
+	forward := func(chunk string) error {
+		streamed = true
+		return onChunk(chunk)
+	}
+
+	return q.query(ctx, opts, func(ctx context.Context, querier Querier, opts []QueryOption) (QueryResult, error) {
+		if streamer, ok := querier.(StreamingQuerier); ok {
+			return streamer.QueryStream(ctx, messages, forward, opts...)
+		}
+		result, err := querier.Query(ctx, messages, opts...)
+		if err == nil && result.Content != "" {
+			err = forward(result.Content)
+		}
+		return result, err
+	}, func() bool { return streamed })
+}
+
+// queryFunc sends one query to a model's querier.
+type queryFunc func(ctx context.Context, querier Querier, opts []QueryOption) (QueryResult, error)
+
+// fallbackAttempt is a model to try: an entry of the chain, possibly asked
+// for another model than its own.
+type fallbackAttempt struct {
+	index int
+	model string
+}
+
+// query walks the chain. committed reports whether output already reached
+// the caller, which rules out another attempt.
+func (q *FallbackQuerier) query(ctx context.Context, opts []QueryOption, send queryFunc, committed func() bool) (QueryResult, error) {
+	var options QueryOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var lastErr error
+	for _, attempt := range q.attempts(options.Model) {
+		breaker := q.breakers[attempt.index]
+		if !breaker.allow(time.Now()) {
+			q.logger.Debug().Str("model", attempt.model).Msg("Skipping model with open circuit")
+			continue
+		}
+
+		result, err := q.tryModel(ctx, attempt.index, attempt.model, opts, send, committed)
+		if err == nil {
+			breaker.success()
+			if result.Model == "" {
+				result.Model = attempt.model
+			}
+			return result, nil
+		}
+
+		class := ClassifyError(err)
+		if class == ErrorClassCanceled || ctx.Err() != nil {
+			breaker.release()
+			return QueryResult{}, err
+		}
+		if class == ErrorClassClient {
+			breaker.release()
+		} else if breaker.failure(time.Now()) {
+			q.logger.Warn().
+				Str("model", attempt.model).
+				Dur("cooldown", q.policy.BreakerCooldown).
+				Msg("Circuit opened")
+		}
+		if committed() {
+			return QueryResult{}, err
+		}
+
+		q.logger.Warn().
+			Err(err).
+			Str("model", attempt.model).
+			Str("error_class", string(class)).
+			Msg("Model failed, falling back")
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return QueryResult{}, errors.New("no model available: every circuit is open")
+	}
+	return QueryResult{}, fmt.Errorf("all models failed: %w", lastErr)
+}
+
+// attempts returns the order in which to try the chain. A requested model
+// goes first; one that isn't in the chain is sent to the primary's querier.
+// Auxiliary models are only tried when requested.
+func (q *FallbackQuerier) attempts(requested string) []fallbackAttempt {
+	attempts := make([]fallbackAttempt, 0, len(q.models)+1)
+	found := false
+	for i, model := range q.models {
+		if model.Name == requested {
+			attempts = append(attempts, fallbackAttempt{index: i, model: model.Name})
+			found = true
+		}
+	}
+	if requested != "" && !found {
+		attempts = append(attempts, fallbackAttempt{index: q.primary(), model: requested})
+	}
+	for i, model := range q.models {
+		if model.Name != requested && !model.Auxiliary {
+			attempts = append(attempts, fallbackAttempt{index: i, model: model.Name})
+		}
+	}
+	return attempts
+}
+
+// primary returns the index of the first model that isn't auxiliary.
+func (q *FallbackQuerier) primary() int {
+	for i, model := range q.models {
+		if !model.Auxiliary {
+			return i
+		}
+	}
+	return 0
+}
+
+// tryModel queries one model, retrying as the policy allows.
+func (q *FallbackQuerier) tryModel(ctx context.Context, index int, model string, opts []QueryOption, send queryFunc, committed func() bool) (QueryResult, error) {
+	opts = append(opts[:len(opts):len(opts)], WithModel(model))
+	querier := q.models[index].Querier
+
+	for retry := 0; ; retry++ {
+		result, err := send(ctx, querier, opts)
+		if err == nil {
+			return result, nil
+		}
+
+		class := ClassifyError(err)
+		if class == ErrorClassCanceled || committed() || retry >= q.retries(class) {
+			return QueryResult{}, err
+		}
+
+		delay := q.backoff(retry)
+		q.logger.Warn().
+			Err(err).
+			Str("model", model).
+			Str("error_class", string(class)).
+			Int("retry", retry+1).
+			Dur("delay", delay).
+			Msg("Query failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return QueryResult{}, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retries returns how many times an error of class may be retried.
+func (q *FallbackQuerier) retries(class ErrorClass) int {
+	switch class {
+	case ErrorClassRateLimit:
+		return q.policy.RateLimit
+	case ErrorClassServer:
+		return q.policy.Server
+	case ErrorClassNetwork:
+		return q.policy.Network
+	default:
+		return 0
+	}
+}
+
+// backoff returns the delay before a retry: the base delay doubled for each
+// earlier retry, capped, with jitter so clients don't retry in lockstep.
+func (q *FallbackQuerier) backoff(retry int) time.Duration {
+	delay := q.policy.MaxDelay
+	if retry < 30 {
+		delay = min(q.policy.BaseDelay<<retry, q.policy.MaxDelay)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// circuitBreaker takes a model out of the chain after consecutive failures.
+// Once the cooldown passes a single query is let through: success closes the
+// circuit, failure opens it again.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool // A trial query is in flight
+}
+
+// allow reports whether a query may be sent to the model.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// success closes the circuit.
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// failure counts a failed query and reports whether it opened the circuit.
+func (b *circuitBreaker) failure(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openUntil = now.Add(b.cooldown)
+	return true
+}
+
+// release ends a query that says nothing about the model's health.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/banray/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// fakeProvider is an OpenAI-compatible chat completions server. Each model
+// answers with the statuses scripted for it, in order, then with 200.
+type fakeProvider struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	statuses map[string][]int
+	calls    map[string][]time.Time // When each model was queried
+}
+
+// newFakeProvider starts a server answering with the scripted statuses.
+func newFakeProvider(t *testing.T, statuses map[string][]int) *fakeProvider {
+	t.Helper()
+	p := &fakeProvider{statuses: statuses, calls: make(map[string][]time.Time)}
+	p.server = httptest.NewServer(http.HandlerFunc(p.handle))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *fakeProvider) handle(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	p.calls[request.Model] = append(p.calls[request.Model], time.Now())
+	status := http.StatusOK
+	if script := p.statuses[request.Model]; len(script) > 0 {
+		status, p.statuses[request.Model] = script[0], script[1:]
+	}
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"error":{"message":"scripted %d","type":"test"}}`, status)
+		return
+	}
+	fmt.Fprintf(w, `{
+		"id": "chatcmpl-test",
+		"object": "chat.completion",
+		"created": 0,
+		"model": %q,
+		"choices": [{"index": 0, "message": {"role": "assistant", "content": "answer from %s"}, "finish_reason": "stop"}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+	}`, request.Model, request.Model)
+}
+
+// script queues more statuses for a model.
+func (p *fakeProvider) script(model string, statuses ...int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statuses[model] = append(p.statuses[model], statuses...)
+}
+
+// callTimes returns when a model was queried.
+func (p *fakeProvider) callTimes(model string) []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]time.Time(nil), p.calls[model]...)
+}
+
+// querier returns a fallback querier over the given models, all served by p.
+func (p *fakeProvider) querier(t *testing.T, policy config.Retry, names ...string) *FallbackQuerier {
+	t.Helper()
+	models := make([]FallbackModel, 0, len(names))
+	for _, name := range names {
+		querier, err := NewOpenAIQuerier("test-key", p.server.URL, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		models = append(models, FallbackModel{Name: name, Querier: querier})
+	}
+	logger := zerolog.Nop()
+	fallback, err := NewFallbackQuerier(models, policy, &logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fallback
+}
+
+// ask sends one user message.
+func ask(t *testing.T, querier *FallbackQuerier, opts ...QueryOption) (QueryResult, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return querier.Query(ctx, []Message{{Role: RoleUser, Content: "hello"}}, opts...)
+}
+
+func TestFallbackRetriesRateLimitWithBackoff(t *testing.T) {
+	provider := newFakeProvider(t, map[string][]int{
+		"primary": {http.StatusTooManyRequests, http.StatusTooManyRequests},
+	})
+	policy := config.Retry{RateLimit: 2, BaseDelay: 40 * time.Millisecond, MaxDelay: time.Second, BreakerThreshold: 5}
+	querier := provider.querier(t, policy, "primary", "backup")
+
+	result, err := ask(t, querier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Model != "primary" || result.Content != "answer from primary" {
+		t.Errorf("answered by %q with %q, want primary", result.Model, result.Content)
+	}
+
+	calls := provider.callTimes("primary")
+	if len(calls) != 3 {
+		t.Fatalf("primary queried %d times, want 3", len(calls))
+	}
+	if n := len(provider.callTimes("backup")); n != 0 {
+		t.Errorf("backup queried %d times, want 0", n)
+	}
+	// Jitter keeps each delay within half and all of the doubled base delay
+	for i, min := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond} {
+		if gap := calls[i+1].Sub(calls[i]); gap < min {
+			t.Errorf("retry %d after %s, want at least %s", i+1, gap, min)
+		}
+	}
+}
+
+func TestFallbackServerErrorFallsOver(t *testing.T) {
+	provider := newFakeProvider(t, map[string][]int{
+		"primary": {http.StatusBadGateway, http.StatusServiceUnavailable},
+	})
+	policy := config.Retry{Server: 1, BreakerThreshold: 5}
+	querier := provider.querier(t, policy, "primary", "backup")
+
+	result, err := ask(t, querier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Model != "backup" || result.Content != "answer from backup" {
+		t.Errorf("answered by %q with %q, want backup", result.Model, result.Content)
+	}
+	if n := len(provider.callTimes("primary")); n != 2 {
+		t.Errorf("primary queried %d times, want 2 (one retry)", n)
+	}
+	if n := len(provider.callTimes("backup")); n != 1 {
+		t.Errorf("backup queried %d times, want 1", n)
+	}
+}
+
+func TestFallbackClientErrorIsNotRetried(t *testing.T) {
+	provider := newFakeProvider(t, map[string][]int{
+		"primary": {http.StatusBadRequest},
+		"backup":  {http.StatusUnauthorized},
+	})
+	policy := config.Retry{RateLimit: 3, Server: 3, Network: 3, BreakerThreshold: 1, BreakerCooldown: time.Hour}
+	querier := provider.querier(t, policy, "primary", "backup")
+
+	_, err := ask(t, querier)
+	if err == nil {
+		t.Fatal("query succeeded, want the client errors")
+	}
+	if class := ClassifyError(err); class != ErrorClassClient {
+		t.Errorf("error class %s, want %s", class, ErrorClassClient)
+	}
+	for _, model := range []string{"primary", "backup"} {
+		if n := len(provider.callTimes(model)); n != 1 {
+			t.Errorf("%s queried %d times, want 1", model, n)
+		}
+	}
+
+	// Client errors say nothing about the model's health, so its circuit stays closed
+	result, err := ask(t, querier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Model != "primary" {
+		t.Errorf("answered by %q, want primary", result.Model)
+	}
+}
+
+func TestFallbackCircuitBreaker(t *testing.T) {
+	provider := newFakeProvider(t, map[string][]int{
+		"primary": {http.StatusInternalServerError, http.StatusInternalServerError},
+	})
+	cooldown := 100 * time.Millisecond
+	policy := config.Retry{BreakerThreshold: 2, BreakerCooldown: cooldown}
+	querier := provider.querier(t, policy, "primary", "backup")
+
+	// Two failures in a row open the circuit
+	for i := 0; i < 2; i++ {
+		result, err := ask(t, querier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Model != "backup" {
+			t.Fatalf("query %d answered by %q, want backup", i+1, result.Model)
+		}
+	}
+
+	// While it's open the primary is skipped
+	result, err := ask(t, querier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Model != "backup" {
+		t.Errorf("answered by %q with the circuit open, want backup", result.Model)
+	}
+	if n := len(provider.callTimes("primary")); n != 2 {
+		t.Errorf("primary queried %d times with its circuit open, want 2", n)
+	}
+
+	// After the cooldown a failed probe opens it again
+	time.Sleep(cooldown + 20*time.Millisecond)
+	provider.script("primary", http.StatusInternalServerError)
+	if result, err := ask(t, querier); err != nil || result.Model != "backup" {
+		t.Fatalf("answered by %q (%v), want backup after the failed probe", result.Model, err)
+	}
+	if n := len(provider.callTimes("primary")); n != 3 {
+		t.Errorf("primary queried %d times, want 3 (one probe)", n)
+	}
+	if result, err := ask(t, querier); err != nil || result.Model != "backup" {
+		t.Fatalf("answered by %q (%v), want backup while open again", result.Model, err)
+	}
+	if n := len(provider.callTimes("primary")); n != 3 {
+		t.Errorf("primary queried %d times while open again, want 3", n)
+	}
+
+	// A successful probe closes it
+	time.Sleep(cooldown + 20*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		result, err := ask(t, querier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Model != "primary" {
+			t.Errorf("query %d after recovery answered by %q, want primary", i+1, result.Model)
+		}
+	}
+}
+
+func TestFallbackResultNamesAnsweringModel(t *testing.T) {
+	provider := newFakeProvider(t, map[string][]int{
+		"requested": {http.StatusServiceUnavailable},
+	})
+	policy := config.Retry{BreakerThreshold: 5}
+	querier := provider.querier(t, policy, "primary", "requested", "backup")
+
+	// A requested model goes first; when it fails the chain continues from the primary
+	result, err := ask(t, querier, WithModel("requested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Model != "primary" || result.Content != "answer from primary" {
+		t.Errorf("answered by %q with %q, want primary", result.Model, result.Content)
+	}
+
+	result, err = ask(t, querier, WithModel("requested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Model != "requested" {
+		t.Errorf("answered by %q, want requested", result.Model)
+	}
+}
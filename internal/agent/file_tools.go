@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFileTool reads files under the working directory.
+type ReadFileTool struct {
+	root string
+}
+
+// NewReadFileTool creates a read_file tool rooted at dir.
+func NewReadFileTool(dir string) *ReadFileTool {
+	return &ReadFileTool{root: dir}
+}
+
+// Definition returns the read_file tool definition.
+func (t *ReadFileTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name:        string(ActionTypeReadFile),
+		Description: "Read a text file from the working directory. Paths are relative to the working directory.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path of the file to read",
+				},
+			},
+			"required": []string{"path"},
+		},
+	}
+}
+
+// Execute reads the requested file.
+func (t *ReadFileTool) Execute(ctx context.Context, action Action) (Output, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := decodeArgs(action, &args); err != nil {
+		return Output{}, err
+	}
+	if strings.TrimSpace(args.Path) == "" {
+		return Output{}, &ProcessErr{Type: ProcessErrFormat, Message: "read_file requires a non-empty \"path\"."}
+	}
+
+	path, err := resolvePath(t.root, args.Path)
+	if err != nil {
+		return Output{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("Cannot read %q: %s", args.Path, err)}
+	}
+	if info.IsDir() {
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("%q is a directory, not a file.", args.Path)}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("Cannot read %q: %s", args.Path, err)}
+	}
+
+	return Output{Stdout: truncateOutput(string(content))}, nil
+}
+
+// WriteFileTool writes files under the working directory.
+type WriteFileTool struct {
+	root string
+}
+
+// NewWriteFileTool creates a write_file tool rooted at dir.
+func NewWriteFileTool(dir string) *WriteFileTool {
+	return &WriteFileTool{root: dir}
+}
+
+// Definition returns the write_file tool definition.
+func (t *WriteFileTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name:        string(ActionTypeWriteFile),
+		Description: "Write a text file in the working directory, creating parent directories as needed. Overwrites the file unless append is true.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path of the file to write",
+				},
+				"content": map[string]any{
+					"type":        "string",
+					"description": "Text content to write",
+				},
+				"append": map[string]any{
+					"type":        "boolean",
+					"description": "Append to the file instead of overwriting it",
+				},
+			},
+			"required": []string{"path", "content"},
+		},
+	}
+}
+
+// Execute writes the requested file.
+func (t *WriteFileTool) Execute(ctx context.Context, action Action) (Output, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+		Append  bool   `json:"append"`
+	}
+	if err := decodeArgs(action, &args); err != nil {
+		return Output{}, err
+	}
+	if strings.TrimSpace(args.Path) == "" {
+		return Output{}, &ProcessErr{Type: ProcessErrFormat, Message: "write_file requires a non-empty \"path\"."}
+	}
+
+	path, err := resolvePath(t.root, args.Path)
+	if err != nil {
+		return Output{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("Cannot create directory for %q: %s", args.Path, err)}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if args.Append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("Cannot open %q: %s", args.Path, err)}
+	}
+	defer f.Close()
+
+	n, err := f.WriteString(args.Content)
+	if err != nil {
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("Cannot write %q: %s", args.Path, err)}
+	}
+
+	verb := "Wrote"
+	if args.Append {
+		verb = "Appended"
+	}
+	return Output{Stdout: fmt.Sprintf("%s %d bytes to %s", verb, n, args.Path)}, nil
+}
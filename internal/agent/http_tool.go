@@ -0,0 +1,222 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxHTTPRedirects caps the redirects http_fetch follows.
+const maxHTTPRedirects = 10
+
+// errBlockedAddress is returned when a request would reach an address that
+// isn't on the public internet.
+var errBlockedAddress = errors.New("address is not public")
+
+// errHostNotAllowed is returned when a redirect leads to a host that isn't
+// on the allowlist.
+var errHostNotAllowed = errors.New("host is not allowed")
+
+// cgnat is the shared address space carriers and some clouds use internally.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// HTTPFetchTool makes HTTP requests on behalf of the agent. It only reaches
+// public addresses, checked after DNS resolution, so it can't be pointed at
+// cloud metadata endpoints or services on the bot's own network, and only
+// the allowed hosts, if any are configured.
+type HTTPFetchTool struct {
+	client     *http.Client
+	allowHosts []string // Host name patterns; empty allows any public host
+}
+
+// NewHTTPFetchTool creates an http_fetch tool with the given request timeout
+// that may only reach hosts matching allowHosts, shell glob patterns such as
+// *.example.com. An empty allowHosts allows any public host.
+func NewHTTPFetchTool(timeout time.Duration, allowHosts []string) *HTTPFetchTool {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	t := &HTTPFetchTool{allowHosts: allowHosts}
+
+	dialer := &net.Dialer{Timeout: timeout, Control: checkDialAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // A proxy would make the request on our behalf, unchecked
+	transport.DialContext = dialer.DialContext
+	t.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxHTTPRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxHTTPRedirects)
+			}
+			if !t.hostAllowed(req.URL.Hostname()) {
+				return fmt.Errorf("redirect to %s: %w", req.URL.Hostname(), errHostNotAllowed)
+			}
+			return nil
+		},
+	}
+	return t
+}
+
+// hostAllowed reports whether the allowlist lets requests reach a host.
+func (t *HTTPFetchTool) hostAllowed(host string) bool {
+	if len(t.allowHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range t.allowHosts {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDialAddress rejects connections to loopback, private, link-local,
+// multicast and unspecified addresses. It runs after DNS resolution, for
+// every connection, so neither a host name nor a redirect can lead there.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%s: %w", address, errBlockedAddress)
+	}
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || cgnat.Contains(addr) {
+		return fmt.Errorf("%s: %w", addr, errBlockedAddress)
+	}
+	return nil
+}
+
+// Definition returns the http_fetch tool definition.
+func (t *HTTPFetchTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name:        string(ActionTypeHTTPFetch),
+		Description: "Make an HTTP request and return the status, headers and body. Use instead of curl.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"url": map[string]any{
+					"type":        "string",
+					"description": "Absolute http or https URL",
+				},
+				"method": map[string]any{
+					"type":        "string",
+					"description": "HTTP method (default GET)",
+					"enum":        []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+				},
+				"headers": map[string]any{
+					"type":                 "object",
+					"description":          "Request headers",
+					"additionalProperties": map[string]any{"type": "string"},
+				},
+				"body": map[string]any{
+					"type":        "string",
+					"description": "Request body",
+				},
+			},
+			"required": []string{"url"},
+		},
+	}
+}
+
+// Execute performs the HTTP request.
+func (t *HTTPFetchTool) Execute(ctx context.Context, action Action) (Output, error) {
+	var args struct {
+		URL     string            `json:"url"`
+		Method  string            `json:"method"`
+		Headers map[string]string `json:"headers"`
+		Body    string            `json:"body"`
+	}
+	if err := decodeArgs(action, &args); err != nil {
+		return Output{}, err
+	}
+
+	parsed, err := url.Parse(args.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Output{}, &ProcessErr{Type: ProcessErrFormat, Message: fmt.Sprintf("Invalid URL %q. Provide an absolute http or https URL.", args.URL)}
+	}
+	if !t.hostAllowed(parsed.Hostname()) {
+		return Output{}, &ProcessErr{
+			Type:    ProcessErrExecution,
+			Message: fmt.Sprintf("Requests to %s are not allowed. Allowed hosts: %s.", parsed.Hostname(), strings.Join(t.allowHosts, ", ")),
+		}
+	}
+
+	method := strings.ToUpper(args.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if args.Body != "" {
+		body = strings.NewReader(args.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, parsed.String(), body)
+	if err != nil {
+		return Output{}, &ProcessErr{Type: ProcessErrFormat, Message: fmt.Sprintf("Invalid request: %s", err)}
+	}
+	for k, v := range args.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		if errors.Is(err, errBlockedAddress) {
+			return Output{}, &ProcessErr{
+				Type:    ProcessErrExecution,
+				Message: fmt.Sprintf("Request to %s rejected: only public addresses may be reached, not loopback, private or link-local ones.", parsed.Host),
+			}
+		}
+		if errors.Is(err, errHostNotAllowed) {
+			return Output{}, &ProcessErr{
+				Type:    ProcessErrExecution,
+				Message: fmt.Sprintf("Request to %s rejected: it redirected to a host that is not allowed. Allowed hosts: %s.", parsed.Host, strings.Join(t.allowHosts, ", ")),
+			}
+		}
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return Output{TimedOut: true}, &ProcessErr{Type: ProcessErrTimeout, Message: fmt.Sprintf("Request to %s timed out.", parsed.Host)}
+		}
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("Request failed: %s", err)}
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxToolOutput+1))
+	if err != nil {
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("Failed to read response: %s", err)}
+	}
+
+	return Output{Stdout: formatHTTPResponse(resp, content)}, nil
+}
+
+// formatHTTPResponse renders the status line, a few useful headers and the body.
+func formatHTTPResponse(resp *http.Response, body []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP %s\n", resp.Status)
+
+	for _, h := range []string{"Content-Type", "Content-Length", "Location", "Retry-After"} {
+		if v := resp.Header.Get(h); v != "" {
+			fmt.Fprintf(&b, "%s: %s\n", h, v)
+		}
+	}
+
+	b.WriteString("\n")
+	if len(body) > maxToolOutput {
+		b.Write(body[:maxToolOutput])
+		b.WriteString("\n\n[... body truncated ...]")
+	} else {
+		b.Write(body)
+	}
+	return b.String()
+}
@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/j0lvera/banray/internal/db"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// maxMemoriesPerExchange caps what one exchange may add.
+	maxMemoriesPerExchange = 5
+
+	// maxMemoryLength drops proposals too long to be a single fact.
+	maxMemoryLength = 300
+)
+
+// MemoryStore keeps durable facts about each user across sessions, searched
+// with Postgres full-text search.
+type MemoryStore struct {
+	client *db.Client
+}
+
+// NewMemoryStore creates a new memory store
+func NewMemoryStore(client *db.Client) *MemoryStore {
+	return &MemoryStore{client: client}
+}
+
+// AddMemory stores a fact about a user, learned from a message. It returns
+// false if the user already had the same memory.
+func (s *MemoryStore) AddMemory(ctx context.Context, userID int64, messageID int64, content string) (bool, error) {
+	added, err := s.client.Queries.CreateMemory(ctx, dbgen.CreateMemoryParams{
+		UserID:    userID,
+		MessageID: pgtype.Int8{Int64: messageID, Valid: messageID > 0},
+		Content:   content,
+	})
+	if err != nil {
+		return false, err
+	}
+	return added > 0, nil
+}
+
+// ListMemories returns all of a user's memories, oldest first
+func (s *MemoryStore) ListMemories(ctx context.Context, userID int64) ([]*dbgen.DataMemory, error) {
+	return s.client.Queries.ListUserMemories(ctx, userID)
+}
+
+// SearchMemories returns up to limit of a user's memories sharing words with
+// text, most relevant first
+func (s *MemoryStore) SearchMemories(ctx context.Context, userID int64, text string, limit int) ([]*dbgen.DataMemory, error) {
+	return s.client.Queries.SearchUserMemories(ctx, dbgen.SearchUserMemoriesParams{
+		UserID:     userID,
+		Query:      text,
+		MaxResults: int32(limit),
+	})
+}
+
+// DeleteMemory removes one of a user's memories. It returns false if the
+// user has no memory with that ID.
+func (s *MemoryStore) DeleteMemory(ctx context.Context, userID int64, memoryID int64) (bool, error) {
+	deleted, err := s.client.Queries.DeleteUserMemory(ctx, dbgen.DeleteUserMemoryParams{
+		ID:     memoryID,
+		UserID: userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+// DeleteMemories removes all of a user's memories and returns how many there were
+func (s *MemoryStore) DeleteMemories(ctx context.Context, userID int64) (int, error) {
+	deleted, err := s.client.Queries.DeleteUserMemories(ctx, userID)
+	return int(deleted), err
+}
+
+// MemoryPrompt returns the system prompt section listing what is remembered
+// about the user, or an empty string when nothing is.
+func MemoryPrompt(memories []*dbgen.DataMemory) string {
+	if len(memories) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\n## What You Remember About the User\n")
+	for _, memory := range memories {
+		fmt.Fprintf(&b, "\n- %s", memory.Content)
+	}
+	return b.String()
+}
+
+// memoryExtractionPrompt asks for the durable facts of an exchange.
+const memoryExtractionPrompt = `You maintain the long-term memory of a chat assistant about one user. From the latest exchange, extract durable facts worth knowing in future conversations: the user's name, preferences, projects, tools, the people and places in their life, and standing instructions for the assistant.
+
+Skip anything temporary, trivial, only about this one task, or already among the known facts. Write each fact as one short sentence in the third person ("The user ...").
+
+Reply with a JSON array of strings only, or [] when there is nothing new to remember.`
+
+// ExtractMemories asks model for the facts worth remembering from one
+// exchange. known are memories the user already has, so they aren't
+// proposed again.
+func ExtractMemories(
+	ctx context.Context,
+	querier Querier,
+	model string,
+	pricing Pricing,
+	known []*dbgen.DataMemory,
+	userText string,
+	response string,
+) ([]string, RequestUsage, error) {
+	var exchange strings.Builder
+	if len(known) > 0 {
+		exchange.WriteString("Known facts:\n")
+		for _, memory := range known {
+			fmt.Fprintf(&exchange, "- %s\n", memory.Content)
+		}
+		exchange.WriteString("\n")
+	}
+	fmt.Fprintf(&exchange, "User: %s\n\nAssistant: %s", userText, response)
+
+	prompt := []Message{
+		{Role: RoleSystem, Content: memoryExtractionPrompt},
+		{Role: RoleUser, Content: exchange.String()},
+	}
+
+	var opts []QueryOption
+	if model != "" {
+		opts = append(opts, WithModel(model))
+	}
+	result, err := querier.Query(ctx, prompt, opts...)
+	if err != nil {
+		return nil, RequestUsage{}, fmt.Errorf("memory extraction failed: %w", err)
+	}
+
+	if result.Model != "" {
+		model = result.Model
+	}
+	usage := RequestUsage{
+		Purpose:      PurposeMemory,
+		Model:        model,
+		InputTokens:  result.InputTokens,
+		OutputTokens: result.OutputTokens,
+		TotalTokens:  result.TotalTokens,
+		CostUSD:      pricing.QueryCost(model, result),
+	}
+
+	facts, err := parseMemories(result.Content)
+	if err != nil {
+		return nil, usage, fmt.Errorf("memory extraction failed: %w", err)
+	}
+	return facts, usage, nil
+}
+
+// parseMemories reads the JSON array of facts out of a response, which
+// models like to wrap in a code block.
+func parseMemories(content string) ([]string, error) {
+	start := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in response")
+	}
+
+	var proposed []string
+	if err := json.Unmarshal([]byte(content[start:end+1]), &proposed); err != nil {
+		return nil, fmt.Errorf("invalid JSON array in response: %w", err)
+	}
+
+	var facts []string
+	for _, fact := range proposed {
+		fact = strings.TrimSpace(fact)
+		if fact == "" || len(fact) > maxMemoryLength {
+			continue
+		}
+		facts = append(facts, fact)
+		if len(facts) == maxMemoriesPerExchange {
+			break
+		}
+	}
+	return facts, nil
+}
@@ -1,6 +1,9 @@
 package agent
 
-import "fmt"
+import (
+	"encoding/base64"
+	"fmt"
+)
 
 // Role represents the sender of a message in the conversation.
 type Role string
@@ -9,15 +12,58 @@ const (
 	RoleSystem    Role = "system"
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
 )
 
 // Message represents a single message in the conversation history.
 type Message struct {
+	// ID is the ID of the stored message, or zero for one that isn't stored.
+	ID      int64
 	Role    Role
 	Content string
+
+	// Images are sent with the text of a user message, as parts of the
+	// same message.
+	Images []Image
+
+	// ToolCalls holds the tool calls requested by an assistant message.
+	ToolCalls []ToolCall
+	// ToolCallID links a tool message to the call it answers.
+	ToolCallID string
+}
+
+// ToolCall is a structured request from the model to invoke a tool.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string // Raw JSON arguments
+}
+
+// Image is an image part of a message.
+type Image struct {
+	MIMEType string // image/jpeg, image/png, image/gif or image/webp
+	Data     []byte // Empty until loaded when replaying history
+
+	// Where the image came from, to store it and load it again
+	FileID string // Telegram file ID
+	Width  int
+	Height int
+}
+
+// Loaded reports whether the image's data is present.
+func (i Image) Loaded() bool {
+	return len(i.Data) > 0
+}
+
+// DataURL returns the image as a base64 data URL.
+func (i Image) DataURL() string {
+	return "data:" + i.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
 }
 
 // String returns a string representation of the message for debugging.
 func (m Message) String() string {
+	if len(m.Images) > 0 {
+		return fmt.Sprintf("%s: [%d images] %s", m.Role, len(m.Images), m.Content)
+	}
 	return fmt.Sprintf("%s: %s", m.Role, m.Content)
 }
@@ -1,7 +1,13 @@
 package agent
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
 	"github.com/j0lvera/banray/internal/config"
+	"github.com/rs/zerolog"
 	"go.uber.org/fx"
 )
 
@@ -10,6 +16,7 @@ type Params struct {
 	fx.In
 
 	Config *config.Config
+	Logger zerolog.Logger
 }
 
 // Result of creating a Querier
@@ -19,9 +26,26 @@ type Result struct {
 	Querier Querier
 }
 
-// New creates a new Querier based on configuration
+// New creates a new Querier based on configuration. Queries go down the
+// [[models]] chain of config.toml, or to OPENROUTER_MODEL when there is none,
+// with retries and circuit breaking.
 func New(p Params) (Result, error) {
-	querier, err := NewOpenAIQuerier(p.Config.APIKey, p.Config.BaseURL, p.Config.Model)
+	endpoints := p.Config.ModelChain
+	if len(endpoints) == 0 {
+		endpoints = []config.ModelEndpoint{{Name: p.Config.Model}}
+	}
+
+	models := make([]FallbackModel, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		querier, err := newEndpointQuerier(p.Config, endpoint)
+		if err != nil {
+			return Result{}, fmt.Errorf("model %q: %w", endpoint.Name, err)
+		}
+		models = append(models, FallbackModel{Name: endpoint.Name, Querier: querier, Auxiliary: endpoint.Auxiliary})
+	}
+
+	logger := p.Logger
+	querier, err := NewFallbackQuerier(models, p.Config.Retry, &logger)
 	if err != nil {
 		return Result{}, err
 	}
@@ -31,12 +55,129 @@ func New(p Params) (Result, error) {
 	}, nil
 }
 
-// Module provides the agent Querier
+// newEndpointQuerier creates the querier for one model of the chain, using
+// its provider's API.
+func newEndpointQuerier(cfg *config.Config, endpoint config.ModelEndpoint) (Querier, error) {
+	apiKey := ""
+	if endpoint.APIKeyEnv != "" {
+		apiKey = os.Getenv(endpoint.APIKeyEnv)
+	}
+
+	switch endpoint.Provider {
+	case config.ProviderAnthropic:
+		if endpoint.APIKeyEnv == "" {
+			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		return NewAnthropicQuerier(apiKey, endpoint.BaseURL, endpoint.Name)
+	case config.ProviderOllama:
+		return NewOllamaQuerier(endpoint.BaseURL, endpoint.Name), nil
+	default:
+		baseURL := endpoint.BaseURL
+		if baseURL == "" {
+			baseURL = cfg.BaseURL
+		}
+		if endpoint.APIKeyEnv == "" {
+			apiKey = cfg.APIKey
+		}
+		return NewOpenAIQuerier(apiKey, baseURL, endpoint.Name)
+	}
+}
+
+// NewExecutors creates the per-session executor pool selected by EXECUTOR.
+func NewExecutors(cfg *config.Config, log zerolog.Logger) (*ExecutorPool, error) {
+	validator, err := newCommandValidator(cfg.Commands)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Executor {
+	case config.ExecutorBash:
+		if cfg.PersistentShell {
+			return NewExecutorPool(func(ExecutorKey) (Executor, error) {
+				return NewPersistentShellExecutor(PersistentShellConfig{
+					Timeout:   cfg.CommandTimeout,
+					WorkDir:   cfg.WorkingDir,
+					Validator: validator,
+				}), nil
+			}), nil
+		}
+		executor := NewBashExecutor(WithTimeout(cfg.CommandTimeout), WithWorkingDir(cfg.WorkingDir), WithValidator(validator))
+		return NewExecutorPool(func(ExecutorKey) (Executor, error) {
+			return executor, nil
+		}), nil
+	case config.ExecutorSandbox:
+		dir := cfg.SandboxDir
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "banray-sandbox")
+		}
+		if tools := configuredTools(cfg); len(tools) > 0 {
+			log.Warn().Strs("tools", tools).Msg("Tools run outside the sandbox and are disabled with EXECUTOR=sandbox; only bash is offered")
+		}
+		return NewExecutorPool(func(key ExecutorKey) (Executor, error) {
+			sandbox, err := NewSandboxExecutor(SandboxConfig{
+				ScratchDir: filepath.Join(dir, fmt.Sprintf("session-%d-user-%d", key.SessionID, key.UserID)),
+				Timeout:    cfg.CommandTimeout,
+				Network:    cfg.SandboxNetwork,
+				MemoryMB:   cfg.SandboxMemoryMB,
+				MaxPids:    cfg.SandboxMaxPids,
+				CPUSeconds: cfg.SandboxCPUSeconds,
+				Validator:  validator,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if !cfg.PersistentShell {
+				return sandbox, nil
+			}
+			return NewPersistentShellExecutor(PersistentShellConfig{
+				Timeout:   cfg.CommandTimeout,
+				WorkDir:   sandbox.WorkDir(),
+				Start:     sandbox.ShellCommand,
+				Cleanup:   sandbox.Close,
+				Validator: validator,
+			}), nil
+		}), nil
+	default:
+		return nil, fmt.Errorf("invalid executor %q: must be %s or %s", cfg.Executor, config.ExecutorBash, config.ExecutorSandbox)
+	}
+}
+
+// configuredTools returns the extra tools AGENT_TOOLS and the profiles ask for.
+func configuredTools(cfg *config.Config) []string {
+	var tools []string
+	for _, name := range cfg.ProfileNames() {
+		profile, _ := cfg.Profile(name)
+		for _, tool := range profile.Tools {
+			if !slices.Contains(tools, tool) {
+				tools = append(tools, tool)
+			}
+		}
+	}
+	slices.Sort(tools)
+	return tools
+}
+
+// newCommandValidator builds the validator selected in config.toml.
+func newCommandValidator(cfg config.Commands) (CommandValidator, error) {
+	if cfg.Validator != config.ValidatorPolicy {
+		return NewDefaultBlocklistValidator(), nil
+	}
+	return NewPolicyValidator(CommandPolicy{
+		Allow:         cfg.Allow,
+		Deny:          cfg.Deny,
+		DenyRedirects: cfg.DenyRedirects,
+		DenySubshells: cfg.DenySubshells,
+	})
+}
+
+// Module provides the agent Querier, executor pool and context manager
 func Module() fx.Option {
 	return fx.Module(
 		"agent",
 		fx.Provide(
 			New,
+			NewExecutors,
+			NewContextManager,
 		),
 	)
 }
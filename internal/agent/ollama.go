@@ -0,0 +1,240 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OllamaBaseURL is the default address of a local Ollama server.
+const OllamaBaseURL = "http://localhost:11434"
+
+// OllamaQuerier implements Querier using Ollama's native chat API, for models
+// served locally. Local models cost nothing unless the price table says
+// otherwise.
+type OllamaQuerier struct {
+	client  *http.Client
+	baseURL string
+	model   string
+}
+
+// NewOllamaQuerier creates a querier for the Ollama server at baseURL.
+func NewOllamaQuerier(baseURL, model string) *OllamaQuerier {
+	if baseURL == "" {
+		baseURL = OllamaBaseURL
+	}
+	return &OllamaQuerier{
+		client:  http.DefaultClient,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+	}
+}
+
+// Query sends messages to the model and returns the response with token usage.
+func (q *OllamaQuerier) Query(ctx context.Context, messages []Message, opts ...QueryOption) (QueryResult, error) {
+	return q.generate(ctx, messages, nil, opts)
+}
+
+// QueryStream sends messages to the model and streams the response chunks to onChunk.
+func (q *OllamaQuerier) QueryStream(ctx context.Context, messages []Message, onChunk func(chunk string) error, opts ...QueryOption) (QueryResult, error) {
+	return q.generate(ctx, messages, onChunk, opts)
+}
+
+// ollamaRequest is the body of a chat request.
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+	Stream   bool            `json:"stream"`
+}
+
+// ollamaOptions are the model parameters of a chat request.
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    [][]byte         `json:"images,omitempty"` // Encoded as base64 strings
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+// ollamaResponse is a chat response, or one line of a streamed one.
+type ollamaResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// generate performs the request shared by Query and QueryStream. A nil
+// onChunk makes a plain request.
+func (q *OllamaQuerier) generate(ctx context.Context, messages []Message, onChunk func(chunk string) error, opts []QueryOption) (QueryResult, error) {
+	var options QueryOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	model := q.model
+	if options.Model != "" {
+		model = options.Model
+	}
+
+	request := ollamaRequest{
+		Model:    model,
+		Messages: ollamaMessages(messages),
+		Stream:   onChunk != nil,
+	}
+	if options.Temperature != nil {
+		request.Options = &ollamaOptions{Temperature: options.Temperature}
+	}
+	for _, tool := range options.Tools {
+		var t ollamaTool
+		t.Type = "function"
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.Parameters
+		request.Tools = append(request.Tools, t)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return QueryResult{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		message := strings.TrimSpace(string(data))
+		var apiErr ollamaResponse
+		if err := json.Unmarshal(data, &apiErr); err == nil && apiErr.Error != "" {
+			message = apiErr.Error
+		}
+		return QueryResult{}, &QueryError{StatusCode: resp.StatusCode, Err: fmt.Errorf("ollama: %s", message)}
+	}
+
+	// Streamed responses are one JSON object per line; the last one has the counts
+	var content strings.Builder
+	var final ollamaResponse
+	var calls []ollamaToolCall
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return QueryResult{}, fmt.Errorf("failed to read response: %w", err)
+		}
+		if chunk.Error != "" {
+			return QueryResult{}, fmt.Errorf("ollama: %s", chunk.Error)
+		}
+
+		content.WriteString(chunk.Message.Content)
+		calls = append(calls, chunk.Message.ToolCalls...)
+		if onChunk != nil && chunk.Message.Content != "" {
+			if err := onChunk(chunk.Message.Content); err != nil {
+				return QueryResult{}, err
+			}
+		}
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return QueryResult{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	result := QueryResult{
+		Content:      content.String(),
+		Model:        model,
+		InputTokens:  final.PromptEvalCount,
+		OutputTokens: final.EvalCount,
+		TotalTokens:  final.PromptEvalCount + final.EvalCount,
+	}
+
+	// Ollama doesn't identify tool calls, so number them for the tool messages
+	for i, call := range calls {
+		arguments := string(call.Function.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      call.Function.Name,
+			Arguments: arguments,
+		})
+	}
+
+	return result, nil
+}
+
+// ollamaMessages converts messages to Ollama's chat format.
+func ollamaMessages(messages []Message) []ollamaMessage {
+	result := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem, RoleTool:
+			result = append(result, ollamaMessage{Role: string(msg.Role), Content: msg.Content})
+		case RoleUser:
+			converted := ollamaMessage{Role: "user", Content: msg.Content}
+			for _, image := range msg.Images {
+				if image.Loaded() {
+					converted.Images = append(converted.Images, image.Data)
+				}
+			}
+			result = append(result, converted)
+		case RoleAssistant:
+			converted := ollamaMessage{Role: "assistant", Content: msg.Content}
+			for _, call := range msg.ToolCalls {
+				var c ollamaToolCall
+				c.Function.Name = call.Name
+				c.Function.Arguments = json.RawMessage(call.Arguments)
+				if !json.Valid(c.Function.Arguments) {
+					c.Function.Arguments = json.RawMessage("{}")
+				}
+				converted.ToolCalls = append(converted.ToolCalls, c)
+			}
+			result = append(result, converted)
+		}
+	}
+	return result
+}
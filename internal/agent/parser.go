@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -8,7 +9,7 @@ import (
 
 // Parser extracts executable actions from LLM responses.
 type Parser interface {
-	ParseAction(response string) (Action, error)
+	ParseAction(result QueryResult) (Action, error)
 }
 
 // commandRegex matches ```bash\n...\n``` code blocks
@@ -23,8 +24,8 @@ func NewBashParser() *BashParser {
 }
 
 // ParseAction extracts a single bash command from the response.
-func (p *BashParser) ParseAction(response string) (Action, error) {
-	matches := commandRegex.FindAllStringSubmatch(response, -1)
+func (p *BashParser) ParseAction(result QueryResult) (Action, error) {
+	matches := commandRegex.FindAllStringSubmatch(result.Content, -1)
 
 	if len(matches) == 0 {
 		return Action{}, &ProcessErr{
@@ -53,3 +54,127 @@ func (p *BashParser) ParseAction(response string) (Action, error) {
 		Command: command,
 	}, nil
 }
+
+// BashToolDefinition is the native tool offered to models in tool calling mode.
+var BashToolDefinition = ToolDefinition{
+	Name:        string(ActionTypeBash),
+	Description: "Run a single bash command and return its output. Call it once per response.",
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"command": map[string]any{
+				"type":        "string",
+				"description": "The bash command to execute",
+			},
+		},
+		"required": []string{"command"},
+	},
+}
+
+// ToolCallParser extracts actions from native tool calls. Responses without a
+// tool call fall back to markdown bash blocks, and plain text is treated as
+// the final answer.
+type ToolCallParser struct {
+	fallback *BashParser
+	tools    map[string]bool
+	names    []string
+}
+
+// NewToolCallParser creates a parser accepting calls to the given tools.
+// With no tools, only the bash tool is accepted.
+func NewToolCallParser(tools ...ToolDefinition) *ToolCallParser {
+	if len(tools) == 0 {
+		tools = []ToolDefinition{BashToolDefinition}
+	}
+
+	p := &ToolCallParser{
+		fallback: NewBashParser(),
+		tools:    make(map[string]bool, len(tools)),
+	}
+	for _, tool := range tools {
+		p.tools[tool.Name] = true
+		p.names = append(p.names, tool.Name)
+	}
+	return p
+}
+
+// ParseAction extracts a single action from the response's tool calls.
+func (p *ToolCallParser) ParseAction(result QueryResult) (Action, error) {
+	if len(result.ToolCalls) == 0 {
+		// Some models still answer with a code block even when tools are offered
+		if commandRegex.MatchString(result.Content) {
+			return p.fallback.ParseAction(result)
+		}
+
+		content := strings.TrimSpace(result.Content)
+		if content == "" {
+			return Action{}, &ProcessErr{
+				Type:    ProcessErrFormat,
+				Message: "Empty response. Call a tool to take an action, or reply with your final answer.",
+			}
+		}
+
+		// No tool call means the model answered the user directly
+		return Action{}, &TerminatingErr{
+			Reason: ReasonComplete,
+			Output: content,
+		}
+	}
+
+	if len(result.ToolCalls) > 1 {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Found %d tool calls, expected exactly one. Please call a single tool per response.", len(result.ToolCalls)),
+		}
+	}
+
+	call := result.ToolCalls[0]
+	if !p.tools[call.Name] {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Unknown tool %q. Available tools: %s.", call.Name, strings.Join(p.names, ", ")),
+		}
+	}
+
+	args := json.RawMessage(call.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Invalid arguments for %q: not valid JSON. Provide a JSON object matching the tool schema.", call.Name),
+		}
+	}
+
+	action := Action{
+		Type:       ActionType(call.Name),
+		Args:       args,
+		ToolCallID: call.ID,
+	}
+
+	if action.Type != ActionTypeBash {
+		action.Command = fmt.Sprintf("%s %s", call.Name, args)
+		return action, nil
+	}
+
+	var bashArgs struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(args, &bashArgs); err != nil {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Invalid arguments for %q: %s. Provide a JSON object with a \"command\" string.", call.Name, err),
+		}
+	}
+
+	action.Command = strings.TrimSpace(bashArgs.Command)
+	if action.Command == "" {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: "Empty command in bash tool call. Please provide a valid command.",
+		}
+	}
+
+	return action, nil
+}
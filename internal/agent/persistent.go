@@ -0,0 +1,365 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxShellOutput caps the output captured per stream for one command.
+	maxShellOutput = 1 << 20
+
+	// interruptGrace is how long a timed out command gets to stop after
+	// SIGINT before the whole shell is killed.
+	interruptGrace = 2 * time.Second
+)
+
+// PersistentShellConfig holds settings for the persistent shell executor.
+type PersistentShellConfig struct {
+	Timeout time.Duration    // Timeout for each command
+	WorkDir string           // Directory the shell starts in, reported through WorkDir
+	Start   func() *exec.Cmd // Builds an unstarted bash that reads commands from stdin (nil = local bash in WorkDir)
+	Cleanup func() error     // Runs on Close after the shell is killed
+
+	Validator CommandValidator // Checks commands before they run (nil = default blocklist)
+}
+
+// PersistentShellExecutor keeps one long-lived bash process, so the working
+// directory, variables and activated environments carry over between
+// commands. Each command is framed with random sentinels that carry its exit
+// code. A command that times out is interrupted with SIGINT; if it doesn't
+// stop, the shell is killed and a fresh one is started on the next command.
+type PersistentShellExecutor struct {
+	config    PersistentShellConfig
+	validator CommandValidator
+
+	mu     sync.Mutex // Serializes commands; the shell runs one at a time
+	shell  *exec.Cmd
+	stdin  io.WriteCloser
+	stdout <-chan []byte
+	stderr <-chan []byte
+}
+
+// NewPersistentShellExecutor creates a persistent shell executor. The shell
+// starts lazily on the first command.
+func NewPersistentShellExecutor(config PersistentShellConfig) *PersistentShellExecutor {
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.Start == nil {
+		workDir := config.WorkDir
+		config.Start = func() *exec.Cmd {
+			cmd := exec.Command("bash", "--noprofile", "--norc")
+			cmd.Dir = workDir
+			return cmd
+		}
+	}
+
+	validator := config.Validator
+	if validator == nil {
+		validator = NewDefaultBlocklistValidator()
+	}
+
+	return &PersistentShellExecutor{
+		config:    config,
+		validator: validator,
+	}
+}
+
+// WorkDir returns the directory the shell starts in.
+func (e *PersistentShellExecutor) WorkDir() string {
+	return e.config.WorkDir
+}
+
+// Execute runs a bash command in the shell and returns the output.
+func (e *PersistentShellExecutor) Execute(ctx context.Context, action Action) (Output, error) {
+	if action.Type != ActionTypeBash {
+		return Output{}, fmt.Errorf("unsupported action type: %s", action.Type)
+	}
+
+	if e.validator != nil {
+		if err := e.validator.Validate(action.Command); err != nil {
+			return Output{}, err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.shell == nil {
+		if err := e.start(); err != nil {
+			return Output{}, fmt.Errorf("failed to start shell: %w", err)
+		}
+	}
+
+	marker, err := newShellMarker()
+	if err != nil {
+		return Output{}, err
+	}
+
+	// eval keeps syntax errors from swallowing the framing, and stdin is
+	// detached so the command can't read the lines that follow it
+	script := fmt.Sprintf("eval %s </dev/null\nprintf '%%s %%d\\n' %s \"$?\"\nprintf '%%s\\n' %s >&2\n",
+		shellQuote(action.Command), marker, marker)
+	if _, err := io.WriteString(e.stdin, script); err != nil {
+		e.kill()
+		return Output{}, fmt.Errorf("failed to write to shell: %w", err)
+	}
+
+	return e.collect(ctx, []byte(marker))
+}
+
+// Close kills the shell and runs the cleanup hook.
+func (e *PersistentShellExecutor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.kill()
+	if e.config.Cleanup != nil {
+		return e.config.Cleanup()
+	}
+	return nil
+}
+
+// collect reads both streams until each carries the marker, interrupting
+// the command when it runs past the timeout.
+func (e *PersistentShellExecutor) collect(ctx context.Context, marker []byte) (Output, error) {
+	stdout := &shellStream{marker: marker, exitCode: true}
+	stderr := &shellStream{marker: marker}
+	output := func() Output {
+		return Output{Stdout: stdout.String(), Stderr: stderr.String()}
+	}
+
+	timeout := time.NewTimer(e.config.Timeout)
+	defer timeout.Stop()
+	var grace <-chan time.Time
+	stdoutCh, stderrCh := e.stdout, e.stderr
+
+	for !stdout.done || !stderr.done {
+		select {
+		case chunk, ok := <-stdoutCh:
+			if !ok {
+				return e.exited(output())
+			}
+			stdout.Write(chunk)
+		case chunk, ok := <-stderrCh:
+			if !ok {
+				return e.exited(output())
+			}
+			stderr.Write(chunk)
+		case <-timeout.C:
+			// Interrupt the foreground command; the shell itself traps SIGINT
+			syscall.Kill(-e.shell.Process.Pid, syscall.SIGINT)
+			grace = time.After(interruptGrace)
+		case <-grace:
+			e.kill()
+			out := output()
+			out.TimedOut = true
+			return out, &ProcessErr{
+				Type: ProcessErrTimeout,
+				Message: fmt.Sprintf("Command timed out after %s and did not stop when interrupted, so the shell was restarted. The working directory and variables were reset. Partial output:\n%s",
+					e.config.Timeout, out.String()),
+			}
+		case <-ctx.Done():
+			e.kill()
+			return output(), ctx.Err()
+		}
+	}
+
+	out := output()
+	out.ExitCode = stdout.code
+
+	if grace != nil {
+		out.TimedOut = true
+		return out, &ProcessErr{
+			Type:    ProcessErrTimeout,
+			Message: fmt.Sprintf("Command timed out after %s and was interrupted. Partial output:\n%s", e.config.Timeout, out.String()),
+		}
+	}
+
+	if out.ExitCode != 0 {
+		return out, &ProcessErr{
+			Type:    ProcessErrExecution,
+			Message: fmt.Sprintf("Command failed: exit status %d\nOutput:\n%s", out.ExitCode, out.String()),
+		}
+	}
+
+	return out, nil
+}
+
+// exited handles the shell ending mid-command, e.g. after `exit`.
+func (e *PersistentShellExecutor) exited(out Output) (Output, error) {
+	if state := e.kill(); state != nil {
+		out.ExitCode = state.ExitCode()
+	}
+	return out, &ProcessErr{
+		Type: ProcessErrExecution,
+		Message: fmt.Sprintf("The shell exited with status %d. A new shell will be started, so the working directory and variables were reset.\nOutput:\n%s",
+			out.ExitCode, out.String()),
+	}
+}
+
+// start launches the shell in its own process group, so a timeout can
+// interrupt everything the current command spawned.
+func (e *PersistentShellExecutor) start() error {
+	cmd := e.config.Start()
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	e.shell = cmd
+	e.stdin = stdin
+	e.stdout = readShellPipe(stdout)
+	e.stderr = readShellPipe(stderr)
+
+	// A handled SIGINT is reset to the default in children, so commands can
+	// be interrupted while the shell survives
+	if _, err := io.WriteString(stdin, "trap 'true' INT\n"); err != nil {
+		e.kill()
+		return err
+	}
+	return nil
+}
+
+// kill stops the shell and everything in its process group, returning the
+// shell's exit state if it had one.
+func (e *PersistentShellExecutor) kill() *os.ProcessState {
+	if e.shell == nil {
+		return nil
+	}
+
+	syscall.Kill(-e.shell.Process.Pid, syscall.SIGKILL)
+	e.stdin.Close()
+	e.shell.Wait()
+	state := e.shell.ProcessState
+
+	e.shell = nil
+	e.stdin = nil
+	e.stdout = nil
+	e.stderr = nil
+	return state
+}
+
+// readShellPipe forwards chunks read from r until it fails.
+func readShellPipe(r io.Reader) <-chan []byte {
+	ch := make(chan []byte, 16)
+	go func() {
+		defer close(ch)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				ch <- bytes.Clone(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// shellStream accumulates one stream of a framed command until its marker.
+type shellStream struct {
+	marker   []byte
+	exitCode bool // The marker is followed by the exit code and a newline
+
+	out       bytes.Buffer
+	window    []byte // Unflushed bytes that may hold a partial marker
+	truncated bool
+	found     bool
+	done      bool
+	code      int
+}
+
+// Write appends a chunk, looking for the marker across chunk boundaries.
+func (s *shellStream) Write(p []byte) {
+	if s.done {
+		return
+	}
+	s.window = append(s.window, p...)
+
+	if !s.found {
+		i := bytes.Index(s.window, s.marker)
+		if i < 0 {
+			// Keep just enough to match a marker split across chunks
+			if keep := len(s.marker) - 1; len(s.window) > keep {
+				s.flush(s.window[:len(s.window)-keep])
+				s.window = append([]byte(nil), s.window[len(s.window)-keep:]...)
+			}
+			return
+		}
+		s.flush(s.window[:i])
+		s.window = append([]byte(nil), s.window[i+len(s.marker):]...)
+		s.found = true
+	}
+
+	if !s.exitCode {
+		s.done = true
+		return
+	}
+	if i := bytes.IndexByte(s.window, '\n'); i >= 0 {
+		s.code, _ = strconv.Atoi(strings.TrimSpace(string(s.window[:i])))
+		s.done = true
+	}
+}
+
+// flush moves output into the buffer, dropping anything past the cap.
+func (s *shellStream) flush(p []byte) {
+	if room := maxShellOutput - s.out.Len(); len(p) > room {
+		p = p[:max(room, 0)]
+		s.truncated = true
+	}
+	s.out.Write(p)
+}
+
+// String returns the captured output, including any unflushed bytes.
+func (s *shellStream) String() string {
+	out := s.out.String()
+	if !s.found {
+		out += string(s.window)
+	}
+	if s.truncated {
+		out += "\n[output truncated]"
+	}
+	return out
+}
+
+// newShellMarker returns a sentinel that can't plausibly occur in output.
+func newShellMarker() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate marker: %w", err)
+	}
+	return "__BANRAY_" + hex.EncodeToString(b) + "__", nil
+}
+
+// shellQuote single-quotes s for bash.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,493 @@
+package agent
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// CommandPolicy lists what commands may do. Names and paths are shell glob
+// patterns; a path pattern also covers everything below it.
+type CommandPolicy struct {
+	Allow         []string // Programs that may run; empty allows anything not denied
+	Deny          []string // Programs that may never run
+	DenyRedirects []string // Paths output may not be redirected to
+	DenySubshells bool     // Reject (...), $(...), `...` and <(...)
+}
+
+// safeBuiltins are shell builtins that can't run other programs, so they are
+// allowed even when they're missing from the allowlist.
+var safeBuiltins = map[string]bool{
+	":": true, "[": true, "break": true, "cd": true, "continue": true,
+	"declare": true, "echo": true, "exit": true, "export": true, "false": true,
+	"local": true, "printf": true, "pwd": true, "read": true, "return": true,
+	"set": true, "shift": true, "test": true, "true": true, "type": true,
+	"unset": true, "wait": true,
+}
+
+// wrappers run the program named in their arguments, so it is checked too.
+// busybox and toybox run the applet named in their first argument.
+var wrappers = map[string]bool{
+	"builtin": true, "busybox": true, "command": true, "env": true, "exec": true,
+	"nice": true, "nohup": true, "setsid": true, "stdbuf": true, "sudo": true,
+	"time": true, "timeout": true, "toybox": true, "xargs": true,
+}
+
+// wrapperValueFlags are the short options of each wrapper that take a value
+// in the next argument, such as sudo -u root.
+var wrapperValueFlags = map[string]string{
+	"env":     "uCS",
+	"nice":    "n",
+	"stdbuf":  "ioe",
+	"sudo":    "CDghprTUu",
+	"timeout": "ks",
+	"xargs":   "aEdIiLlnPs",
+}
+
+// shells run the script passed with -c, so it is parsed and checked too.
+var shells = map[string]bool{
+	"ash": true, "bash": true, "dash": true, "sh": true, "zsh": true,
+}
+
+// sourcers run the script file named in their first argument.
+var sourcers = map[string]bool{".": true, "source": true}
+
+// stdinScript matches script paths that read the script from standard input,
+// which may be a pipe, a heredoc or a herestring.
+var stdinScript = regexp.MustCompile(`^(-|/dev/stdin|/dev/fd/[0-9]+|/proc/(self|thread-self|[0-9]+)/fd/[0-9]+)$`)
+
+// patternChars are the characters the shell expands in unquoted words, as
+// globs or braces.
+const patternChars = "*?[]{}"
+
+// wrapperValue matches option values such as nice's priority or timeout's
+// duration, which sit between a wrapper and the wrapped program.
+var wrapperValue = regexp.MustCompile(`^[+-]?[0-9.]+[smhd]?$`)
+
+// descriptor matches the targets of >& that duplicate or close a file
+// descriptor rather than name a file.
+var descriptor = regexp.MustCompile(`^([0-9]+-?|-)$`)
+
+// PolicyValidator parses commands with a shell parser and checks every
+// program they run, every file they write through a redirect and every
+// subshell against a CommandPolicy. Unlike BlocklistValidator it sees
+// through quoting, and doesn't trip over arguments such as
+// `grep halt log.txt`. Both of these run rm, and are checked as such:
+//
+//	r''m -rf /tmp/x
+//	\rm -rf /tmp/x
+type PolicyValidator struct {
+	policy CommandPolicy
+}
+
+// NewPolicyValidator creates a validator for the policy.
+func NewPolicyValidator(policy CommandPolicy) (*PolicyValidator, error) {
+	for _, patterns := range [][]string{policy.Allow, policy.Deny, policy.DenyRedirects} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+	}
+	return &PolicyValidator{policy: policy}, nil
+}
+
+// Validate parses the command and checks it against the policy.
+func (v *PolicyValidator) Validate(command string) error {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Command rejected: it could not be parsed as a shell command (%s). Fix the syntax and try again.", err),
+		}
+	}
+
+	// Calls to functions defined in the command itself are allowed; their
+	// bodies are checked like any other code
+	functions := map[string]bool{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if decl, ok := node.(*syntax.FuncDecl); ok {
+			functions[decl.Name.Value] = true
+		}
+		return true
+	})
+
+	var rejection error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if rejection != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			rejection = v.checkCall(command, n, functions)
+		case *syntax.Redirect:
+			rejection = v.checkRedirect(command, n)
+		case *syntax.Subshell, *syntax.CmdSubst, *syntax.ProcSubst:
+			if v.policy.DenySubshells {
+				rejection = reject("subshells are not allowed: %s", snippet(command, n))
+			}
+		}
+		return rejection == nil
+	})
+	return rejection
+}
+
+// checkCall checks the program a simple command runs, following wrappers
+// such as sudo or xargs and scripts passed to bash -c.
+func (v *PolicyValidator) checkCall(command string, call *syntax.CallExpr, functions map[string]bool) error {
+	args := call.Args
+	xargs := false // Arguments may come from xargs' input
+	for len(args) > 0 {
+		name, ok := literalName(args[0])
+		if !ok && isTestBracket(args[0]) {
+			name, ok = "[", true
+		}
+		if !ok {
+			return reject("the program name %s is computed at runtime, so it can't be checked. Use a literal program name", snippet(command, args[0]))
+		}
+
+		if !functions[name] {
+			if err := v.checkProgram(name); err != nil {
+				return err
+			}
+		}
+
+		base := path.Base(name)
+		switch {
+		case shells[base]:
+			return v.checkShellScript(command, args[1:], xargs)
+		case sourcers[base] && !functions[name]:
+			if len(args) < 2 && xargs {
+				return reject("%s would take its script from xargs' input, which can't be checked", base)
+			}
+			if len(args) > 1 {
+				if script, ok := literalName(args[1]); !ok || stdinScript.MatchString(script) {
+					return reject("%s reads its script from %s, which can't be checked. Run the commands directly", base, snippet(command, args[1]))
+				}
+			}
+			return nil
+		case base == "eval":
+			return v.checkScript(command, args[1:])
+		case base == "find":
+			args = findExec(args[1:])
+		case base == "env" && envSplitString(args[1:]) != nil:
+			// env -S splits its value into a command line
+			return v.checkScript(command, envSplitString(args[1:]))
+		case wrappers[base]:
+			args = wrappedProgram(base, args[1:])
+			// xargs runs echo when it's given no program, but other
+			// wrappers under xargs would run whatever its input names
+			if len(args) == 0 && xargs && base != "xargs" {
+				return reject("`%s` would run a program named by xargs' input, which can't be checked", base)
+			}
+			xargs = xargs || base == "xargs"
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkProgram checks a program name against the allow and deny lists.
+func (v *PolicyValidator) checkProgram(name string) error {
+	base := path.Base(name)
+	if pattern, ok := matchName(v.policy.Deny, base); ok {
+		return reject("`%s` is not allowed (denied by pattern %q)", base, pattern)
+	}
+	if len(v.policy.Allow) == 0 || safeBuiltins[base] {
+		return nil
+	}
+	if _, ok := matchName(v.policy.Allow, base); !ok {
+		return reject("`%s` is not on the allowlist. Allowed programs: %s", base, strings.Join(v.policy.Allow, ", "))
+	}
+	return nil
+}
+
+// checkShellScript checks the script given to a shell with -c. Running a
+// script file can't be checked, so it's only allowed if the shell is. A
+// script read from standard input, which may be a pipe, a heredoc or a
+// herestring, is rejected, as is one xargs would pass.
+func (v *PolicyValidator) checkShellScript(command string, args []*syntax.Word, xargs bool) error {
+	stdin := reject("the shell reads its script from standard input, which can't be checked. Pass the script with -c or run the commands directly")
+	for i := 0; i < len(args); i++ {
+		flag, ok := literal(args[i])
+		if !ok {
+			return reject("the shell argument %s is computed at runtime, so it can't be checked", snippet(command, args[i]))
+		}
+		if flag == "--" || flag == "-" {
+			if flag == "-" || i+1 == len(args) {
+				return stdin
+			}
+			return v.checkScriptFile(command, args[i+1])
+		}
+		if !(strings.HasPrefix(flag, "-") || strings.HasPrefix(flag, "+")) {
+			return v.checkScriptFile(command, args[i])
+		}
+		switch {
+		case flag == "-o" || flag == "+o":
+			i++ // Skip the option name
+		case strings.HasPrefix(flag, "--"):
+		case strings.Contains(flag, "c"):
+			if i+1 < len(args) {
+				return v.checkScript(command, args[i+1:i+2])
+			}
+			if xargs {
+				return reject("the shell would take its script from xargs' input, which can't be checked. Pass the script with -c")
+			}
+			return reject("the shell was given -c without a script")
+		case strings.Contains(flag, "s") && strings.HasPrefix(flag, "-"):
+			return stdin
+		}
+	}
+	if xargs {
+		return reject("the shell would take its script from xargs' input, which can't be checked. Pass the script with -c")
+	}
+	return stdin
+}
+
+// checkScriptFile checks the path of a script a shell runs, which must not
+// be its standard input.
+func (v *PolicyValidator) checkScriptFile(command string, word *syntax.Word) error {
+	script, ok := literalName(word)
+	if !ok {
+		return reject("the script path %s is computed at runtime, so it can't be checked. Use a literal path", snippet(command, word))
+	}
+	if stdinScript.MatchString(script) {
+		return reject("the shell reads its script from standard input, which can't be checked. Pass the script with -c or run the commands directly")
+	}
+	return nil
+}
+
+// checkScript parses the literal script in args and validates it.
+func (v *PolicyValidator) checkScript(command string, args []*syntax.Word) error {
+	var parts []string
+	for _, arg := range args {
+		s, ok := literal(arg)
+		if !ok {
+			return reject("the script %s is computed at runtime, so it can't be checked. Run the commands directly", snippet(command, arg))
+		}
+		parts = append(parts, s)
+	}
+	return v.Validate(strings.Join(parts, " "))
+}
+
+// checkRedirect checks where a redirect writes to.
+func (v *PolicyValidator) checkRedirect(command string, redirect *syntax.Redirect) error {
+	switch redirect.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrInOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll:
+	case syntax.DplOut:
+		// >&2 duplicates a descriptor; >&file writes to a file
+		if target, ok := literal(redirect.Word); ok && descriptor.MatchString(target) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if len(v.policy.DenyRedirects) == 0 {
+		return nil
+	}
+
+	target, ok := literalName(redirect.Word)
+	if !ok {
+		return reject("the redirect target %s is computed at runtime, so it can't be checked. Use a literal path", snippet(command, redirect.Word))
+	}
+	target = path.Clean(target)
+	for _, pattern := range v.policy.DenyRedirects {
+		if matchPath(pattern, target) {
+			return reject("writing to %s is not allowed (denied by pattern %q)", target, pattern)
+		}
+	}
+	return nil
+}
+
+// wrappedProgram skips a wrapper's options, variable assignments and option
+// values, returning the wrapped command.
+func wrappedProgram(wrapper string, args []*syntax.Word) []*syntax.Word {
+	for i := 0; i < len(args); i++ {
+		s, ok := literal(args[i])
+		if !ok {
+			return args[i:]
+		}
+		if len(s) == 2 && s[0] == '-' && strings.IndexByte(wrapperValueFlags[wrapper], s[1]) >= 0 {
+			i++ // Skip the option's value
+			continue
+		}
+		if strings.HasPrefix(s, "-") || strings.Contains(s, "=") || wrapperValue.MatchString(s) {
+			continue
+		}
+		return args[i:]
+	}
+	return nil
+}
+
+// envSplitString returns the string env -S splits into a command, if any.
+func envSplitString(args []*syntax.Word) []*syntax.Word {
+	quoted := func(s string) []*syntax.Word {
+		return []*syntax.Word{{Parts: []syntax.WordPart{&syntax.SglQuoted{Value: s}}}}
+	}
+	for i := 0; i < len(args); i++ {
+		s, ok := literal(args[i])
+		switch {
+		case !ok:
+			return nil
+		case s == "-S" || s == "--split-string":
+			if i+1 < len(args) {
+				return args[i+1 : i+2]
+			}
+			return quoted("")
+		case strings.HasPrefix(s, "--split-string="):
+			return quoted(strings.TrimPrefix(s, "--split-string="))
+		case strings.HasPrefix(s, "-S"):
+			return quoted(strings.TrimPrefix(s, "-S"))
+		case s == "-u" || s == "-C":
+			i++ // Skip the option's value
+		case !strings.HasPrefix(s, "-") && !strings.Contains(s, "="):
+			return nil
+		}
+	}
+	return nil
+}
+
+// findExec returns the command passed to find -exec, -execdir, -ok or
+// -okdir, if any.
+func findExec(args []*syntax.Word) []*syntax.Word {
+	for i, arg := range args {
+		s, _ := literal(arg)
+		if s == "-exec" || s == "-execdir" || s == "-ok" || s == "-okdir" {
+			return args[i+1:]
+		}
+	}
+	return nil
+}
+
+// literal returns the value of a word that involves no expansions, with
+// quotes and escapes removed, so these both read as rm:
+//
+//	r''m
+//	\rm
+func literal(word *syntax.Word) (string, bool) {
+	if word == nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	for _, part := range word.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			// expand.Literal keeps backslashes outside quotes
+			escaped := false
+			for _, r := range p.Value {
+				if r == '\\' && !escaped {
+					escaped = true
+					continue
+				}
+				escaped = false
+				b.WriteRune(r)
+			}
+			continue
+		case *syntax.SglQuoted:
+		case *syntax.DblQuoted:
+			for _, inner := range p.Parts {
+				if _, ok := inner.(*syntax.Lit); !ok {
+					return "", false
+				}
+			}
+		default:
+			return "", false
+		}
+
+		s, err := expand.Literal(&expand.Config{}, &syntax.Word{Parts: []syntax.WordPart{part}})
+		if err != nil {
+			return "", false
+		}
+		b.WriteString(s)
+	}
+	return b.String(), true
+}
+
+// literalName is like literal, but also rejects words with unquoted glob or
+// brace characters, which the shell expands into other names.
+func literalName(word *syntax.Word) (string, bool) {
+	s, ok := literal(word)
+	if !ok {
+		return "", false
+	}
+	for _, part := range word.Parts {
+		if lit, ok := part.(*syntax.Lit); ok && hasPattern(lit.Value) {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// hasPattern reports whether an unquoted literal holds an unescaped glob or
+// brace character.
+func hasPattern(s string) bool {
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case strings.ContainsRune(patternChars, r):
+			return true
+		}
+	}
+	return false
+}
+
+// isTestBracket reports whether a word is the [ builtin, the one program
+// name that is a glob character.
+func isTestBracket(word *syntax.Word) bool {
+	s, ok := literal(word)
+	return ok && s == "["
+}
+
+// matchName returns the first pattern matching a program name.
+func matchName(patterns []string, name string) (string, bool) {
+	i := slices.IndexFunc(patterns, func(p string) bool {
+		ok, _ := path.Match(p, name)
+		return ok
+	})
+	if i < 0 {
+		return "", false
+	}
+	return patterns[i], true
+}
+
+// matchPath reports whether target matches pattern or lies below a path
+// that does.
+func matchPath(pattern, target string) bool {
+	for p := target; ; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		if p == "/" || p == "." || !strings.Contains(p, "/") {
+			return false
+		}
+	}
+}
+
+// snippet returns the source of a node for error messages.
+func snippet(command string, node syntax.Node) string {
+	start, end := int(node.Pos().Offset()), int(node.End().Offset())
+	if start < 0 || end > len(command) || start >= end {
+		return "in the command"
+	}
+	return "`" + command[start:end] + "`"
+}
+
+// reject builds feedback telling the model what the policy disallowed.
+func reject(format string, args ...any) error {
+	return &ProcessErr{
+		Type:    ProcessErrExecution,
+		Message: "Command rejected by policy: " + fmt.Sprintf(format, args...) + ".",
+	}
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicyValidator(t *testing.T) {
+	deny := CommandPolicy{Deny: []string{"rm", "shutdown"}, DenyRedirects: []string{"/etc", "/root/.ssh"}}
+	allow := CommandPolicy{Allow: []string{"ls", "cat", "grep", "git"}}
+	noSubshells := CommandPolicy{DenySubshells: true}
+
+	tests := []struct {
+		name    string
+		policy  CommandPolicy
+		command string
+		reject  bool
+	}{
+		// Plain programs
+		{"denied program", deny, "rm -rf /tmp/x", true},
+		{"denied by path", deny, "/bin/rm -rf /tmp/x", true},
+		{"other program", deny, "ls -la /tmp", false},
+		{"denied name as argument", deny, "grep rm notes.txt", false},
+		{"denied in pipeline", deny, "ls | rm -rf /tmp/x", true},
+		{"denied after &&", deny, "cd /tmp && rm -rf x", true},
+		{"denied in function body", deny, "f() { rm -rf /tmp/x; }; f", true},
+		{"local function", allow, "f() { ls; }; f", false},
+
+		// Quoting and escapes
+		{"empty quotes", deny, "r''m -rf /tmp/x", true},
+		{"double quotes", deny, `"rm" -rf /tmp/x`, true},
+		{"split double quotes", deny, `r"m" -rf /tmp/x`, true},
+		{"backslash", deny, `\rm -rf /tmp/x`, true},
+		{"backslash inside", deny, `r\m -rf /tmp/x`, true},
+
+		// Names expanded by the shell
+		{"glob question mark", deny, "/bin/r? -rf /tmp/x", true},
+		{"glob star", deny, "/bin/r* -rf /tmp/x", true},
+		{"glob bracket", deny, "/bin/r[m] -rf /tmp/x", true},
+		{"brace expansion", deny, "{rm,-rf,/tmp/x}", true},
+		{"variable", deny, "$CMD -rf /tmp/x", true},
+		{"command substitution", deny, "$(echo rm) -rf /tmp/x", true},
+		{"quoted glob", deny, "'r?' -rf /tmp/x", false},
+		{"glob argument", deny, "ls /tmp/*.log", false},
+		{"test bracket", deny, "[ -f /tmp/x ] && echo yes", false},
+
+		// Wrappers
+		{"sudo", deny, "sudo rm -rf /tmp/x", true},
+		{"sudo with user", deny, "sudo -u root rm -rf /tmp/x", true},
+		{"env with assignment", deny, "env FOO=1 rm -rf /tmp/x", true},
+		{"env split string", deny, "env -S 'rm -rf /tmp/x'", true},
+		{"env split string attached", deny, "env -u HOME -S'rm -rf /tmp/x'", true},
+		{"timeout", deny, "timeout 5 rm -rf /tmp/x", true},
+		{"nested wrappers", deny, "nohup nice -n 5 rm -rf /tmp/x", true},
+		{"xargs", deny, "find /tmp -name x | xargs rm", true},
+		{"xargs with replacement", deny, "ls | xargs -I{} rm {}", true},
+		{"xargs without program", deny, "echo rm -rf /tmp/x | xargs", false},
+		{"xargs env", deny, "echo rm -rf /tmp/x | xargs env", true},
+		{"find exec", deny, `find /tmp -name x -exec rm {} \;`, true},
+		{"busybox applet", deny, "busybox rm x", true},
+		{"toybox applet", deny, "toybox rm x", true},
+		{"busybox allowed applet", deny, "busybox ls /tmp", false},
+		{"wrapped allowed program", deny, "sudo ls /root", false},
+		{"wrapper not allowed", allow, "sudo ls /root", true},
+
+		// Scripts run by shells and eval
+		{"bash -c", deny, "bash -c 'rm -rf /tmp/x'", true},
+		{"sh -ec", deny, `sh -ec "ls; rm -rf /tmp/x"`, true},
+		{"bash -c allowed", deny, "bash -c 'ls /tmp'", false},
+		{"computed script", deny, `bash -c "$SCRIPT"`, true},
+		{"eval", deny, "eval rm -rf /tmp/x", true},
+		{"eval allowed", deny, "eval ls /tmp", false},
+		{"pipe into shell", deny, "echo rm -rf / | bash", true},
+		{"herestring into shell", deny, "bash <<< 'rm -rf /'", true},
+		{"heredoc into shell", deny, "sh <<EOF\nrm -rf /\nEOF", true},
+		{"shell -s", deny, "echo rm -rf / | bash -s", true},
+		{"shell reading -", deny, "echo rm -rf / | sh -", true},
+		{"shell reading /dev/stdin", deny, "echo rm -rf / | bash /dev/stdin", true},
+		{"source stdin", deny, "source /dev/stdin <<< 'rm -rf /'", true},
+		{"xargs shell", deny, "echo 'rm -rf /' | xargs sh -c", true},
+		{"script file", deny, "bash ./build.sh", false},
+		{"nested shells", deny, `bash -c "sh -c 'rm -rf /tmp/x'"`, true},
+
+		// Redirects
+		{"denied redirect", deny, "echo x > /etc/passwd", true},
+		{"denied append", deny, "echo key >> /root/.ssh/authorized_keys", true},
+		{"denied redirect with dots", deny, "echo x > /tmp/../etc/hosts", true},
+		{"denied redirect glob", deny, "echo x > /et?/passwd", true},
+		{"computed redirect", deny, `echo x > "$TARGET"`, true},
+		{"allowed redirect", deny, "echo x > /tmp/out.txt", false},
+		{"descriptor duplication", deny, "ls 2>&1 >/tmp/out.txt", false},
+		{"reading a denied path", deny, "cat < /etc/passwd", false},
+
+		// Allowlist
+		{"allowed program", allow, "ls -la | grep go", false},
+		{"program not allowed", allow, "curl example.com", true},
+		{"safe builtin", allow, "cd /tmp && echo hi", false},
+
+		// Subshells
+		{"subshell", noSubshells, "(cd /tmp && ls)", true},
+		{"command substitution denied", noSubshells, "echo $(date)", true},
+		{"backticks", noSubshells, "echo `date`", true},
+		{"process substitution", noSubshells, "diff <(ls a) <(ls b)", true},
+		{"no subshell", noSubshells, "ls /tmp", false},
+		{"subshell allowed", deny, "(cd /tmp && ls)", false},
+		{"denied program in subshell", deny, "(cd /tmp && rm -rf x)", true},
+
+		// Syntax errors
+		{"unparsable", deny, "ls 'unterminated", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewPolicyValidator(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = validator.Validate(tt.command)
+			if tt.reject && err == nil {
+				t.Errorf("%q was allowed", tt.command)
+			}
+			if !tt.reject && err != nil {
+				t.Errorf("%q was rejected: %v", tt.command, err)
+			}
+			var procErr *ProcessErr
+			if err != nil && !errors.As(err, &procErr) {
+				t.Errorf("rejection is %T, want *ProcessErr", err)
+			}
+		})
+	}
+}
+
+func TestNewPolicyValidatorRejectsInvalidPatterns(t *testing.T) {
+	if _, err := NewPolicyValidator(CommandPolicy{Deny: []string{"[rm"}}); err == nil {
+		t.Error("invalid pattern was accepted")
+	}
+}
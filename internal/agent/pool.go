@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ExecutorKey identifies an executor: the session it runs commands for and
+// the user who asked. Members of a group share its session, but each gets
+// their own executor, so one's working directory, exports and files don't
+// leak into another's runs.
+type ExecutorKey struct {
+	SessionID int64
+	UserID    int64
+}
+
+// ExecutorFactory creates the executor for a user in a session.
+type ExecutorFactory func(key ExecutorKey) (Executor, error)
+
+// ExecutorPool keeps one executor per session and user, so state such as a
+// sandbox scratch directory survives between messages. Executors that
+// implement io.Closer are closed when their session is released.
+type ExecutorPool struct {
+	factory ExecutorFactory
+
+	mu        sync.Mutex
+	executors map[ExecutorKey]Executor
+}
+
+// NewExecutorPool creates a pool that builds executors with factory.
+func NewExecutorPool(factory ExecutorFactory) *ExecutorPool {
+	return &ExecutorPool{
+		factory:   factory,
+		executors: make(map[ExecutorKey]Executor),
+	}
+}
+
+// Get returns the user's executor in the session, creating it on first use.
+func (p *ExecutorPool) Get(sessionID, userID int64) (Executor, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := ExecutorKey{SessionID: sessionID, UserID: userID}
+	if executor, ok := p.executors[key]; ok {
+		return executor, nil
+	}
+
+	executor, err := p.factory(key)
+	if err != nil {
+		return nil, err
+	}
+	p.executors[key] = executor
+	return executor, nil
+}
+
+// Release tears down the executors of every user in the session, if any.
+// Call it when the session ends.
+func (p *ExecutorPool) Release(sessionID int64) error {
+	p.mu.Lock()
+	var released []Executor
+	for key, executor := range p.executors {
+		if key.SessionID == sessionID {
+			released = append(released, executor)
+			delete(p.executors, key)
+		}
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, executor := range released {
+		if closer, ok := executor.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
@@ -11,19 +12,89 @@ import (
 // QueryResult holds the response and token usage from an LLM call.
 type QueryResult struct {
 	Content      string
+	ToolCalls    []ToolCall
 	InputTokens  int
 	OutputTokens int
 	TotalTokens  int
+	CostUSD      float64 // Cost reported by the provider
+	CostReported bool    // Whether the provider reported CostUSD
+	Model        string  // Model that answered
+
+	// Prompt cache usage, included in InputTokens (Anthropic only)
+	CacheReadTokens  int
+	CacheWriteTokens int
+}
+
+// QueryError is a query the provider rejected, with the HTTP status it
+// answered with.
+type QueryError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("status %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// ToolDefinition describes a tool the model may call.
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  map[string]any // JSON schema of the arguments
+}
+
+// QueryOptions holds per-request options for a Querier.
+type QueryOptions struct {
+	Tools       []ToolDefinition
+	Model       string   // Overrides the querier's default model
+	Temperature *float64 // Sampling temperature (nil = the provider's default)
+}
+
+// QueryOption configures a single Query call.
+type QueryOption func(*QueryOptions)
+
+// WithTools offers the given tools to the model for native tool calling.
+func WithTools(tools ...ToolDefinition) QueryOption {
+	return func(o *QueryOptions) {
+		o.Tools = append(o.Tools, tools...)
+	}
+}
+
+// WithModel sends the request to model instead of the querier's default.
+func WithModel(model string) QueryOption {
+	return func(o *QueryOptions) {
+		o.Model = model
+	}
+}
+
+// WithTemperature sets the sampling temperature of the request.
+func WithTemperature(temperature float64) QueryOption {
+	return func(o *QueryOptions) {
+		o.Temperature = &temperature
+	}
 }
 
 // Querier sends messages to an LLM and receives responses.
 type Querier interface {
-	Query(ctx context.Context, messages []Message) (QueryResult, error)
+	Query(ctx context.Context, messages []Message, opts ...QueryOption) (QueryResult, error)
+}
+
+// StreamingQuerier is a Querier that can deliver the response incrementally.
+// onChunk is called with each text chunk as it arrives; returning an error
+// aborts the request. The returned QueryResult holds the full response.
+type StreamingQuerier interface {
+	Querier
+	QueryStream(ctx context.Context, messages []Message, onChunk func(chunk string) error, opts ...QueryOption) (QueryResult, error)
 }
 
 // OpenAIQuerier implements Querier using the OpenAI-compatible API.
 type OpenAIQuerier struct {
 	client llms.Model
+	model  string
 }
 
 // NewOpenAIQuerier creates a new OpenAI-compatible querier.
@@ -32,16 +103,34 @@ func NewOpenAIQuerier(apiKey, baseURL, model string) (*OpenAIQuerier, error) {
 		openai.WithToken(apiKey),
 		openai.WithBaseURL(baseURL),
 		openai.WithModel(model),
+		openai.WithHTTPClient(newUsageCostClient(baseURL)),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create LLM client: %w", err)
 	}
 
-	return &OpenAIQuerier{client: client}, nil
+	return &OpenAIQuerier{client: client, model: model}, nil
 }
 
 // Query sends messages to the LLM and returns the response with token usage.
-func (q *OpenAIQuerier) Query(ctx context.Context, messages []Message) (QueryResult, error) {
+func (q *OpenAIQuerier) Query(ctx context.Context, messages []Message, opts ...QueryOption) (QueryResult, error) {
+	return q.generate(ctx, messages, opts)
+}
+
+// QueryStream sends messages to the LLM and streams the response chunks to onChunk.
+func (q *OpenAIQuerier) QueryStream(ctx context.Context, messages []Message, onChunk func(chunk string) error, opts ...QueryOption) (QueryResult, error) {
+	return q.generate(ctx, messages, opts, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		return onChunk(string(chunk))
+	}))
+}
+
+// generate performs the completion request shared by Query and QueryStream.
+func (q *OpenAIQuerier) generate(ctx context.Context, messages []Message, opts []QueryOption, callOpts ...llms.CallOption) (QueryResult, error) {
+	var options QueryOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	llmMessages := make([]llms.MessageContent, 0, len(messages))
 
 	for _, msg := range messages {
@@ -53,15 +142,67 @@ func (q *OpenAIQuerier) Query(ctx context.Context, messages []Message) (QueryRes
 			msgType = llms.ChatMessageTypeHuman
 		case RoleAssistant:
 			msgType = llms.ChatMessageTypeAI
+		case RoleTool:
+			llmMessages = append(llmMessages, llms.MessageContent{
+				Role: llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{
+					llms.ToolCallResponse{ToolCallID: msg.ToolCallID, Content: msg.Content},
+				},
+			})
+			continue
 		default:
 			continue
 		}
-		llmMessages = append(llmMessages, llms.TextParts(msgType, msg.Content))
+
+		if len(msg.Images) > 0 {
+			llmMessages = append(llmMessages, llms.MessageContent{Role: msgType, Parts: llmImageParts(msg)})
+			continue
+		}
+
+		if len(msg.ToolCalls) == 0 {
+			llmMessages = append(llmMessages, llms.TextParts(msgType, msg.Content))
+			continue
+		}
+
+		// Assistant message that requested tool calls
+		var parts []llms.ContentPart
+		if msg.Content != "" {
+			parts = append(parts, llms.TextContent{Text: msg.Content})
+		}
+		for _, call := range msg.ToolCalls {
+			parts = append(parts, llms.ToolCall{
+				ID:   call.ID,
+				Type: "function",
+				FunctionCall: &llms.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+		llmMessages = append(llmMessages, llms.MessageContent{Role: msgType, Parts: parts})
+	}
+
+	if len(options.Tools) > 0 {
+		callOpts = append(callOpts, llms.WithTools(llmTools(options.Tools)))
+	}
+
+	model := q.model
+	if options.Model != "" {
+		model = options.Model
+		callOpts = append(callOpts, llms.WithModel(options.Model))
+	}
+	if options.Temperature != nil {
+		callOpts = append(callOpts, llms.WithTemperature(*options.Temperature))
 	}
 
-	resp, err := q.client.GenerateContent(ctx, llmMessages)
+	recorder := &responseRecorder{}
+	resp, err := q.client.GenerateContent(withResponseRecorder(ctx, recorder), llmMessages, callOpts...)
 	if err != nil {
-		return QueryResult{}, fmt.Errorf("failed to generate content: %w", err)
+		err = fmt.Errorf("failed to generate content: %w", err)
+		if status := recorder.statusCode(); status != 0 && status != http.StatusOK {
+			err = &QueryError{StatusCode: status, Err: err}
+		}
+		return QueryResult{}, err
 	}
 
 	if len(resp.Choices) == 0 {
@@ -70,6 +211,18 @@ func (q *OpenAIQuerier) Query(ctx context.Context, messages []Message) (QueryRes
 
 	result := QueryResult{
 		Content: resp.Choices[0].Content,
+		Model:   model,
+	}
+
+	for _, call := range resp.Choices[0].ToolCalls {
+		if call.FunctionCall == nil {
+			continue
+		}
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.FunctionCall.Name,
+			Arguments: call.FunctionCall.Arguments,
+		})
 	}
 
 	// Extract token usage from GenerationInfo
@@ -91,5 +244,39 @@ func (q *OpenAIQuerier) Query(ctx context.Context, messages []Message) (QueryRes
 		}
 	}
 
+	// langchaingo drops the cost some providers put in the usage object
+	result.CostUSD, result.CostReported = recorder.get()
+
 	return result, nil
 }
+
+// llmImageParts converts a message with images to its text part followed by
+// the images as data URLs. Images that weren't loaded are left out.
+func llmImageParts(msg Message) []llms.ContentPart {
+	var parts []llms.ContentPart
+	if msg.Content != "" {
+		parts = append(parts, llms.TextContent{Text: msg.Content})
+	}
+	for _, image := range msg.Images {
+		if image.Loaded() {
+			parts = append(parts, llms.ImageURLPart(image.DataURL()))
+		}
+	}
+	return parts
+}
+
+// llmTools converts tool definitions to langchaingo function tools.
+func llmTools(tools []ToolDefinition) []llms.Tool {
+	result := make([]llms.Tool, 0, len(tools))
+	for _, tool := range tools {
+		result = append(result, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return result
+}
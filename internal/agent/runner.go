@@ -15,11 +15,25 @@ const completionMarker = "TASK_COMPLETE"
 
 // RunnerConfig holds configuration for the agent runner.
 type RunnerConfig struct {
-	MaxSteps         int           // Maximum number of steps before stopping
-	CommandTimeout   time.Duration // Timeout for each command
-	WorkingDir       string        // Working directory for commands
-	SystemPrompt     string        // Base system prompt
-	ContextThreshold int           // Character threshold to trigger summarization (0 = disabled)
+	MaxSteps         int             // Maximum number of steps before stopping
+	CommandTimeout   time.Duration   // Timeout for each command
+	WorkingDir       string          // Working directory for commands
+	SystemPrompt     string          // Base system prompt
+	ToolPrompt       string          // System prompt in tool calling mode (empty = DefaultToolCallingSystemPrompt)
+	ContextThreshold int             // Token threshold to trigger summarization (0 = disabled)
+	ToolCalling      bool            // Use native tool calling instead of markdown bash blocks
+	Tools            []ActionType    // Extra tools offered in tool calling mode (bash is always available)
+	SQLDSN           string          // Connection string for the sql_query tool
+	HTTPHosts        []string        // Hosts the http_fetch tool may reach (empty = any public host)
+	Executor         Executor        // Runs bash commands (nil = a BashExecutor built from this config)
+	Sandboxed        bool            // Executor is a sandbox; tools that would run outside it are skipped
+	Model            string          // Model for every query (empty = the querier's default)
+	Temperature      *float64        // Sampling temperature of the main queries (nil = the provider's default)
+	Budget           RunBudget       // Spending cap for the run (zero = unlimited)
+	Pricing          Pricing         // Prices queries the provider doesn't report a cost for
+	Summarizer       Querier         // Summarizes large command output (nil = the main querier)
+	SummarizerModel  string          // Model for summaries (empty = Model)
+	Context          *ContextManager // Fits the conversation into the model's window (nil = DefaultContextWindow)
 }
 
 // DefaultRunnerConfig returns a sensible default configuration.
@@ -28,7 +42,7 @@ func DefaultRunnerConfig() RunnerConfig {
 		MaxSteps:         10,
 		CommandTimeout:   30 * time.Second,
 		SystemPrompt:     DefaultAgentSystemPrompt,
-		ContextThreshold: 8000, // Summarize when context exceeds 8K chars
+		ContextThreshold: 2000, // Summarize when context exceeds 2K tokens
 	}
 }
 
@@ -55,6 +69,26 @@ echo "Your final summary here"
 
 6. You have access to common tools: curl, jq, python3, node, etc.`
 
+// DefaultToolCallingSystemPrompt is the system prompt for the agent in tool
+// calling mode, where it calls tools instead of writing bash blocks and
+// finishes by answering without a tool call.
+const DefaultToolCallingSystemPrompt = `You are an autonomous agent. You accomplish tasks by calling the tools you are offered.
+
+TOOLS:
+- bash runs one shell command and returns its output and exit code.
+- Other tools, when offered, read and write files, make HTTP requests or query a database; their descriptions say how to call them.
+
+RULES:
+1. Call exactly ONE tool per response. After each call, you'll see its result. Use it to decide your next action.
+
+2. When the task is complete, reply with your final answer as plain text, without calling a tool. That reply is sent to the user.
+
+3. Be concise. Call tools, observe results, iterate.
+
+4. If a call fails, try an alternative approach.
+
+5. bash has access to common tools: curl, jq, python3, node, etc.`
+
 // Runner orchestrates the agentic loop.
 type Runner struct {
 	config   RunnerConfig
@@ -64,9 +98,13 @@ type Runner struct {
 	logger   *zerolog.Logger
 	output   io.Writer
 
-	messages []Message
-	step     int
-	userTask string // Original user request, used for summarization context
+	messages  []Message
+	step      int
+	userTask  string // Original user request, used for summarization context
+	taskIndex int    // Position of the user request in messages, kept when trimming
+
+	tools             []ToolDefinition // Tools offered to the model in tool calling mode
+	pendingToolCallID string           // Tool call awaiting its result message
 }
 
 // NewRunner creates a new agent runner.
@@ -75,23 +113,74 @@ func NewRunner(
 	querier Querier,
 	logger *zerolog.Logger,
 ) *Runner {
-	var executorOpts []BashExecutorOption
-	if config.CommandTimeout > 0 {
-		executorOpts = append(executorOpts, WithTimeout(config.CommandTimeout))
+	executor := config.Executor
+	if executor == nil {
+		var executorOpts []BashExecutorOption
+		if config.CommandTimeout > 0 {
+			executorOpts = append(executorOpts, WithTimeout(config.CommandTimeout))
+		}
+		if config.WorkingDir != "" {
+			executorOpts = append(executorOpts, WithWorkingDir(config.WorkingDir))
+		}
+		executor = NewBashExecutor(executorOpts...)
 	}
-	if config.WorkingDir != "" {
-		executorOpts = append(executorOpts, WithWorkingDir(config.WorkingDir))
+
+	if config.Context == nil {
+		config.Context = &ContextManager{defaultWindow: DefaultContextWindow}
+	}
+
+	// File tools must not reach outside an executor's own workspace
+	if ws, ok := executor.(Workspace); ok {
+		config.WorkingDir = ws.WorkDir()
 	}
 
-	return &Runner{
+	registry := NewToolRegistry(NewBashTool(executor))
+
+	runner := &Runner{
 		config:   config,
 		querier:  querier,
 		parser:   NewBashParser(),
-		executor: NewBashExecutor(executorOpts...),
+		executor: registry,
 		logger:   logger,
 		output:   io.Discard,
 		messages: []Message{},
 	}
+
+	// Tools beyond bash can only be called natively, and the bash block
+	// instructions of the system prompt would contradict them
+	if config.ToolCalling {
+		runner.config.SystemPrompt = config.ToolPrompt
+		if runner.config.SystemPrompt == "" {
+			runner.config.SystemPrompt = DefaultToolCallingSystemPrompt
+		}
+		toolConfig := ToolConfig{
+			Timeout:    config.CommandTimeout,
+			WorkingDir: config.WorkingDir,
+			SQLDSN:     config.SQLDSN,
+			HTTPHosts:  config.HTTPHosts,
+			Sandboxed:  config.Sandboxed,
+		}
+		for _, name := range config.Tools {
+			tool, err := NewTool(name, toolConfig)
+			if err != nil {
+				logger.Warn().Err(err).Str("tool", string(name)).Msg("Skipping tool")
+				continue
+			}
+			registry.Register(tool)
+		}
+
+		runner.tools = registry.Definitions()
+		runner.parser = NewToolCallParser(runner.tools...)
+	}
+
+	return runner
+}
+
+// WithApproval pauses actions matching policy until approver answers, waiting
+// at most timeout (0 = no limit) before treating the action as denied.
+func (r *Runner) WithApproval(policy ApprovalPolicy, approver Approver, timeout time.Duration) *Runner {
+	r.executor = NewApprovingExecutor(r.executor, policy, approver, timeout)
+	return r
 }
 
 // WithOutput sets the output writer for command output streaming.
@@ -102,28 +191,76 @@ func (r *Runner) WithOutput(w io.Writer) *Runner {
 
 // RunResult contains the final output from a run.
 type RunResult struct {
-	Response   string        // Final response/summary
-	Messages   []Message     // Full conversation history
-	Steps      int           // Number of steps taken
-	TokenUsage TokenUsage    // Aggregated token usage
-	Reason     TerminationReason
+	Response    string         // Final response/summary
+	Messages    []Message      // Full conversation history
+	Steps       int            // Number of steps taken
+	StepResults []StepResult   // Per-step details, in execution order
+	TokenUsage  TokenUsage     // Aggregated token usage of every request, summaries included
+	Summaries   []RequestUsage // Usage of each summarization request
+	Model       string         // Model that answered the last step
+	Reason      TerminationReason
 }
 
-// TokenUsage aggregates token counts across the run.
+// MainUsage returns the token usage of the run's steps, leaving out
+// summaries.
+func (r RunResult) MainUsage() RequestUsage {
+	usage := RequestUsage{Purpose: PurposeMain, Model: r.Model}
+	for _, step := range r.StepResults {
+		usage.InputTokens += step.InputTokens
+		usage.OutputTokens += step.OutputTokens
+		usage.TotalTokens += step.TotalTokens
+		usage.CostUSD += step.CostUSD
+	}
+	return usage
+}
+
+// TokenUsage aggregates token counts and cost across the run.
 type TokenUsage struct {
 	InputTokens  int
 	OutputTokens int
 	TotalTokens  int
+	CostUSD      float64
+}
+
+// add counts one request.
+func (u *TokenUsage) add(r RequestUsage) {
+	u.InputTokens += r.InputTokens
+	u.OutputTokens += r.OutputTokens
+	u.TotalTokens += r.TotalTokens
+	u.CostUSD += r.CostUSD
+}
+
+// RequestPurpose tags what an LLM request was for.
+type RequestPurpose string
+
+const (
+	PurposeMain           RequestPurpose = "main"            // Answering the user
+	PurposeSummarize      RequestPurpose = "summarize"       // Condensing large command output
+	PurposeSessionSummary RequestPurpose = "session_summary" // Summarizing a session that outgrew its history budget
+	PurposeMemory         RequestPurpose = "memory"          // Extracting facts to remember about the user
+)
+
+// RequestUsage is what one LLM request was for, the model that answered and
+// what it used.
+type RequestUsage struct {
+	Purpose      RequestPurpose
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	TotalTokens  int
+	CostUSD      float64
 }
 
 // Run executes the agent loop until completion or error.
 // history should contain previous conversation messages (excluding system prompt and current user message).
-func (r *Runner) Run(ctx context.Context, history []Message, userPrompt string) (RunResult, error) {
+// images are sent along with userPrompt.
+func (r *Runner) Run(ctx context.Context, history []Message, userPrompt string, images ...Image) (RunResult, error) {
 	result := RunResult{}
 
 	// Initialize conversation
 	r.messages = []Message{}
 	r.step = 0
+	r.pendingToolCallID = ""
 
 	// Store user task for summarization context
 	r.userTask = userPrompt
@@ -133,11 +270,12 @@ func (r *Runner) Run(ctx context.Context, history []Message, userPrompt string)
 
 	// Add conversation history (previous messages from session)
 	for _, msg := range history {
-		r.addMessage(msg.Role, msg.Content)
+		r.addMessage(msg.Role, msg.Content, msg.Images...)
 	}
 
 	// Add current user message
-	r.addMessage(RoleUser, userPrompt)
+	r.addMessage(RoleUser, userPrompt, images...)
+	r.taskIndex = len(r.messages) - 1
 
 	r.logger.Info().
 		Int("max_steps", r.config.MaxSteps).
@@ -147,12 +285,49 @@ func (r *Runner) Run(ctx context.Context, history []Message, userPrompt string)
 
 	// Main loop
 	for r.step = 0; r.step < r.config.MaxSteps; r.step++ {
+		// The next step costs at least as much as the last one, since the
+		// context only grows, so stop before it would run past the budget
+		if r.step > 0 && r.overBudget(result.TokenUsage, result.StepResults[len(result.StepResults)-1]) {
+			r.logger.Warn().
+				Int("step", r.step+1).
+				Int("total_tokens", result.TokenUsage.TotalTokens).
+				Float64("cost_usd", result.TokenUsage.CostUSD).
+				Msg("Budget exceeded")
+
+			result.Response = lastResponse
+			result.Reason = ReasonBudgetExceeded
+			result.Messages = r.messages
+			result.Steps = r.step
+			return result, &TerminatingErr{Reason: ReasonBudgetExceeded}
+		}
+
 		r.logger.Info().
 			Int("step", r.step+1).
-			Int("context_size", r.contextSize()).
+			Int("context_tokens", r.contextSize()).
 			Msg("Starting step")
 
+		started := time.Now()
 		stepResult, err := r.Step(ctx)
+		stepResult.Number = r.step + 1
+		stepResult.Duration = time.Since(started)
+		stepResult.Err = err
+
+		// Accumulate token usage, including steps that ended in an error
+		result.TokenUsage.add(RequestUsage{
+			InputTokens:  stepResult.InputTokens,
+			OutputTokens: stepResult.OutputTokens,
+			TotalTokens:  stepResult.TotalTokens,
+			CostUSD:      stepResult.CostUSD,
+		})
+		if stepResult.Summary != nil {
+			result.TokenUsage.add(*stepResult.Summary)
+			result.Summaries = append(result.Summaries, *stepResult.Summary)
+		}
+		if stepResult.Model != "" {
+			result.Model = stepResult.Model
+		}
+		result.StepResults = append(result.StepResults, stepResult)
+
 		if err != nil {
 			var termErr *TerminatingErr
 			var procErr *ProcessErr
@@ -175,20 +350,17 @@ func (r *Runner) Run(ctx context.Context, history []Message, userPrompt string)
 					Str("type", string(procErr.Type)).
 					Str("message", procErr.Message).
 					Msg("Process error, continuing")
-				r.addMessage(RoleUser, procErr.Message)
+				r.addFeedback(procErr.Message)
 				continue
 			}
 
 			// Unrecoverable error
 			r.logger.Error().Err(err).Msg("Unrecoverable error")
+			result.Messages = r.messages
+			result.Steps = r.step + 1
 			return result, err
 		}
 
-		// Accumulate token usage
-		result.TokenUsage.InputTokens += stepResult.InputTokens
-		result.TokenUsage.OutputTokens += stepResult.OutputTokens
-		result.TokenUsage.TotalTokens += stepResult.TotalTokens
-
 		lastResponse = stepResult.Response
 	}
 
@@ -204,14 +376,33 @@ func (r *Runner) Run(ctx context.Context, history []Message, userPrompt string)
 	return result, &TerminatingErr{Reason: ReasonStepLimit}
 }
 
+// overBudget reports whether another step like last would take the run past
+// its budget.
+func (r *Runner) overBudget(used TokenUsage, last StepResult) bool {
+	budget := r.config.Budget
+	if budget.Tokens > 0 && used.TotalTokens+last.TotalTokens > budget.Tokens {
+		return true
+	}
+	if budget.CostUSD > 0 && used.CostUSD+last.CostUSD > budget.CostUSD {
+		return true
+	}
+	return false
+}
+
 // StepResult contains the output from a single step.
 type StepResult struct {
+	Number       int // 1-based step number within the run
 	Response     string
 	Command      string
 	Output       Output
 	InputTokens  int
 	OutputTokens int
 	TotalTokens  int
+	CostUSD      float64
+	Model        string        // Model that answered
+	Summary      *RequestUsage // Usage of summarizing the step's output, if it was summarized
+	Duration     time.Duration
+	Err          error // Error returned by the step, if any
 }
 
 // Step performs a single iteration of the agent loop.
@@ -223,7 +414,17 @@ func (r *Runner) Step(ctx context.Context) (StepResult, error) {
 	r.logger.Debug().Msg("Querying model")
 
 	// 1. Query the model
-	queryResult, err := r.querier.Query(ctx, r.messages)
+	var queryOpts []QueryOption
+	if r.config.Model != "" {
+		queryOpts = append(queryOpts, WithModel(r.config.Model))
+	}
+	if len(r.tools) > 0 {
+		queryOpts = append(queryOpts, WithTools(r.tools...))
+	}
+	if r.config.Temperature != nil {
+		queryOpts = append(queryOpts, WithTemperature(*r.config.Temperature))
+	}
+	queryResult, err := r.querier.Query(ctx, r.fitMessages(), queryOpts...)
 	if err != nil {
 		r.logger.Error().Err(err).Msg("Query failed")
 		return StepResult{}, fmt.Errorf("query failed: %w", err)
@@ -231,19 +432,27 @@ func (r *Runner) Step(ctx context.Context) (StepResult, error) {
 
 	r.logger.Debug().
 		Int("response_length", len(queryResult.Content)).
+		Int("tool_calls", len(queryResult.ToolCalls)).
 		Int("input_tokens", queryResult.InputTokens).
 		Int("output_tokens", queryResult.OutputTokens).
 		Msg("Got response")
 
+	model := queryResult.Model
+	if model == "" {
+		model = r.config.Model
+	}
+
 	result := StepResult{
 		Response:     queryResult.Content,
 		InputTokens:  queryResult.InputTokens,
 		OutputTokens: queryResult.OutputTokens,
 		TotalTokens:  queryResult.TotalTokens,
+		CostUSD:      r.config.Pricing.QueryCost(model, queryResult),
+		Model:        model,
 	}
 
 	// 2. Parse action from response
-	action, err := r.parser.ParseAction(queryResult.Content)
+	action, err := r.parser.ParseAction(queryResult)
 	if err != nil {
 		r.logger.Debug().Err(err).Msg("Failed to parse action")
 		return result, err
@@ -251,7 +460,12 @@ func (r *Runner) Step(ctx context.Context) (StepResult, error) {
 	result.Command = action.Command
 
 	// 3. Add assistant message before execution
-	r.addMessage(RoleAssistant, queryResult.Content)
+	if action.ToolCallID != "" {
+		r.addToolCallMessage(queryResult.Content, queryResult.ToolCalls)
+		r.pendingToolCallID = action.ToolCallID
+	} else {
+		r.addMessage(RoleAssistant, queryResult.Content)
+	}
 
 	// 4. Execute the command and stream output
 	fmt.Fprintf(r.output, "$ %s\n", action.Command)
@@ -262,14 +476,23 @@ func (r *Runner) Step(ctx context.Context) (StepResult, error) {
 
 	output, err := r.executor.Execute(ctx, action)
 	result.Output = output
+	if output.Edited != "" {
+		r.logger.Info().
+			Str("command", output.Edited).
+			Msg("Command edited before execution")
+		result.Command = output.Edited
+	}
 
 	if err != nil {
 		r.logger.Warn().Err(err).Msg("Command execution failed")
 		return result, err
 	}
 
+	// Only bash can signal completion, other tools may return arbitrary content
+	complete := action.Type == ActionTypeBash && r.isTaskComplete(output)
+
 	// Print output (skip if it's just the completion marker)
-	if !r.isTaskComplete(output) && strings.TrimSpace(output.Stdout) != "" {
+	if !complete && strings.TrimSpace(output.Stdout) != "" {
 		fmt.Fprintln(r.output, output.Stdout)
 	}
 
@@ -279,7 +502,7 @@ func (r *Runner) Step(ctx context.Context) (StepResult, error) {
 		Msg("Command completed")
 
 	// 5. Check for completion signal in command output
-	if r.isTaskComplete(output) {
+	if complete {
 		r.logger.Info().Msg("Task complete signal in output")
 		finalOutput := r.extractFinalOutput(output)
 		return result, &TerminatingErr{
@@ -290,10 +513,14 @@ func (r *Runner) Step(ctx context.Context) (StepResult, error) {
 
 	// 6. Format observation (with optional summarization)
 	feedback := r.formatObservation(output)
+	if output.Edited != "" {
+		feedback = fmt.Sprintf("[The user edited your command before running it. Command run: %s]\n%s", output.Edited, feedback)
+	}
 
 	// 7. Summarize if context is getting too large
-	if r.shouldSummarize(len(feedback)) {
-		summarized, err := r.summarizeOutput(ctx, feedback)
+	if r.shouldSummarize(feedback) {
+		summarized, usage, err := r.summarizeOutput(ctx, feedback)
+		result.Summary = usage
 		if err != nil {
 			r.logger.Warn().Err(err).Msg("Failed to summarize, using truncated output")
 		} else {
@@ -301,8 +528,8 @@ func (r *Runner) Step(ctx context.Context) (StepResult, error) {
 		}
 	}
 
-	// 8. Add execution result as user message
-	r.addMessage(RoleUser, feedback)
+	// 8. Add execution result as user (or tool) message
+	r.addFeedback(feedback)
 
 	return result, nil
 }
@@ -347,42 +574,88 @@ func (r *Runner) formatObservation(output Output) string {
 }
 
 // addMessage appends a message to the conversation history.
-func (r *Runner) addMessage(role Role, content string) {
+func (r *Runner) addMessage(role Role, content string, images ...Image) {
 	r.messages = append(r.messages, Message{
 		Role:    role,
 		Content: content,
+		Images:  images,
 	})
 	r.logger.Debug().
 		Str("role", string(role)).
 		Int("content_length", len(content)).
+		Int("images", len(images)).
 		Msg("Message added")
 }
 
+// addToolCallMessage appends an assistant message that requested tool calls.
+func (r *Runner) addToolCallMessage(content string, calls []ToolCall) {
+	r.messages = append(r.messages, Message{
+		Role:      RoleAssistant,
+		Content:   content,
+		ToolCalls: calls,
+	})
+	r.logger.Debug().
+		Int("tool_calls", len(calls)).
+		Msg("Tool call message added")
+}
+
+// addFeedback appends an observation or error for the model. Feedback that
+// answers a pending tool call is sent as a tool message, as providers require
+// every tool call to be followed by its result.
+func (r *Runner) addFeedback(content string) {
+	if r.pendingToolCallID == "" {
+		r.addMessage(RoleUser, content)
+		return
+	}
+
+	r.messages = append(r.messages, Message{
+		Role:       RoleTool,
+		Content:    content,
+		ToolCallID: r.pendingToolCallID,
+	})
+	r.pendingToolCallID = ""
+	r.logger.Debug().
+		Int("content_length", len(content)).
+		Msg("Tool result added")
+}
+
 // Messages returns the current conversation history.
 func (r *Runner) Messages() []Message {
 	return r.messages
 }
 
-// contextSize returns the total character count of all messages.
+// contextSize returns the total token count of all messages.
 func (r *Runner) contextSize() int {
-	total := 0
-	for _, msg := range r.messages {
-		total += len(msg.Content)
+	return r.config.Context.CountMessages(r.messages)
+}
+
+// fitMessages returns the conversation cut down to the model's context
+// window. The system prompt, the user's request and the latest message are
+// always kept.
+func (r *Runner) fitMessages() []Message {
+	messages, trim := r.config.Context.Fit(r.messages, r.config.Context.Available(r.config.Model), r.taskIndex)
+	if trim.Trimmed() {
+		r.logger.Info().
+			Int("compressed", trim.Compressed).
+			Int("dropped", trim.Dropped).
+			Int("context_tokens", trim.Tokens).
+			Msg("Trimmed context to fit the window")
 	}
-	return total
+	return messages
 }
 
 // shouldSummarize returns true if context is large and output is substantial.
-func (r *Runner) shouldSummarize(outputLen int) bool {
+func (r *Runner) shouldSummarize(output string) bool {
 	if r.config.ContextThreshold <= 0 {
 		return false
 	}
-	// Summarize if context exceeds threshold and output is substantial (>500 chars)
-	return r.contextSize() > r.config.ContextThreshold && outputLen > 500
+	// Summarize if context exceeds threshold and output is substantial (>128 tokens)
+	return r.contextSize() > r.config.ContextThreshold && r.config.Context.Count(output) > 128
 }
 
-// summarizeOutput asks the model to extract relevant information from large output.
-func (r *Runner) summarizeOutput(ctx context.Context, output string) (string, error) {
+// summarizeOutput asks the summarizer to extract relevant information from
+// large output. It returns the usage of the request, if one was answered.
+func (r *Runner) summarizeOutput(ctx context.Context, output string) (string, *RequestUsage, error) {
 	prompt := []Message{
 		{
 			Role: RoleSystem,
@@ -394,19 +667,46 @@ func (r *Runner) summarizeOutput(ctx context.Context, output string) (string, er
 		},
 	}
 
+	querier := r.config.Summarizer
+	if querier == nil {
+		querier = r.querier
+	}
+	model := r.config.SummarizerModel
+	if model == "" {
+		model = r.config.Model
+	}
+
 	r.logger.Info().
 		Int("output_length", len(output)).
+		Str("model", model).
 		Msg("Summarizing large output")
 
-	result, err := r.querier.Query(ctx, prompt)
+	var queryOpts []QueryOption
+	if model != "" {
+		queryOpts = append(queryOpts, WithModel(model))
+	}
+	result, err := querier.Query(ctx, prompt, queryOpts...)
 	if err != nil {
-		return "", fmt.Errorf("summarization failed: %w", err)
+		return "", nil, fmt.Errorf("summarization failed: %w", err)
+	}
+
+	if result.Model != "" {
+		model = result.Model
+	}
+	usage := &RequestUsage{
+		Purpose:      PurposeSummarize,
+		Model:        model,
+		InputTokens:  result.InputTokens,
+		OutputTokens: result.OutputTokens,
+		TotalTokens:  result.TotalTokens,
+		CostUSD:      r.config.Pricing.QueryCost(model, result),
 	}
 
 	r.logger.Debug().
 		Int("original_length", len(output)).
 		Int("summarized_length", len(result.Content)).
+		Int("total_tokens", result.TotalTokens).
 		Msg("Output summarized")
 
-	return "[Summarized] " + result.Content, nil
+	return "[Summarized] " + result.Content, usage, nil
 }
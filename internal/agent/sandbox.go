@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sandboxInit prepares the isolated environment inside fresh namespaces and
+// execs the command. The host root is bind-mounted read-only onto an empty
+// directory, /tmp is replaced by a private tmpfs and only the scratch
+// directory stays writable; its parent is hidden so sessions can't read each
+// other's files. pivot_root detaches the host root, and dropping
+// every capability stops the command from remounting anything read-write.
+//
+// Mounts are read from mountinfo a line at a time, with the octal escapes of
+// spaces, tabs and backslashes in their paths undone, and remounted with
+// their own flags kept, which user namespaces require for locked flags like
+// nosuid. It fails closed: if the new root or any mount outside the scratch
+// directory can't be made read-only, the command doesn't run.
+//
+// Arguments: $1 mountpoint for the new root, $2 scratch dir, $3 ulimit
+// flags, $4 command.
+const sandboxInit = `set -eu
+root="$1"; scratch="$2"; limits="$3"; cmd="$4"
+mount --rbind / "$root"
+awk -v r="$root" '{
+  m = $5
+  gsub(/\\040/, " ", m); gsub(/\\011/, "\t", m); gsub(/\\134/, "\\", m)
+  if (m == r || index(m, r "/") == 1) print $6 " " m
+}' /proc/self/mountinfo | sort -r -k2 | while IFS= read -r line; do
+  opts="${line%% *}"; m="${line#* }"
+  opts="${opts#r[ow]}"; opts="${opts#,}"
+  mount -o "remount,bind,ro${opts:+,$opts}" "$m" 2>/dev/null && continue
+  case "$m" in
+    "$root$scratch"|"$root$scratch"/*) ;;
+    *) echo "sandbox: unable to make $m read-only" >&2; exit 1 ;;
+  esac
+done
+mount -t tmpfs -o nosuid,nodev,size=64m tmpfs "$root/tmp"
+parent="$(dirname "$scratch")"
+if [ "$parent" != / ]; then
+  mkdir -p "$root$parent" 2>/dev/null || true
+  mount -t tmpfs -o nosuid,nodev,size=1m tmpfs "$root$parent"
+fi
+mkdir -p "$root$scratch" 2>/dev/null || true
+mount --bind "$scratch" "$root$scratch"
+mount -t proc -o nosuid,nodev,noexec proc "$root/proc"
+cd "$root"
+pivot_root . .
+umount -l .
+cd "$scratch"
+if [ -n "$limits" ]; then ulimit $limits; fi
+exec setpriv --no-new-privs --inh-caps=-all --bounding-set=-all -- /bin/bash -c "$cmd"
+`
+
+// SandboxConfig holds settings for the sandbox executor.
+type SandboxConfig struct {
+	ScratchDir string        // Writable directory commands run in, created if missing
+	Timeout    time.Duration // Timeout for each command
+	Network    bool          // Share the host network; when false commands only see loopback
+	MemoryMB   int           // Address space limit per process (0 = unlimited)
+	MaxPids    int           // Process limit, counted against the bot's OS user (0 = unlimited)
+	CPUSeconds int           // CPU time limit per process (0 = unlimited)
+
+	Validator CommandValidator // Checks commands before they run (nil = default blocklist)
+}
+
+// SandboxExecutor runs bash commands in unprivileged Linux namespaces.
+// Each command gets a read-only view of the host filesystem, a private
+// /tmp, its own PID namespace and, optionally, no network. Only the scratch
+// directory is writable and persists between commands.
+type SandboxExecutor struct {
+	config    SandboxConfig
+	rootDir   string // Empty mountpoint for the sandbox root
+	validator CommandValidator
+}
+
+// NewSandboxExecutor creates a sandbox executor, creating its scratch
+// directory. It fails if unshare or setpriv are not installed.
+func NewSandboxExecutor(config SandboxConfig) (*SandboxExecutor, error) {
+	for _, bin := range []string{"unshare", "setpriv"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return nil, fmt.Errorf("sandbox requires %s: %w", bin, err)
+		}
+	}
+
+	if config.ScratchDir == "" {
+		return nil, fmt.Errorf("sandbox requires a scratch directory")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	scratch, err := filepath.Abs(config.ScratchDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve scratch directory: %w", err)
+	}
+	if err := os.MkdirAll(scratch, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	// Mounts need the real path
+	if scratch, err = filepath.EvalSymlinks(scratch); err != nil {
+		return nil, fmt.Errorf("failed to resolve scratch directory: %w", err)
+	}
+	config.ScratchDir = scratch
+
+	// The root mountpoint is shared by all sandboxes under the same parent;
+	// mounts on it are private to each command's mount namespace
+	rootDir := filepath.Join(filepath.Dir(scratch), ".rootfs")
+	if err := os.MkdirAll(rootDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create sandbox root: %w", err)
+	}
+
+	validator := config.Validator
+	if validator == nil {
+		validator = NewDefaultBlocklistValidator()
+	}
+
+	return &SandboxExecutor{
+		config:    config,
+		rootDir:   rootDir,
+		validator: validator,
+	}, nil
+}
+
+// WorkDir returns the scratch directory, so file tools can be confined to it.
+func (e *SandboxExecutor) WorkDir() string {
+	return e.config.ScratchDir
+}
+
+// Execute runs a bash command inside the sandbox and returns the output.
+func (e *SandboxExecutor) Execute(ctx context.Context, action Action) (Output, error) {
+	if action.Type != ActionTypeBash {
+		return Output{}, fmt.Errorf("unsupported action type: %s", action.Type)
+	}
+
+	if e.validator != nil {
+		if err := e.validator.Validate(action.Command); err != nil {
+			return Output{}, err
+		}
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(timeoutCtx, "unshare", e.unshareArgs(action.Command)...)
+	cmd.Dir = e.config.ScratchDir
+	cmd.Env = sandboxEnv(e.config.ScratchDir)
+
+	return runCommand(timeoutCtx, cmd, e.config.Timeout)
+}
+
+// ShellCommand returns an unstarted bash inside the sandbox that reads
+// commands from stdin, for use with PersistentShellExecutor.
+func (e *SandboxExecutor) ShellCommand() *exec.Cmd {
+	cmd := exec.Command("unshare", e.unshareArgs("exec bash --noprofile --norc")...)
+	cmd.Dir = e.config.ScratchDir
+	cmd.Env = sandboxEnv(e.config.ScratchDir)
+	return cmd
+}
+
+// Close removes the scratch directory and everything in it.
+func (e *SandboxExecutor) Close() error {
+	return os.RemoveAll(e.config.ScratchDir)
+}
+
+// unshareArgs builds the unshare invocation for a command.
+func (e *SandboxExecutor) unshareArgs(command string) []string {
+	args := []string{
+		"--user", "--map-root-user",
+		"--mount", "--pid", "--ipc", "--uts",
+		"--fork", "--kill-child",
+	}
+	if !e.config.Network {
+		args = append(args, "--net")
+	}
+	return append(args, "--", "bash", "-c", sandboxInit, "sandbox",
+		e.rootDir, e.config.ScratchDir, e.limits(), command)
+}
+
+// limits returns the ulimit flags for the configured resource limits.
+func (e *SandboxExecutor) limits() string {
+	var flags []string
+	if e.config.MemoryMB > 0 {
+		flags = append(flags, fmt.Sprintf("-v %d", e.config.MemoryMB*1024))
+	}
+	if e.config.MaxPids > 0 {
+		flags = append(flags, fmt.Sprintf("-u %d", e.config.MaxPids))
+	}
+	if e.config.CPUSeconds > 0 {
+		flags = append(flags, fmt.Sprintf("-t %d", e.config.CPUSeconds))
+	}
+	return strings.Join(flags, " ")
+}
+
+// sandboxEnv returns a minimal environment so secrets in the bot's
+// environment (API keys, database URLs) don't leak into commands.
+func sandboxEnv(home string) []string {
+	return []string{
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"HOME=" + home,
+		"TMPDIR=/tmp",
+		"LANG=C.UTF-8",
+	}
+}
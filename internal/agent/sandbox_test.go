@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSandbox creates a sandbox for one session under a fresh parent
+// directory, skipping the test where unprivileged namespaces aren't
+// available. Commands aren't validated, so only the sandbox stops escapes.
+func newTestSandbox(t *testing.T, config SandboxConfig) *SandboxExecutor {
+	t.Helper()
+	if _, err := exec.LookPath("unshare"); err != nil {
+		t.Skip("unshare is not installed")
+	}
+
+	if config.ScratchDir == "" {
+		config.ScratchDir = filepath.Join(t.TempDir(), "session-1")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	validator, err := NewBlocklistValidator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Validator = validator
+
+	sandbox, err := NewSandboxExecutor(config)
+	if err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+	t.Cleanup(func() { sandbox.Close() })
+
+	if output := run(t, sandbox, "true"); output.ExitCode != 0 {
+		t.Skipf("unprivileged namespaces unavailable: %s", output.Stderr)
+	}
+	return sandbox
+}
+
+// run executes a command in the sandbox. Commands that fail, including those
+// killed by a signal, have a non-zero exit code.
+func run(t *testing.T, sandbox *SandboxExecutor, command string) Output {
+	t.Helper()
+	output, err := sandbox.Execute(context.Background(), Action{Type: ActionTypeBash, Command: command})
+	var procErr *ProcessErr
+	if err != nil && !errors.As(err, &procErr) {
+		t.Fatalf("%s: %v", command, err)
+	}
+	if err != nil && output.ExitCode == 0 {
+		output.ExitCode = -1
+	}
+	return output
+}
+
+func TestSandboxWritesOutsideScratchFail(t *testing.T) {
+	sandbox := newTestSandbox(t, SandboxConfig{})
+
+	// Another session's scratch directory next to this one
+	sibling := filepath.Join(filepath.Dir(sandbox.WorkDir()), "session-2")
+	if err := os.MkdirAll(sibling, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"/", "/etc", "/usr", "/var", home, sibling} {
+		t.Run(dir, func(t *testing.T) {
+			path := filepath.Join(dir, "sandbox-escape")
+			output := run(t, sandbox, "echo escaped > "+strconv.Quote(path))
+			if output.ExitCode == 0 {
+				t.Errorf("write to %s succeeded", path)
+			}
+			if _, err := os.Stat(path); err == nil {
+				os.Remove(path)
+				t.Errorf("%s exists on the host", path)
+			}
+		})
+	}
+}
+
+func TestSandboxOnlyScratchIsWritable(t *testing.T) {
+	sandbox := newTestSandbox(t, SandboxConfig{})
+
+	output := run(t, sandbox, "echo kept > note && cat note")
+	if output.ExitCode != 0 || strings.TrimSpace(output.Stdout) != "kept" {
+		t.Fatalf("write to scratch failed: %+v", output)
+	}
+	data, err := os.ReadFile(filepath.Join(sandbox.WorkDir(), "note"))
+	if err != nil || strings.TrimSpace(string(data)) != "kept" {
+		t.Fatalf("scratch file not on the host: %q, %v", data, err)
+	}
+
+	// /tmp is private to each command and never reaches the host
+	name := "sandbox-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if output := run(t, sandbox, "echo gone > /tmp/"+name); output.ExitCode != 0 {
+		t.Fatalf("write to private /tmp failed: %+v", output)
+	}
+	if _, err := os.Stat(filepath.Join(os.TempDir(), name)); err == nil {
+		os.Remove(filepath.Join(os.TempDir(), name))
+		t.Error("write to /tmp reached the host")
+	}
+	if output := run(t, sandbox, "cat /tmp/"+name); output.ExitCode == 0 {
+		t.Error("/tmp persisted between commands")
+	}
+
+	// Every mount outside the scratch directory is read-only. Where mounts are
+	// stacked on the same path, only the last one listed can be reached.
+	output = run(t, sandbox, `awk '{opts[$5] = $6} END {for (m in opts) if (m != "/tmp" && m != "/proc" && m != ENVIRON["HOME"] && opts[m] !~ /^ro/) print m}' /proc/self/mountinfo`)
+	if output.ExitCode != 0 {
+		t.Fatalf("unable to read mounts: %+v", output)
+	}
+	parent := filepath.Dir(sandbox.WorkDir())
+	for _, mount := range strings.Fields(output.Stdout) {
+		// The scratch directory's parent is an empty tmpfs hiding the other sessions
+		if mount != parent {
+			t.Errorf("%s is mounted writable", mount)
+		}
+	}
+}
+
+func TestSandboxNetwork(t *testing.T) {
+	// A listener on the host's loopback, which an isolated network namespace can't reach
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	connect := "exec 3<>/dev/tcp/127.0.0.1/" + port
+
+	isolated := newTestSandbox(t, SandboxConfig{Network: false})
+	if output := run(t, isolated, connect); output.ExitCode == 0 {
+		t.Error("connection succeeded with Network=false")
+	}
+	if output := run(t, isolated, "exec 3<>/dev/tcp/1.1.1.1/53"); output.ExitCode == 0 {
+		t.Error("connection to the internet succeeded with Network=false")
+	}
+
+	shared := newTestSandbox(t, SandboxConfig{Network: true})
+	if output := run(t, shared, connect); output.ExitCode != 0 {
+		t.Errorf("connection failed with Network=true: %s", output.Stderr)
+	}
+}
+
+func TestSandboxResourceLimits(t *testing.T) {
+	t.Run("cpu", func(t *testing.T) {
+		sandbox := newTestSandbox(t, SandboxConfig{CPUSeconds: 1, Timeout: 20 * time.Second})
+		if output := run(t, sandbox, "ulimit -t"); strings.TrimSpace(output.Stdout) != "1" {
+			t.Errorf("ulimit -t = %q, want 1", output.Stdout)
+		}
+
+		started := time.Now()
+		output := run(t, sandbox, "while :; do :; done")
+		if output.TimedOut || output.ExitCode == 0 {
+			t.Errorf("busy loop wasn't stopped by the CPU limit: %+v", output)
+		}
+		if elapsed := time.Since(started); elapsed > 10*time.Second {
+			t.Errorf("busy loop ran for %s", elapsed)
+		}
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		sandbox := newTestSandbox(t, SandboxConfig{MemoryMB: 64})
+		if output := run(t, sandbox, "ulimit -v"); strings.TrimSpace(output.Stdout) != "65536" {
+			t.Errorf("ulimit -v = %q, want 65536", output.Stdout)
+		}
+
+		// 128 MB in a shell variable is more than the process may map
+		output := run(t, sandbox, `x="$(head -c 134217728 /dev/zero | tr '\0' a)"; echo "${#x}"`)
+		if output.ExitCode == 0 && strings.TrimSpace(output.Stdout) == "134217728" {
+			t.Error("allocation past the memory limit succeeded")
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxSQLRows caps the number of rows returned by sql_query.
+const maxSQLRows = 100
+
+// SQLQueryTool runs read-only SQL against a configured database. A read-only
+// transaction doesn't stop functions with side effects, such as
+// pg_terminate_backend, dblink or lo_export, so the DSN should name a role
+// that may only read what the agent needs.
+type SQLQueryTool struct {
+	dsn     string
+	timeout time.Duration
+}
+
+// NewSQLQueryTool creates a sql_query tool for the given DSN.
+func NewSQLQueryTool(dsn string, timeout time.Duration) *SQLQueryTool {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &SQLQueryTool{dsn: dsn, timeout: timeout}
+}
+
+// Definition returns the sql_query tool definition.
+func (t *SQLQueryTool) Definition() ToolDefinition {
+	return ToolDefinition{
+		Name:        string(ActionTypeSQLQuery),
+		Description: fmt.Sprintf("Run a single read-only SQL query against the configured PostgreSQL database. Returns at most %d rows.", maxSQLRows),
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "A single SELECT (or other read-only) statement",
+				},
+			},
+			"required": []string{"query"},
+		},
+	}
+}
+
+// Execute runs the query inside a read-only transaction that is always rolled back.
+func (t *SQLQueryTool) Execute(ctx context.Context, action Action) (Output, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := decodeArgs(action, &args); err != nil {
+		return Output{}, err
+	}
+	query := strings.TrimSpace(args.Query)
+	if query == "" {
+		return Output{}, &ProcessErr{Type: ProcessErrFormat, Message: "sql_query requires a non-empty \"query\"."}
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	// Failures to reach the database are the model's to report, not the end of the run
+	connConfig, err := pgx.ParseConfig(t.dsn)
+	if err != nil {
+		return Output{}, &ProcessErr{Type: ProcessErrExecution, Message: "The sql_query database is misconfigured, so queries can't run."}
+	}
+	// The server stops queries that outlive the tool, even if the connection is lost
+	connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(t.timeout.Milliseconds(), 10)
+
+	conn, err := pgx.ConnectConfig(timeoutCtx, connConfig)
+	if err != nil {
+		return Output{}, t.unavailableErr(timeoutCtx, err)
+	}
+	defer conn.Close(context.Background())
+
+	tx, err := conn.BeginTx(timeoutCtx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return Output{}, t.unavailableErr(timeoutCtx, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	// The extended protocol rejects multiple statements in one query
+	rows, err := tx.Query(timeoutCtx, query)
+	if err != nil {
+		return Output{}, t.queryErr(timeoutCtx, err)
+	}
+	defer rows.Close()
+
+	output, err := formatRows(rows)
+	if err != nil {
+		return Output{}, t.queryErr(timeoutCtx, err)
+	}
+
+	return Output{Stdout: output}, nil
+}
+
+// queryErr converts a query failure into feedback for the model.
+func (t *SQLQueryTool) queryErr(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &ProcessErr{Type: ProcessErrTimeout, Message: fmt.Sprintf("Query timed out after %s.", t.timeout)}
+	}
+	return &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("Query failed: %s", err)}
+}
+
+// unavailableErr converts a failure to connect or start the transaction
+// into feedback for the model.
+func (t *SQLQueryTool) unavailableErr(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &ProcessErr{Type: ProcessErrTimeout, Message: fmt.Sprintf("Connecting to the database timed out after %s.", t.timeout)}
+	}
+	return &ProcessErr{Type: ProcessErrExecution, Message: fmt.Sprintf("The database is unavailable: %s", err)}
+}
+
+// formatRows renders rows as tab-separated values with a header line.
+func formatRows(rows pgx.Rows) (string, error) {
+	var b strings.Builder
+
+	fields := rows.FieldDescriptions()
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	b.WriteString(strings.Join(names, "\t"))
+	b.WriteString("\n")
+
+	count := 0
+	truncated := false
+	for rows.Next() {
+		if count == maxSQLRows {
+			truncated = true
+			break
+		}
+		count++
+
+		values, err := rows.Values()
+		if err != nil {
+			return "", err
+		}
+		cells := make([]string, len(values))
+		for i, v := range values {
+			if v == nil {
+				cells[i] = "NULL"
+			} else {
+				cells[i] = fmt.Sprint(v)
+			}
+		}
+		b.WriteString(strings.Join(cells, "\t"))
+		b.WriteString("\n")
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if truncated {
+		fmt.Fprintf(&b, "(showing first %d rows, more available)", maxSQLRows)
+	} else {
+		fmt.Fprintf(&b, "(%d rows)", count)
+	}
+
+	return truncateOutput(b.String()), nil
+}
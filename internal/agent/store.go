@@ -3,6 +3,8 @@ package agent
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -10,6 +12,9 @@ import (
 	dbgen "github.com/j0lvera/banray/internal/db/gen"
 )
 
+// AttachmentImage is the type of attachments that are images.
+const AttachmentImage = "image"
+
 // Store manages conversation sessions and messages using PostgreSQL
 type Store struct {
 	client *db.Client
@@ -20,27 +25,54 @@ func NewStore(client *db.Client) *Store {
 	return &Store{client: client}
 }
 
-// GetOrCreateSession returns the active session for a user, creating one if none exists
-func (s *Store) GetOrCreateSession(ctx context.Context, userID int64, systemPrompt string) (*dbgen.DataSession, error) {
-	session, err := s.client.Queries.GetActiveSession(ctx, userID)
+// GetOrCreateSession returns the active session of a chat, or of a forum topic within it,
+// creating one started by the user if none exists. A new session records its profile and
+// keeps the simple and agent prompts it starts with.
+func (s *Store) GetOrCreateSession(ctx context.Context, chatID, threadID, userID int64, profile, systemPrompt, agentPrompt string) (*dbgen.DataSession, error) {
+	session, err := s.client.Queries.GetActiveSession(ctx, dbgen.GetActiveSessionParams{
+		ChatID:   chatID,
+		ThreadID: threadID,
+	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			// No active session, create one
-			return s.client.Queries.CreateSession(ctx, dbgen.CreateSessionParams{
-				UserID:       userID,
-				SystemPrompt: pgtype.Text{String: systemPrompt, Valid: systemPrompt != ""},
-			})
+			return s.CreateSession(ctx, chatID, threadID, userID, profile, systemPrompt, agentPrompt)
 		}
 		return nil, err
 	}
 	return session, nil
 }
 
-// CreateSession creates a new session for a user
-func (s *Store) CreateSession(ctx context.Context, userID int64, systemPrompt string) (*dbgen.DataSession, error) {
+// CreateSession creates a new session in a chat or forum topic, started by the user, recording
+// its profile and keeping the simple and agent prompts it starts with
+func (s *Store) CreateSession(ctx context.Context, chatID, threadID, userID int64, profile, systemPrompt, agentPrompt string) (*dbgen.DataSession, error) {
 	return s.client.Queries.CreateSession(ctx, dbgen.CreateSessionParams{
 		UserID:       userID,
+		ChatID:       chatID,
+		ThreadID:     threadID,
 		SystemPrompt: pgtype.Text{String: systemPrompt, Valid: systemPrompt != ""},
+		AgentPrompt:  pgtype.Text{String: agentPrompt, Valid: agentPrompt != ""},
+		Profile:      pgtype.Text{String: profile, Valid: profile != ""},
+	})
+}
+
+// GetActiveSession returns the active session of a chat or forum topic, or nil if there is none
+func (s *Store) GetActiveSession(ctx context.Context, chatID, threadID int64) (*dbgen.DataSession, error) {
+	session, err := s.client.Queries.GetActiveSession(ctx, dbgen.GetActiveSessionParams{
+		ChatID:   chatID,
+		ThreadID: threadID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return session, err
+}
+
+// GetUserSessions returns a user's most recent sessions, newest first
+func (s *Store) GetUserSessions(ctx context.Context, userID int64, limit int) ([]*dbgen.DataSession, error) {
+	return s.client.Queries.GetUserSessions(ctx, dbgen.GetUserSessionsParams{
+		UserID: userID,
+		Limit:  int32(limit),
 	})
 }
 
@@ -49,27 +81,80 @@ func (s *Store) EndSession(ctx context.Context, sessionID int64) error {
 	return s.client.Queries.EndSession(ctx, sessionID)
 }
 
+// EndSessionWithSummary marks a session as ended and stores the summary of its conversation
+func (s *Store) EndSessionWithSummary(ctx context.Context, sessionID int64, summary string) error {
+	return s.client.Queries.EndSessionWithSummary(ctx, dbgen.EndSessionWithSummaryParams{
+		ID:      sessionID,
+		Summary: pgtype.Text{String: summary, Valid: summary != ""},
+	})
+}
+
 // AddMessage adds a message to a session and returns the message ID
 func (s *Store) AddMessage(ctx context.Context, sessionID int64, role Role, content string) (int64, error) {
+	return s.AddSpeakerMessage(ctx, sessionID, role, "", content)
+}
+
+// AddSpeakerMessage adds a message to a session with the name of who sent it, so history in
+// group chats tells speakers apart, and returns the message ID. An empty speaker is not stored.
+func (s *Store) AddSpeakerMessage(ctx context.Context, sessionID int64, role Role, speaker, content string) (int64, error) {
 	return s.client.Queries.AddMessage(ctx, dbgen.AddMessageParams{
 		SessionID: sessionID,
 		Role:      string(role),
 		Content:   content,
+		Speaker:   pgtype.Text{String: speaker, Valid: speaker != ""},
 	})
 }
 
-// GetSessionMessages returns all messages in a session
+// AddImage stores the metadata of an image sent with a message. The image itself stays on
+// Telegram, where its file ID finds it again.
+func (s *Store) AddImage(ctx context.Context, messageID int64, image Image) error {
+	_, err := s.client.Queries.CreateAttachment(ctx, dbgen.CreateAttachmentParams{
+		MessageID:      messageID,
+		Type:           AttachmentImage,
+		TelegramFileID: image.FileID,
+		MimeType:       image.MIMEType,
+		SizeBytes:      int32(len(image.Data)),
+		Width:          pgtype.Int4{Int32: int32(image.Width), Valid: image.Width > 0},
+		Height:         pgtype.Int4{Int32: int32(image.Height), Valid: image.Height > 0},
+	})
+	return err
+}
+
+// GetSessionMessages returns all messages in a session, prefixed with their speaker's name
+// when one was stored. Images sent with them are returned without their data, which has to
+// be loaded from Telegram.
 func (s *Store) GetSessionMessages(ctx context.Context, sessionID int64) ([]Message, error) {
 	rows, err := s.client.Queries.GetSessionMessages(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
+	attachments, err := s.client.Queries.GetSessionAttachments(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	images := make(map[int64][]Image)
+	for _, attachment := range attachments {
+		if attachment.Type != AttachmentImage {
+			continue
+		}
+		images[attachment.MessageID] = append(images[attachment.MessageID], Image{
+			MIMEType: attachment.MimeType,
+			FileID:   attachment.TelegramFileID,
+			Width:    int(attachment.Width.Int32),
+			Height:   int(attachment.Height.Int32),
+		})
+	}
 
 	messages := make([]Message, len(rows))
 	for i, row := range rows {
 		messages[i] = Message{
+			ID:      row.ID,
 			Role:    Role(row.Role),
 			Content: row.Content,
+			Images:  images[row.ID],
+		}
+		if row.Speaker.Valid {
+			messages[i].Content = SpeakerContent(row.Speaker.String, row.Content)
 		}
 	}
 	return messages, nil
@@ -84,15 +169,118 @@ func (s *Store) CountSessionMessages(ctx context.Context, sessionID int64) (int,
 	return int(count), nil
 }
 
-// RecordLLMRequest stores an LLM API request with token usage
-func (s *Store) RecordLLMRequest(ctx context.Context, sessionID int64, messageID int64, inputTokens, outputTokens, totalTokens int, model string) error {
-	_, err := s.client.Queries.CreateLLMRequest(ctx, dbgen.CreateLLMRequestParams{
+// RecordLLMRequest stores an LLM API request made for a user with its purpose, token usage and cost and returns the request ID
+func (s *Store) RecordLLMRequest(ctx context.Context, sessionID int64, userID int64, messageID int64, usage RequestUsage) (int64, error) {
+	purpose := usage.Purpose
+	if purpose == "" {
+		purpose = PurposeMain
+	}
+	request, err := s.client.Queries.CreateLLMRequest(ctx, dbgen.CreateLLMRequestParams{
 		SessionID:    sessionID,
 		MessageID:    pgtype.Int8{Int64: messageID, Valid: messageID > 0},
-		InputTokens:  int32(inputTokens),
-		OutputTokens: int32(outputTokens),
-		TotalTokens:  int32(totalTokens),
-		Model:        model,
+		InputTokens:  int32(usage.InputTokens),
+		OutputTokens: int32(usage.OutputTokens),
+		TotalTokens:  int32(usage.TotalTokens),
+		Model:        usage.Model,
+		CostUsd:      usage.CostUSD,
+		Purpose:      string(purpose),
+		UserID:       userID,
 	})
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return request.ID, nil
+}
+
+// GetSessionUserTokenUsage returns the token usage of a user's requests in a session
+func (s *Store) GetSessionUserTokenUsage(ctx context.Context, sessionID int64, userID int64) (*dbgen.GetSessionUserTokenUsageRow, error) {
+	return s.client.Queries.GetSessionUserTokenUsage(ctx, dbgen.GetSessionUserTokenUsageParams{
+		SessionID: sessionID,
+		UserID:    userID,
+	})
+}
+
+// GetUserTokenUsage returns a user's token usage across all sessions
+func (s *Store) GetUserTokenUsage(ctx context.Context, userID int64) (*dbgen.GetUserTokenUsageRow, error) {
+	return s.client.Queries.GetUserTokenUsage(ctx, userID)
+}
+
+// ListUserTokenUsage returns the heaviest users by cost, then total tokens
+func (s *Store) ListUserTokenUsage(ctx context.Context, limit int) ([]*dbgen.ListUserTokenUsageRow, error) {
+	return s.client.Queries.ListUserTokenUsage(ctx, int32(limit))
+}
+
+// GetUserUsageSince returns the tokens a user has used and what they cost since a point in time
+func (s *Store) GetUserUsageSince(ctx context.Context, userID int64, since time.Time) (*dbgen.GetUserUsageSinceRow, error) {
+	return s.client.Queries.GetUserUsageSince(ctx, dbgen.GetUserUsageSinceParams{
+		UserID:    userID,
+		CreatedAt: pgtype.Timestamptz{Time: since, Valid: true},
+	})
+}
+
+// ListModelUsage returns token usage and cost per model since a point in time
+func (s *Store) ListModelUsage(ctx context.Context, since time.Time) ([]*dbgen.ListModelUsageRow, error) {
+	return s.client.Queries.ListModelUsage(ctx, pgtype.Timestamptz{Time: since, Valid: true})
+}
+
+// ListDailyUsage returns token usage and cost per UTC day since a point in time, newest first
+func (s *Store) ListDailyUsage(ctx context.Context, since time.Time) ([]*dbgen.ListDailyUsageRow, error) {
+	return s.client.Queries.ListDailyUsage(ctx, pgtype.Timestamptz{Time: since, Valid: true})
+}
+
+// RecordAgentSteps stores every step of an agentic run in a single transaction.
+// messageID and llmRequestID link the steps to the triggering message and the
+// aggregated LLM request; zero values are stored as NULL.
+func (s *Store) RecordAgentSteps(ctx context.Context, sessionID, messageID, llmRequestID int64, steps []StepResult) error {
+	tx, err := s.client.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	queries := s.client.Queries.WithTx(tx)
+	for _, step := range steps {
+		if _, err := queries.CreateAgentStep(ctx, agentStepParams(sessionID, messageID, llmRequestID, step)); err != nil {
+			return fmt.Errorf("failed to record step %d: %w", step.Number, err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetMessageAgentSteps returns the recorded steps of the run triggered by a message
+func (s *Store) GetMessageAgentSteps(ctx context.Context, messageID int64) ([]*dbgen.DataAgentStep, error) {
+	return s.client.Queries.GetMessageAgentSteps(ctx, pgtype.Int8{Int64: messageID, Valid: true})
+}
+
+// agentStepParams maps a StepResult to its database row.
+func agentStepParams(sessionID, messageID, llmRequestID int64, step StepResult) dbgen.CreateAgentStepParams {
+	params := dbgen.CreateAgentStepParams{
+		SessionID:    sessionID,
+		MessageID:    pgtype.Int8{Int64: messageID, Valid: messageID > 0},
+		LlmRequestID: pgtype.Int8{Int64: llmRequestID, Valid: llmRequestID > 0},
+		StepNumber:   int32(step.Number),
+		Response:     step.Response,
+		Stdout:       step.Output.Stdout,
+		Stderr:       step.Output.Stderr,
+		TimedOut:     step.Output.TimedOut,
+		InputTokens:  int32(step.InputTokens),
+		OutputTokens: int32(step.OutputTokens),
+		TotalTokens:  int32(step.TotalTokens),
+		DurationMs:   int32(step.Duration.Milliseconds()),
+	}
+
+	// Only steps that actually ran a command have a command and exit code
+	if step.Command != "" {
+		params.Command = pgtype.Text{String: step.Command, Valid: true}
+		params.ExitCode = pgtype.Int4{Int32: int32(step.Output.ExitCode), Valid: true}
+	}
+
+	// Completion is signalled through TerminatingErr, which is not a failure
+	var termErr *TerminatingErr
+	if step.Err != nil && !errors.As(step.Err, &termErr) {
+		params.ErrorMessage = pgtype.Text{String: step.Err.Error(), Valid: true}
+	}
+
+	return params
 }
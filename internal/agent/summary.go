@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SessionSummaryPrefix introduces the summary a session starts from, stored
+// as the first message of its history.
+const SessionSummaryPrefix = "Summary of the conversation so far:\n\n"
+
+// sessionSummaryPrompt asks for a summary the assistant can carry on from.
+const sessionSummaryPrompt = `You summarize conversations between a user and an assistant, so the assistant can carry on without the full transcript.
+
+Keep the user's goals, decisions, preferences, names, facts and open questions, and any exact values that may matter later (amounts, dates, paths, commands). Fold in the earlier summary, if there is one. Drop small talk and anything already resolved that won't come up again.
+
+Write plain prose in the third person ("The user ..."), at most 300 words.`
+
+// SummarizeConversation asks model to condense a conversation, including
+// the summary it started from, into a summary the next session can start
+// from. The transcript is cut down to the model's context window first.
+func SummarizeConversation(
+	ctx context.Context,
+	querier Querier,
+	model string,
+	contexts *ContextManager,
+	pricing Pricing,
+	messages []Message,
+) (string, RequestUsage, error) {
+	budget := contexts.Available(model) - contexts.Count(sessionSummaryPrompt) - 2*messageOverhead
+	messages, _ = contexts.Fit(messages, budget)
+
+	var transcript strings.Builder
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			fmt.Fprintf(&transcript, "Earlier summary:\n%s\n\n", strings.TrimPrefix(msg.Content, SessionSummaryPrefix))
+		case RoleUser:
+			// The summary is text, so images are only noted
+			content := msg.Content
+			if n := len(msg.Images); n > 0 {
+				content = strings.TrimSpace(fmt.Sprintf("[sent %d image(s)] %s", n, content))
+			}
+			fmt.Fprintf(&transcript, "User: %s\n\n", content)
+		case RoleAssistant:
+			fmt.Fprintf(&transcript, "Assistant: %s\n\n", msg.Content)
+		}
+	}
+
+	prompt := []Message{
+		{Role: RoleSystem, Content: sessionSummaryPrompt},
+		{Role: RoleUser, Content: strings.TrimSpace(transcript.String())},
+	}
+
+	var opts []QueryOption
+	if model != "" {
+		opts = append(opts, WithModel(model))
+	}
+	result, err := querier.Query(ctx, prompt, opts...)
+	if err != nil {
+		return "", RequestUsage{}, fmt.Errorf("session summary failed: %w", err)
+	}
+
+	if result.Model != "" {
+		model = result.Model
+	}
+	usage := RequestUsage{
+		Purpose:      PurposeSessionSummary,
+		Model:        model,
+		InputTokens:  result.InputTokens,
+		OutputTokens: result.OutputTokens,
+		TotalTokens:  result.TotalTokens,
+		CostUSD:      pricing.QueryCost(model, result),
+	}
+
+	summary := strings.TrimSpace(result.Content)
+	if summary == "" {
+		return "", usage, errors.New("session summary failed: empty response")
+	}
+	return summary, usage, nil
+}
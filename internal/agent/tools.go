@@ -0,0 +1,222 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxToolOutput caps the output returned by built-in tools.
+const maxToolOutput = 64 * 1024
+
+// Tool is an action the agent can invoke. Each tool describes its arguments
+// with a JSON schema, validates them and formats its own output.
+type Tool interface {
+	Definition() ToolDefinition
+	Execute(ctx context.Context, action Action) (Output, error)
+}
+
+// ToolConfig holds settings for the built-in tools.
+type ToolConfig struct {
+	Timeout    time.Duration // Timeout for each tool invocation
+	WorkingDir string        // Root directory for file tools
+	SQLDSN     string        // Connection string for sql_query
+	HTTPHosts  []string      // Hosts http_fetch may reach (empty = any public host)
+	Sandboxed  bool          // Commands run in a sandbox, which these tools would bypass
+}
+
+// ToolRegistry maps action types to tools. It implements Executor by
+// dispatching each action to the tool registered for its type.
+type ToolRegistry struct {
+	tools map[ActionType]Tool
+	order []ActionType
+}
+
+// NewToolRegistry creates a registry with the given tools.
+func NewToolRegistry(tools ...Tool) *ToolRegistry {
+	r := &ToolRegistry{tools: make(map[ActionType]Tool)}
+	for _, tool := range tools {
+		r.Register(tool)
+	}
+	return r
+}
+
+// Register adds a tool, replacing any tool with the same name.
+func (r *ToolRegistry) Register(tool Tool) {
+	name := ActionType(tool.Definition().Name)
+	if _, exists := r.tools[name]; !exists {
+		r.order = append(r.order, name)
+	}
+	r.tools[name] = tool
+}
+
+// Get returns the tool registered for an action type.
+func (r *ToolRegistry) Get(actionType ActionType) (Tool, bool) {
+	tool, ok := r.tools[actionType]
+	return tool, ok
+}
+
+// Definitions returns the definitions of all tools in registration order.
+func (r *ToolRegistry) Definitions() []ToolDefinition {
+	defs := make([]ToolDefinition, 0, len(r.order))
+	for _, name := range r.order {
+		defs = append(defs, r.tools[name].Definition())
+	}
+	return defs
+}
+
+// Execute runs the action with the tool registered for its type.
+func (r *ToolRegistry) Execute(ctx context.Context, action Action) (Output, error) {
+	tool, ok := r.tools[action.Type]
+	if !ok {
+		return Output{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Unknown tool %q. Available tools: %s.", action.Type, strings.Join(r.names(), ", ")),
+		}
+	}
+	return tool.Execute(ctx, action)
+}
+
+// names returns the registered tool names in registration order.
+func (r *ToolRegistry) names() []string {
+	names := make([]string, 0, len(r.order))
+	for _, name := range r.order {
+		names = append(names, string(name))
+	}
+	return names
+}
+
+// NewTool creates a built-in tool by name. The built-in tools run in the
+// bot's own process, outside any sandbox, so none is created when commands
+// are sandboxed: http_fetch would reach the network SANDBOX_NETWORK cut off
+// and the file tools the host beyond the scratch directory.
+func NewTool(actionType ActionType, cfg ToolConfig) (Tool, error) {
+	if cfg.Sandboxed {
+		switch actionType {
+		case ActionTypeReadFile, ActionTypeWriteFile, ActionTypeHTTPFetch, ActionTypeSQLQuery:
+			return nil, fmt.Errorf("%s runs outside the sandbox", actionType)
+		}
+	}
+
+	switch actionType {
+	case ActionTypeReadFile:
+		return NewReadFileTool(cfg.WorkingDir), nil
+	case ActionTypeWriteFile:
+		return NewWriteFileTool(cfg.WorkingDir), nil
+	case ActionTypeHTTPFetch:
+		return NewHTTPFetchTool(cfg.Timeout, cfg.HTTPHosts), nil
+	case ActionTypeSQLQuery:
+		if cfg.SQLDSN == "" {
+			return nil, fmt.Errorf("sql_query requires a DSN")
+		}
+		return NewSQLQueryTool(cfg.SQLDSN, cfg.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown tool: %s", actionType)
+	}
+}
+
+// BashTool exposes an Executor as the bash tool.
+type BashTool struct {
+	executor Executor
+}
+
+// NewBashTool creates the bash tool backed by the given executor.
+func NewBashTool(executor Executor) *BashTool {
+	return &BashTool{executor: executor}
+}
+
+// Definition returns the bash tool definition.
+func (t *BashTool) Definition() ToolDefinition {
+	return BashToolDefinition
+}
+
+// Execute runs the bash command.
+func (t *BashTool) Execute(ctx context.Context, action Action) (Output, error) {
+	return t.executor.Execute(ctx, action)
+}
+
+// decodeArgs unmarshals tool arguments, returning feedback the model can act on.
+func decodeArgs(action Action, v any) error {
+	if err := json.Unmarshal(action.Args, v); err != nil {
+		return &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Invalid arguments for %s: %s", action.Type, err),
+		}
+	}
+	return nil
+}
+
+// truncateOutput caps tool output at maxToolOutput bytes.
+func truncateOutput(s string) string {
+	if len(s) <= maxToolOutput {
+		return s
+	}
+	return s[:maxToolOutput] + fmt.Sprintf("\n\n[... truncated, %d bytes total ...]", len(s))
+}
+
+// resolvePath resolves path against root and ensures the result, after
+// following symlinks, stays inside root.
+func resolvePath(root, path string) (string, error) {
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get working directory: %w", err)
+		}
+		root = wd
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(realRoot, target)
+	}
+
+	realTarget, err := evalExistingSymlinks(filepath.Clean(target))
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(realRoot, realTarget)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &ProcessErr{
+			Type:    ProcessErrExecution,
+			Message: fmt.Sprintf("Path %q is outside the working directory.", path),
+		}
+	}
+
+	return realTarget, nil
+}
+
+// evalExistingSymlinks resolves symlinks in the longest existing prefix of path
+// and appends the remaining, not yet existing, components.
+func evalExistingSymlinks(path string) (string, error) {
+	var missing []string
+	current := path
+	for {
+		real, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				real = filepath.Join(real, missing[i])
+			}
+			return real, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to resolve path: %w", err)
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path, nil
+		}
+		missing = append(missing, filepath.Base(current))
+		current = parent
+	}
+}
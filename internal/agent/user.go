@@ -2,12 +2,68 @@ package agent
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/j0lvera/banray/internal/db"
 	dbgen "github.com/j0lvera/banray/internal/db/gen"
 )
 
+// UserRole controls what a user may do with the bot.
+type UserRole string
+
+const (
+	UserRoleBlocked UserRole = "blocked" // Ignored by the bot
+	UserRoleUser    UserRole = "user"    // Simple mode only
+	UserRoleAgent   UserRole = "agent"   // May use agentic mode
+	UserRoleAdmin   UserRole = "admin"   // May use agentic mode and admin commands
+)
+
+// UserRoles lists every role, from least to most privileged.
+var UserRoles = []UserRole{UserRoleBlocked, UserRoleUser, UserRoleAgent, UserRoleAdmin}
+
+// ParseUserRole validates a role name.
+func ParseUserRole(s string) (UserRole, error) {
+	for _, role := range UserRoles {
+		if string(role) == s {
+			return role, nil
+		}
+	}
+	return "", fmt.Errorf("invalid role %q: must be blocked, user, agent or admin", s)
+}
+
+// CanChat reports whether the bot answers the user at all.
+func (r UserRole) CanChat() bool {
+	return r != UserRoleBlocked
+}
+
+// CanUseAgent reports whether the user may run agentic tasks.
+func (r UserRole) CanUseAgent() bool {
+	return r == UserRoleAgent || r == UserRoleAdmin
+}
+
+// IsAdmin reports whether the user may run admin commands.
+func (r UserRole) IsAdmin() bool {
+	return r == UserRoleAdmin
+}
+
+// UserMode is the mode a user picked with /mode.
+type UserMode string
+
+const (
+	UserModeSimple UserMode = "simple" // Plain chat
+	UserModeAgent  UserMode = "agent"  // Agentic tasks with bash access
+)
+
+// ParseUserMode validates a mode name.
+func ParseUserMode(s string) (UserMode, error) {
+	switch mode := UserMode(s); mode {
+	case UserModeSimple, UserModeAgent:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid mode %q: must be simple or agent", s)
+}
+
 // UserStore manages user data using PostgreSQL
 type UserStore struct {
 	client *db.Client
@@ -18,14 +74,48 @@ func NewUserStore(client *db.Client) *UserStore {
 	return &UserStore{client: client}
 }
 
-// UpsertUser creates or updates a user from Telegram data
-func (s *UserStore) UpsertUser(ctx context.Context, telegramID int64, username, firstName, lastName, languageCode string) (*dbgen.DataUser, error) {
+// UpsertUser creates or updates a user from Telegram data. New users get
+// defaultRole; existing users keep theirs.
+func (s *UserStore) UpsertUser(ctx context.Context, telegramID int64, username, firstName, lastName, languageCode string, defaultRole UserRole) (*dbgen.DataUser, error) {
 	return s.client.Queries.UpsertUser(ctx, dbgen.UpsertUserParams{
 		TelegramID:   telegramID,
 		Username:     pgtype.Text{String: username, Valid: username != ""},
 		FirstName:    pgtype.Text{String: firstName, Valid: firstName != ""},
 		LastName:     pgtype.Text{String: lastName, Valid: lastName != ""},
 		LanguageCode: pgtype.Text{String: languageCode, Valid: languageCode != ""},
+		Role:         string(defaultRole),
+	})
+}
+
+// SetUserRole changes a user's role
+func (s *UserStore) SetUserRole(ctx context.Context, userID int64, role UserRole) (*dbgen.DataUser, error) {
+	return s.client.Queries.SetUserRole(ctx, dbgen.SetUserRoleParams{
+		ID:   userID,
+		Role: string(role),
+	})
+}
+
+// SetUserMode stores the mode a user picked; an empty mode restores the default
+func (s *UserStore) SetUserMode(ctx context.Context, userID int64, mode UserMode) (*dbgen.DataUser, error) {
+	return s.client.Queries.SetUserMode(ctx, dbgen.SetUserModeParams{
+		ID:   userID,
+		Mode: pgtype.Text{String: string(mode), Valid: mode != ""},
+	})
+}
+
+// SetUserModel stores the model a user picked; an empty model restores the default
+func (s *UserStore) SetUserModel(ctx context.Context, userID int64, model string) (*dbgen.DataUser, error) {
+	return s.client.Queries.SetUserModel(ctx, dbgen.SetUserModelParams{
+		ID:    userID,
+		Model: pgtype.Text{String: model, Valid: model != ""},
+	})
+}
+
+// SetUserProfile stores the profile a user picked; an empty profile restores the default
+func (s *UserStore) SetUserProfile(ctx context.Context, userID int64, profile string) (*dbgen.DataUser, error) {
+	return s.client.Queries.SetUserProfile(ctx, dbgen.SetUserProfileParams{
+		ID:      userID,
+		Profile: pgtype.Text{String: profile, Valid: profile != ""},
 	})
 }
 
@@ -33,3 +123,21 @@ func (s *UserStore) UpsertUser(ctx context.Context, telegramID int64, username,
 func (s *UserStore) GetUserByTelegramID(ctx context.Context, telegramID int64) (*dbgen.DataUser, error) {
 	return s.client.Queries.GetUserByTelegramID(ctx, telegramID)
 }
+
+// GetUserByUsername retrieves a user by their Telegram username, ignoring case
+func (s *UserStore) GetUserByUsername(ctx context.Context, username string) (*dbgen.DataUser, error) {
+	return s.client.Queries.GetUserByUsername(ctx, username)
+}
+
+// ListUsers returns users ordered by ID
+func (s *UserStore) ListUsers(ctx context.Context, limit, offset int) ([]*dbgen.DataUser, error) {
+	return s.client.Queries.ListUsers(ctx, dbgen.ListUsersParams{
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+}
+
+// ListBroadcastRecipients returns the Telegram IDs of every user who isn't blocked
+func (s *UserStore) ListBroadcastRecipients(ctx context.Context) ([]int64, error) {
+	return s.client.Queries.ListBroadcastRecipients(ctx)
+}
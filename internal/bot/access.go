@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"context"
+	"slices"
+
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/j0lvera/banray/internal/config"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+	"github.com/rs/zerolog"
+)
+
+// accessControl decides what each user may do based on their role.
+type accessControl struct {
+	defaultRole agent.UserRole
+	admins      []int64 // Telegram IDs always granted the admin role
+	agentic     bool    // Default mode for users who haven't picked one
+}
+
+func newAccessControl(cfg *config.Config) (*accessControl, error) {
+	defaultRole, err := agent.ParseUserRole(cfg.DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+
+	return &accessControl{
+		defaultRole: defaultRole,
+		admins:      cfg.AdminTelegramIDs,
+		agentic:     cfg.AgenticMode,
+	}, nil
+}
+
+// role returns the user's role, promoting allowlisted admins so they can
+// bootstrap everyone else's access.
+func (a *accessControl) role(ctx context.Context, userStore *agent.UserStore, user *dbgen.DataUser, log *zerolog.Logger) agent.UserRole {
+	role := agent.UserRole(user.Role)
+	if role == agent.UserRoleAdmin || !slices.Contains(a.admins, user.TelegramID) {
+		return role
+	}
+
+	if _, err := userStore.SetUserRole(ctx, user.ID, agent.UserRoleAdmin); err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("unable to promote allowlisted admin")
+	} else {
+		log.Info().Int64("user_id", user.ID).Str("previous_role", string(role)).Msg("promoted allowlisted admin")
+	}
+	return agent.UserRoleAdmin
+}
+
+// mode returns the mode a user's messages run in: the one they picked with
+// /mode, their profile's, or AGENTIC_MODE's default. Only roles that may use
+// the agent get agentic mode.
+func (a *accessControl) mode(role agent.UserRole, user *dbgen.DataUser, profile config.Profile) agent.UserMode {
+	switch {
+	case !role.CanUseAgent():
+		return agent.UserModeSimple
+	case user.Mode.Valid:
+		return agent.UserMode(user.Mode.String)
+	case profile.Mode != "":
+		return agent.UserMode(profile.Mode)
+	case a.agentic:
+		return agent.UserModeAgent
+	default:
+		return agent.UserModeSimple
+	}
+}
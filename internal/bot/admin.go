@@ -0,0 +1,387 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tbot "github.com/go-telegram/bot"
+	"github.com/j0lvera/banray/internal/agent"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// usersPageSize is how many users /users lists per page.
+	usersPageSize = 20
+
+	// usageTopUsers is how many users /usage all lists.
+	usageTopUsers = 20
+
+	// usageReportDays is how far back /usage models and /usage days look.
+	usageReportDays = 30
+
+	// broadcastInterval spaces out broadcast messages to stay under
+	// Telegram's limit of about 30 messages per second.
+	broadcastInterval = 50 * time.Millisecond
+
+	// broadcastRetries is how many times a rate-limited message is retried.
+	broadcastRetries = 3
+
+	// adminPrivateOnly is the reply to admin commands sent in a group.
+	adminPrivateOnly = "Admin commands only work in a private chat with me."
+)
+
+// adminCommands implements the commands only admins can run.
+type adminCommands struct {
+	store        *agent.Store
+	userStore    *agent.UserStore
+	index        *agent.ContextIndex
+	access       *accessControl
+	log          *zerolog.Logger
+	broadcasting atomic.Bool // Only one broadcast runs at a time
+}
+
+func newAdminCommands(store *agent.Store, userStore *agent.UserStore, index *agent.ContextIndex, access *accessControl, log *zerolog.Logger) *adminCommands {
+	return &adminCommands{
+		store:     store,
+		userStore: userStore,
+		index:     index,
+		access:    access,
+		log:       log,
+	}
+}
+
+// commands returns the admin commands for the registry.
+func (a *adminCommands) commands() []command {
+	return []command{
+		{name: "users", description: "List users: /users [page]", adminOnly: true, handler: a.users},
+		{name: "grant", description: "Set a user's role: /grant <@username|telegram_id> <role>", adminOnly: true, handler: a.grant},
+		{name: "revoke", description: "Block a user: /revoke <@username|telegram_id>", adminOnly: true, handler: a.revoke},
+		{name: "broadcast", description: "Message every user: /broadcast <text>", adminOnly: true, handler: a.broadcast},
+		{name: "reindex", description: "Index the context directory again", adminOnly: true, handler: a.reindex},
+	}
+}
+
+// users lists known users, a page at a time.
+func (a *adminCommands) users(ctx context.Context, req commandRequest) {
+	page := 1
+	if req.args != "" {
+		n, err := strconv.Atoi(req.args)
+		if err != nil || n < 1 {
+			req.reply(ctx, "Usage: /users [page]")
+			return
+		}
+		page = n
+	}
+
+	users, err := a.userStore.ListUsers(ctx, usersPageSize, (page-1)*usersPageSize)
+	if err != nil {
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to list users")
+		req.reply(ctx, "Sorry, I couldn't list users.")
+		return
+	}
+	if len(users) == 0 {
+		req.reply(ctx, fmt.Sprintf("No users on page %d.", page))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Users (page %d):\n", page)
+	for _, user := range users {
+		fmt.Fprintf(&b, "\n%s — %s", describeUser(user), user.Role)
+	}
+	if len(users) == usersPageSize {
+		fmt.Fprintf(&b, "\n\nMore: /users %d", page+1)
+	}
+	req.reply(ctx, b.String())
+}
+
+// grant changes a user's role.
+func (a *adminCommands) grant(ctx context.Context, req commandRequest) {
+	fields := strings.Fields(req.args)
+	if len(fields) != 2 {
+		req.reply(ctx, "Usage: /grant <@username|telegram_id> <blocked|user|agent|admin>")
+		return
+	}
+
+	role, err := agent.ParseUserRole(strings.ToLower(fields[1]))
+	if err != nil {
+		req.reply(ctx, "Unknown role. Roles: blocked, user, agent, admin.")
+		return
+	}
+	a.setRole(ctx, req, fields[0], role)
+}
+
+// revoke blocks a user.
+func (a *adminCommands) revoke(ctx context.Context, req commandRequest) {
+	fields := strings.Fields(req.args)
+	if len(fields) != 1 {
+		req.reply(ctx, "Usage: /revoke <@username|telegram_id>")
+		return
+	}
+	a.setRole(ctx, req, fields[0], agent.UserRoleBlocked)
+}
+
+// setRole changes the role of the user named by ref.
+func (a *adminCommands) setRole(ctx context.Context, req commandRequest, ref string, role agent.UserRole) {
+	target, ok := a.findUser(ctx, req, ref)
+	if !ok {
+		return
+	}
+	if target.ID == req.user.ID {
+		req.reply(ctx, "You can't change your own role.")
+		return
+	}
+
+	updated, err := a.userStore.SetUserRole(ctx, target.ID, role)
+	if err != nil {
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Int64("target_user_id", target.ID).Msg("unable to set user role")
+		req.reply(ctx, "Sorry, I couldn't update the role.")
+		return
+	}
+	a.log.Info().
+		Int64("user_id", req.user.ID).
+		Int64("target_user_id", target.ID).
+		Str("previous_role", target.Role).
+		Str("role", updated.Role).
+		Msg("user role changed")
+
+	text := fmt.Sprintf("%s is now %s (was %s).", describeUser(updated), updated.Role, target.Role)
+	if role != agent.UserRoleAdmin && slices.Contains(a.access.admins, target.TelegramID) {
+		text += "\nThey're in ADMIN_TELEGRAM_IDS, so they'll be promoted back to admin on their next message."
+	}
+	req.reply(ctx, text)
+}
+
+// usage shows the heaviest users for "/usage all", spending per model or
+// per day for "/usage models" and "/usage days", or one user's totals.
+func (a *adminCommands) usage(ctx context.Context, req commandRequest) {
+	switch req.args {
+	case "all":
+		a.usageByUser(ctx, req)
+		return
+	case "models":
+		a.usageByModel(ctx, req)
+		return
+	case "days":
+		a.usageByDay(ctx, req)
+		return
+	}
+
+	target, ok := a.findUser(ctx, req, req.args)
+	if !ok {
+		return
+	}
+	usage, err := a.store.GetUserTokenUsage(ctx, target.ID)
+	if err != nil {
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Int64("target_user_id", target.ID).Msg("unable to get user token usage")
+		req.reply(ctx, "Sorry, I couldn't load usage.")
+		return
+	}
+	req.reply(ctx, fmt.Sprintf("Usage for %s:\n\nRequests: %d\nInput tokens: %d\nOutput tokens: %d\nTotal tokens: %d\nCost: %s",
+		describeUser(target), usage.RequestCount, usage.TotalInputTokens, usage.TotalOutputTokens, usage.TotalTokens, formatUSD(usage.TotalCostUsd)))
+}
+
+// usageByUser lists the users who spent the most.
+func (a *adminCommands) usageByUser(ctx context.Context, req commandRequest) {
+	rows, err := a.store.ListUserTokenUsage(ctx, usageTopUsers)
+	if err != nil {
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to list user token usage")
+		req.reply(ctx, "Sorry, I couldn't load usage.")
+		return
+	}
+	if len(rows) == 0 {
+		req.reply(ctx, "No usage recorded yet.")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("Usage by user:\n")
+	for _, row := range rows {
+		name := fmt.Sprintf("tg %d", row.TelegramID)
+		if row.Username.Valid {
+			name = "@" + row.Username.String
+		}
+		fmt.Fprintf(&b, "\n#%d %s — %s, %d tokens (%d in, %d out) in %d requests",
+			row.UserID, name, formatUSD(row.TotalCostUsd), row.TotalTokens, row.TotalInputTokens, row.TotalOutputTokens, row.RequestCount)
+	}
+	req.reply(ctx, b.String())
+}
+
+// usageByModel lists spending per model over the report window.
+func (a *adminCommands) usageByModel(ctx context.Context, req commandRequest) {
+	rows, err := a.store.ListModelUsage(ctx, time.Now().AddDate(0, 0, -usageReportDays))
+	if err != nil {
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to list model usage")
+		req.reply(ctx, "Sorry, I couldn't load usage.")
+		return
+	}
+	if len(rows) == 0 {
+		req.reply(ctx, fmt.Sprintf("No usage in the last %d days.", usageReportDays))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage by model, last %d days:\n", usageReportDays)
+	for _, row := range rows {
+		fmt.Fprintf(&b, "\n%s — %s, %d tokens (%d in, %d out) in %d requests",
+			row.Model, formatUSD(row.TotalCostUsd), row.TotalTokens, row.TotalInputTokens, row.TotalOutputTokens, row.RequestCount)
+	}
+	req.reply(ctx, b.String())
+}
+
+// usageByDay lists spending per UTC day over the report window.
+func (a *adminCommands) usageByDay(ctx context.Context, req commandRequest) {
+	rows, err := a.store.ListDailyUsage(ctx, time.Now().AddDate(0, 0, -usageReportDays))
+	if err != nil {
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to list daily usage")
+		req.reply(ctx, "Sorry, I couldn't load usage.")
+		return
+	}
+	if len(rows) == 0 {
+		req.reply(ctx, fmt.Sprintf("No usage in the last %d days.", usageReportDays))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage by day (UTC), last %d days:\n", usageReportDays)
+	for _, row := range rows {
+		fmt.Fprintf(&b, "\n%s — %s, %d tokens in %d requests",
+			row.Day.Time.Format("2006-01-02"), formatUSD(row.TotalCostUsd), row.TotalTokens, row.RequestCount)
+	}
+	req.reply(ctx, b.String())
+}
+
+// broadcast sends a message to every user who isn't blocked. Sending runs in
+// the background, paced to respect Telegram's rate limits, and the admin gets
+// a summary when it's done.
+func (a *adminCommands) broadcast(ctx context.Context, req commandRequest) {
+	if req.args == "" {
+		req.reply(ctx, "Usage: /broadcast <text>")
+		return
+	}
+	if !a.broadcasting.CompareAndSwap(false, true) {
+		req.reply(ctx, "A broadcast is already in progress.")
+		return
+	}
+
+	recipients, err := a.userStore.ListBroadcastRecipients(ctx)
+	if err != nil {
+		a.broadcasting.Store(false)
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to list broadcast recipients")
+		req.reply(ctx, "Sorry, I couldn't load the recipients.")
+		return
+	}
+
+	req.reply(ctx, fmt.Sprintf("Broadcasting to %d users…", len(recipients)))
+	a.log.Info().Int64("user_id", req.user.ID).Int("recipients", len(recipients)).Msg("broadcast started")
+
+	// The update's context ends with this handler, but the broadcast outlives it
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer a.broadcasting.Store(false)
+
+		sent, failed := 0, 0
+		ticker := time.NewTicker(broadcastInterval)
+		defer ticker.Stop()
+		for _, telegramID := range recipients {
+			<-ticker.C
+			if err := sendWithRetry(ctx, req.tg, telegramID, req.args); err != nil {
+				a.log.Warn().Err(err).Int64("telegram_id", telegramID).Msg("unable to deliver broadcast")
+				failed++
+				continue
+			}
+			sent++
+		}
+
+		a.log.Info().Int64("user_id", req.user.ID).Int("sent", sent).Int("failed", failed).Msg("broadcast finished")
+		req.reply(ctx, fmt.Sprintf("Broadcast finished: %d sent, %d failed.", sent, failed))
+	}()
+}
+
+// reindex rebuilds the context index after the context directories changed.
+func (a *adminCommands) reindex(ctx context.Context, req commandRequest) {
+	stats, err := a.index.Reindex(ctx)
+	if err != nil {
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to index context directories")
+		req.reply(ctx, "Sorry, I couldn't index the context directories.")
+		return
+	}
+	a.log.Info().
+		Int64("user_id", req.user.ID).
+		Int("dirs", stats.Dirs).
+		Int("files", stats.Files).
+		Int("chunks", stats.Chunks).
+		Int("tokens", stats.Tokens).
+		Msg("context directories reindexed")
+
+	req.reply(ctx, fmt.Sprintf("Indexed %d chunks (%d tokens) from %d files in %d directories.", stats.Chunks, stats.Tokens, stats.Files, stats.Dirs))
+}
+
+// findUser resolves "@username" or a Telegram ID to a user, replying when it
+// can't.
+func (a *adminCommands) findUser(ctx context.Context, req commandRequest, ref string) (*dbgen.DataUser, bool) {
+	var (
+		user *dbgen.DataUser
+		err  error
+	)
+	if telegramID, convErr := strconv.ParseInt(ref, 10, 64); convErr == nil {
+		user, err = a.userStore.GetUserByTelegramID(ctx, telegramID)
+	} else {
+		user, err = a.userStore.GetUserByUsername(ctx, strings.TrimPrefix(ref, "@"))
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		req.reply(ctx, fmt.Sprintf("No user found for %s. Users must message the bot before they can be managed.", ref))
+		return nil, false
+	}
+	if err != nil {
+		a.log.Error().Err(err).Int64("chat_id", req.chatID).Str("ref", ref).Msg("unable to look up user")
+		req.reply(ctx, "Sorry, I couldn't look up that user.")
+		return nil, false
+	}
+	return user, true
+}
+
+// sendWithRetry sends a message, waiting out rate limits.
+func sendWithRetry(ctx context.Context, tg *tbot.Bot, chatID int64, text string) error {
+	for attempt := 0; ; attempt++ {
+		_, err := tg.SendMessage(ctx, &tbot.SendMessageParams{
+			ChatID: chatID,
+			Text:   text,
+		})
+
+		var tooMany *tbot.TooManyRequestsError
+		if !errors.As(err, &tooMany) || attempt >= broadcastRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(tooMany.RetryAfter) * time.Second):
+		}
+	}
+}
+
+// describeUser formats a user for admin listings.
+func describeUser(user *dbgen.DataUser) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#%d", user.ID)
+	if user.Username.Valid {
+		fmt.Fprintf(&b, " @%s", user.Username.String)
+	}
+	name := strings.TrimSpace(user.FirstName.String + " " + user.LastName.String)
+	if name != "" {
+		fmt.Fprintf(&b, " (%s)", name)
+	}
+	fmt.Fprintf(&b, " · tg %d", user.TelegramID)
+	return b.String()
+}
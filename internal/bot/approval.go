@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	tbot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/rs/zerolog"
+)
+
+// approvalCallbackPrefix prefixes the callback data of approval buttons.
+const approvalCallbackPrefix = "approval:"
+
+// maxApprovalCommand caps how much of a command is shown in an approval request.
+const maxApprovalCommand = 3500
+
+// pendingApproval is an approval request waiting for an answer.
+type pendingApproval struct {
+	chatID    int64
+	threadID  int   // Forum topic, 0 outside of topics
+	userID    int64 // Telegram user allowed to answer
+	messageID int
+	command   string
+	response  chan agent.Approval
+}
+
+// approvalManager tracks approval requests posted to chats and routes inline
+// keyboard answers and edited commands back to the waiting executor.
+type approvalManager struct {
+	log *zerolog.Logger
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]*pendingApproval
+	editing map[int64]string // Chat ID -> approval awaiting an edited command
+}
+
+// newApprovalManager creates an empty approval manager.
+func newApprovalManager(log *zerolog.Logger) *approvalManager {
+	return &approvalManager{
+		log:     log,
+		pending: make(map[string]*pendingApproval),
+		editing: make(map[int64]string),
+	}
+}
+
+// chatApprover posts approval requests to a single chat or forum topic.
+type chatApprover struct {
+	manager  *approvalManager
+	tg       *tbot.Bot
+	chatID   int64
+	threadID int
+	userID   int64
+}
+
+// approverFor returns an agent.Approver that asks userID in chatID, within
+// the forum topic threadID if it isn't 0.
+func (m *approvalManager) approverFor(tg *tbot.Bot, chatID int64, threadID int, userID int64) agent.Approver {
+	return &chatApprover{manager: m, tg: tg, chatID: chatID, threadID: threadID, userID: userID}
+}
+
+// RequestApproval posts the command with Approve / Deny / Edit buttons and
+// waits for the answer.
+func (a *chatApprover) RequestApproval(ctx context.Context, action agent.Action) (agent.Approval, error) {
+	id, pending := a.manager.register(a.chatID, a.threadID, a.userID, action.Command)
+	defer a.manager.remove(id)
+
+	msg, err := a.tg.SendMessage(ctx, &tbot.SendMessageParams{
+		ChatID:          a.chatID,
+		MessageThreadID: a.threadID,
+		Text:            "Approval needed to run:\n\n$ " + truncate(action.Command, maxApprovalCommand),
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{
+					{Text: "Approve", CallbackData: approvalCallbackPrefix + "approve:" + id},
+					{Text: "Deny", CallbackData: approvalCallbackPrefix + "deny:" + id},
+					{Text: "Edit", CallbackData: approvalCallbackPrefix + "edit:" + id},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return agent.Approval{}, fmt.Errorf("failed to send approval request: %w", err)
+	}
+
+	a.manager.mu.Lock()
+	pending.messageID = msg.ID
+	a.manager.mu.Unlock()
+
+	select {
+	case approval := <-pending.response:
+		return approval, nil
+	case <-ctx.Done():
+		a.manager.resolveMessage(context.Background(), a.tg, pending, "Approval timed out, command not run.")
+		return agent.Approval{}, ctx.Err()
+	}
+}
+
+// register creates a pending approval and returns its ID.
+func (m *approvalManager) register(chatID int64, threadID int, userID int64, command string) (string, *pendingApproval) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	id := strconv.Itoa(m.nextID)
+	pending := &pendingApproval{
+		chatID:   chatID,
+		threadID: threadID,
+		userID:   userID,
+		command:  command,
+		response: make(chan agent.Approval, 1),
+	}
+	m.pending[id] = pending
+	return id, pending
+}
+
+// remove forgets a pending approval.
+func (m *approvalManager) remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if pending, ok := m.pending[id]; ok && m.editing[pending.chatID] == id {
+		delete(m.editing, pending.chatID)
+	}
+	delete(m.pending, id)
+}
+
+// answer delivers an approval and removes it from the pending set.
+func (m *approvalManager) answer(id string, approval agent.Approval) (*pendingApproval, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending, ok := m.pending[id]
+	if !ok {
+		return nil, false
+	}
+	delete(m.pending, id)
+	if m.editing[pending.chatID] == id {
+		delete(m.editing, pending.chatID)
+	}
+	pending.response <- approval
+	return pending, true
+}
+
+// handleCallback handles presses on the approval inline keyboard.
+func (m *approvalManager) handleCallback(ctx context.Context, tg *tbot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query == nil {
+		return
+	}
+
+	verb, id, ok := strings.Cut(strings.TrimPrefix(query.Data, approvalCallbackPrefix), ":")
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	pending, exists := m.pending[id]
+	m.mu.Unlock()
+
+	if !exists {
+		tg.AnswerCallbackQuery(ctx, &tbot.AnswerCallbackQueryParams{
+			CallbackQueryID: query.ID,
+			Text:            "This request is no longer pending.",
+		})
+		return
+	}
+	if query.From.ID != pending.userID {
+		tg.AnswerCallbackQuery(ctx, &tbot.AnswerCallbackQueryParams{
+			CallbackQueryID: query.ID,
+			Text:            "Only the user who started this task can answer.",
+		})
+		return
+	}
+
+	tg.AnswerCallbackQuery(ctx, &tbot.AnswerCallbackQueryParams{CallbackQueryID: query.ID})
+
+	switch verb {
+	case "approve":
+		if pending, ok := m.answer(id, agent.Approval{Decision: agent.ApprovalApproved}); ok {
+			m.resolveMessage(ctx, tg, pending, "Approved:")
+		}
+	case "deny":
+		if pending, ok := m.answer(id, agent.Approval{Decision: agent.ApprovalDenied}); ok {
+			m.resolveMessage(ctx, tg, pending, "Denied:")
+		}
+	case "edit":
+		m.mu.Lock()
+		m.editing[pending.chatID] = id
+		m.mu.Unlock()
+		tg.SendMessage(ctx, &tbot.SendMessageParams{
+			ChatID:          pending.chatID,
+			MessageThreadID: pending.threadID,
+			Text:            "Send the edited command as your next message.",
+		})
+	}
+
+	m.log.Info().
+		Int64("chat_id", pending.chatID).
+		Str("approval_id", id).
+		Str("decision", verb).
+		Msg("approval answered")
+}
+
+// takeEdit consumes text as the edited command if the chat has an approval
+// awaiting one from this user. It reports whether the text was consumed.
+func (m *approvalManager) takeEdit(ctx context.Context, tg *tbot.Bot, chatID, userID int64, text string) bool {
+	m.mu.Lock()
+	id, ok := m.editing[chatID]
+	pending := m.pending[id]
+	m.mu.Unlock()
+
+	if !ok || pending == nil || pending.userID != userID {
+		return false
+	}
+
+	if pending, ok := m.answer(id, agent.Approval{Decision: agent.ApprovalEdited, Command: text}); ok {
+		m.resolveMessage(ctx, tg, pending, "Edited, running instead:\n\n$ "+truncate(text, maxApprovalCommand)+"\n\nOriginal:")
+	}
+	return true
+}
+
+// resolveMessage replaces the approval request with its outcome and removes the keyboard.
+func (m *approvalManager) resolveMessage(ctx context.Context, tg *tbot.Bot, pending *pendingApproval, status string) {
+	m.mu.Lock()
+	messageID := pending.messageID
+	m.mu.Unlock()
+	if messageID == 0 {
+		return
+	}
+
+	_, err := tg.EditMessageText(ctx, &tbot.EditMessageTextParams{
+		ChatID:    pending.chatID,
+		MessageID: messageID,
+		Text:      truncateMessage(status + "\n\n$ " + truncate(pending.command, maxApprovalCommand)),
+	})
+	if err != nil {
+		m.log.Debug().Err(err).Int64("chat_id", pending.chatID).Msg("unable to update approval message")
+	}
+}
@@ -3,6 +3,8 @@ package bot
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	tbot "github.com/go-telegram/bot"
@@ -10,6 +12,7 @@ import (
 	"github.com/j0lvera/banray/internal/agent"
 	"github.com/j0lvera/banray/internal/config"
 	"github.com/j0lvera/banray/internal/db"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
 	"github.com/rs/zerolog"
 	"go.uber.org/fx"
 )
@@ -17,29 +20,74 @@ import (
 type Params struct {
 	fx.In
 
-	Config   *config.Config
-	Querier  agent.Querier
-	DBClient *db.Client
+	Config    *config.Config
+	Querier   agent.Querier
+	Executors *agent.ExecutorPool
+	Context   *agent.ContextManager
+	DBClient  *db.Client
 }
 
 type Result struct {
 	fx.Out
 
-	Bot       *tbot.Bot
-	Store     *agent.Store
-	UserStore *agent.UserStore
+	Bot         *tbot.Bot
+	Store       *agent.Store
+	UserStore   *agent.UserStore
+	ChatStore   *agent.ChatStore
+	MemoryStore *agent.MemoryStore
 }
 
 func New(lc fx.Lifecycle, p Params, log zerolog.Logger) (Result, error) {
 	store := agent.NewStore(p.DBClient)
 	userStore := agent.NewUserStore(p.DBClient)
+	chats := agent.NewChatStore(p.DBClient)
+	memories := agent.NewMemoryStore(p.DBClient)
+	index := agent.NewContextIndex(p.DBClient, p.Context, p.Config)
+	reload := newReloader(p.Config, index, &log)
+	approvals := newApprovalManager(&log)
+	loader := newImageLoader()
+
+	policy, err := newApprovalPolicy(p.Config.Approval)
+	if err != nil {
+		return Result{}, err
+	}
+
+	access, err := newAccessControl(p.Config)
+	if err != nil {
+		return Result{}, err
+	}
+
+	budgets, err := agent.NewBudgets(store, p.Config.Budgets)
+	if err != nil {
+		return Result{}, err
+	}
+
+	me := &botIdentity{}
+	registry := newCommandRegistry(me, &log)
+	admin := newAdminCommands(store, userStore, index, access, &log)
+	user := &userCommands{
+		store:     store,
+		userStore: userStore,
+		chats:     chats,
+		memories:  memories,
+		executors: p.Executors,
+		access:    access,
+		budgets:   budgets,
+		registry:  registry,
+		admin:     admin,
+		cfg:       p.Config,
+		log:       &log,
+	}
+	registry.register(user.commands()...)
+	registry.register(admin.commands()...)
 
 	opts := []tbot.Option{
 		tbot.WithDefaultHandler(
 			func(ctx context.Context, tg *tbot.Bot, update *models.Update) {
-				handleMessage(ctx, tg, update, p.Querier, store, userStore, p.Config, &log)
+				handleMessage(ctx, tg, update, me, p.Querier, p.Executors, p.Context, index, store, userStore, chats, memories, loader, access, budgets, approvals, policy, p.Config, &log)
 			},
 		),
+		tbot.WithCallbackQueryDataHandler(approvalCallbackPrefix, tbot.MatchTypePrefix, approvals.handleCallback),
 	}
 
 	tg, err := tbot.New(p.Config.Token, opts...)
@@ -47,27 +95,53 @@ func New(lc fx.Lifecycle, p Params, log zerolog.Logger) (Result, error) {
 		return Result{}, err
 	}
 
+	registry.install(tg, func(ctx context.Context, tg *tbot.Bot, message *models.Message) (*dbgen.DataUser, *dbgen.DataChat, agent.UserRole, bool) {
+		user, role, ok := identifyUser(ctx, tg, message, userStore, access, &log)
+		if !ok {
+			return nil, nil, "", false
+		}
+		chat, ok := identifyChat(ctx, tg, message, chats, &log)
+		return user, chat, role, ok
+	})
+
 	lc.Append(
 		fx.Hook{
 			OnStart: func(ctx context.Context) error {
+				reload.start(ctx)
 				log.Info().Msg("starting telegram bot...")
+				registry.publish(ctx, tg, p.Config.AdminTelegramIDs)
 				go tg.Start(context.Background())
 				return nil
 			},
 			OnStop: func(ctx context.Context) error {
 				log.Info().Msg("stopping telegram bot...")
+				reload.stop()
 				return nil
 			},
 		},
 	)
 
 	return Result{
-		Bot:       tg,
-		Store:     store,
-		UserStore: userStore,
+		Bot:         tg,
+		Store:       store,
+		UserStore:   userStore,
+		ChatStore:   chats,
+		MemoryStore: memories,
 	}, nil
 }
 
+// newApprovalPolicy builds the command approval policy, or nil when approvals are off.
+func newApprovalPolicy(cfg config.Approval) (agent.ApprovalPolicy, error) {
+	switch cfg.Mode {
+	case config.ApprovalModePatterns:
+		return agent.NewPatternApprovalPolicy(cfg.Patterns, false)
+	case config.ApprovalModeStrict:
+		return agent.NewPatternApprovalPolicy(nil, true)
+	default:
+		return nil, nil
+	}
+}
+
 func Module() fx.Option {
 	return fx.Module(
 		"bot",
@@ -84,9 +158,20 @@ func handleMessage(
 	ctx context.Context,
 	tg *tbot.Bot,
 	update *models.Update,
+	me *botIdentity,
 	querier agent.Querier,
+	executors *agent.ExecutorPool,
+	contexts *agent.ContextManager,
+	index *agent.ContextIndex,
 	store *agent.Store,
 	userStore *agent.UserStore,
+	chats *agent.ChatStore,
+	memories *agent.MemoryStore,
+	loader *imageLoader,
+	access *accessControl,
+	budgets *agent.Budgets,
+	approvals *approvalManager,
+	policy agent.ApprovalPolicy,
 	cfg *config.Config,
 	log *zerolog.Logger,
 ) {
@@ -96,6 +181,8 @@ func handleMessage(
 	}
 
 	chatID := update.Message.Chat.ID
+	threadID := messageThread(update.Message)
+	group := isGroup(update.Message.Chat.Type)
 
 	// Guard against nil user
 	if update.Message.From == nil {
@@ -103,117 +190,233 @@ func handleMessage(
 		return
 	}
 
-	// 1. Upsert user from Telegram data
-	user, err := userStore.UpsertUser(
-		ctx,
-		update.Message.From.ID,
-		update.Message.From.Username,
-		update.Message.From.FirstName,
-		update.Message.From.LastName,
-		update.Message.From.LanguageCode,
-	)
-	if err != nil {
-		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to upsert user")
+	// An edited command for a pending approval is not a new request
+	if approvals.takeEdit(ctx, tg, chatID, update.Message.From.ID, update.Message.Text) {
+		return
+	}
+
+	// In groups, only answer messages meant for the bot
+	if group && !me.addressed(update.Message) {
+		return
+	}
+
+	// Photos and image files carry their text as a caption
+	text := messageText(update.Message)
+	attached := messageImages(update.Message)
+	if text == "" && len(attached) == 0 {
 		tg.SendMessage(ctx, &tbot.SendMessageParams{
-			ChatID: chatID,
-			Text:   "Sorry, I encountered an error. Please try again.",
+			ChatID:          chatID,
+			MessageThreadID: threadID,
+			Text:            "Sorry, I can only read text and images.",
 		})
 		return
 	}
 
-	// 2. Handle /clear command
-	if update.Message.Text == "/clear" {
-		// Get active session to end it
-		session, err := store.GetOrCreateSession(ctx, user.ID, cfg.SimplePrompt())
-		if err != nil {
-			log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to get session for clear")
-		} else {
-			if err := store.EndSession(ctx, session.ID); err != nil {
-				log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to end session")
-			}
-		}
+	// 1. Identify the user and the chat, turning away blocked users before any LLM or executor work
+	user, role, ok := identifyUser(ctx, tg, update.Message, userStore, access, log)
+	if !ok {
+		return
+	}
+	chat, ok := identifyChat(ctx, tg, update.Message, chats, log)
+	if !ok {
+		return
+	}
+
+	// 2. Turn away users who have used up their budget
+	allowance, err := budgets.Remaining(ctx, user.ID, role)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to check budget")
 		tg.SendMessage(ctx, &tbot.SendMessageParams{
-			ChatID: chatID,
-			Text:   "Conversation cleared. Starting fresh!",
+			ChatID:          chatID,
+			MessageThreadID: threadID,
+			Text:            "Sorry, I encountered an error. Please try again.",
+		})
+		return
+	}
+	if allowance.Exhausted != "" {
+		log.Info().Int64("chat_id", chatID).Int64("user_id", user.ID).Str("limit", allowance.Exhausted).Msg("budget exhausted")
+		tg.SendMessage(ctx, &tbot.SendMessageParams{
+			ChatID:          chatID,
+			MessageThreadID: threadID,
+			Text:            fmt.Sprintf("You've reached your %s budget. Please try again once it resets.", allowance.Exhausted),
 		})
-		log.Info().Int64("chat_id", chatID).Int64("user_id", user.ID).Msg("session ended by user")
 		return
 	}
 
-	// 3. Get or create active session
-	session, err := store.GetOrCreateSession(ctx, user.ID, cfg.SimplePrompt())
+	// 3. Get or create the active session of the chat or topic, recording its profile and prompts
+	profile := chatProfile(cfg, chat, user, log)
+	session, err := store.GetOrCreateSession(ctx, chat.ID, int64(threadID), user.ID, profile.Name, profile.Prompt, profile.AgentPrompt)
 	if err != nil {
 		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to get or create session")
 		tg.SendMessage(ctx, &tbot.SendMessageParams{
-			ChatID: chatID,
-			Text:   "Sorry, I encountered an error. Please try again.",
+			ChatID:          chatID,
+			MessageThreadID: threadID,
+			Text:            "Sorry, I encountered an error. Please try again.",
 		})
 		return
 	}
 
-	// 4. Check if session hit message limit
-	count, err := store.CountSessionMessages(ctx, session.ID)
-	if err != nil {
-		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to count session messages")
-	}
-	if count >= cfg.HistoryLimit {
-		// End current session and create new one
-		if err := store.EndSession(ctx, session.ID); err != nil {
-			log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to end session at limit")
-		}
-		session, err = store.CreateSession(ctx, user.ID, cfg.SimplePrompt())
+	// 4. Send typing indicator
+	tg.SendChatAction(ctx, &tbot.SendChatActionParams{
+		ChatID:          chatID,
+		MessageThreadID: threadID,
+		Action:          models.ChatActionTyping,
+	})
+
+	// Download the images sent with the message
+	images := make([]agent.Image, 0, len(attached))
+	for _, image := range attached {
+		image, err := loader.load(ctx, tg, image)
 		if err != nil {
-			log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to create new session")
+			log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to download image")
 			tg.SendMessage(ctx, &tbot.SendMessageParams{
-				ChatID: chatID,
-				Text:   "Sorry, I encountered an error. Please try again.",
+				ChatID:          chatID,
+				MessageThreadID: threadID,
+				Text:            "Sorry, I couldn't download your image. Please try again.",
 			})
 			return
 		}
+		images = append(images, image)
+	}
+
+	// Agentic or simple mode, depending on the user's role, chosen mode and
+	// profile, with the prompts the session started with, the context of the
+	// profile relevant to the message and what the bot remembers about the
+	// user. Memories stay out of groups, where others would see them.
+	model := userModel(cfg, profile, user)
+	agentic := access.mode(role, user, profile) == agent.UserModeAgent
+	prompts := sessionPrompts(session, profile.Prompts())
+	retrieved := retrieveContext(ctx, chatID, text, profile.ContextDir, index, cfg, log)
+	var extra string
+	if group {
+		extra = groupPrompt(chat)
+	} else {
+		extra = recallMemories(ctx, chatID, user.ID, text, memories, cfg, log)
+	}
+	systemPrompt := prompts.SimplePrompt(retrieved) + extra
+	var toolPrompt string // The runner's prompt in tool calling mode
+	if agentic {
+		systemPrompt = prompts.AgentPrompt(retrieved) + extra
+		toolPrompt = prompts.AgentToolsPrompt(retrieved) + extra
+	}
+
+	// 5. Continue from a summary once the session outgrows its history budget
+	session = rotateSession(ctx, chatID, user.ID, session, profile, model, systemPrompt, querier, executors, contexts, store, cfg, log)
+	if session == nil {
 		tg.SendMessage(ctx, &tbot.SendMessageParams{
-			ChatID: chatID,
-			Text:   "Starting a new conversation due to context limit.",
+			ChatID:          chatID,
+			MessageThreadID: threadID,
+			Text:            "Sorry, I encountered an error. Please try again.",
 		})
-		log.Info().Int64("chat_id", chatID).Int64("user_id", user.ID).Msg("session auto-rotated due to limit")
+		return
 	}
 
-	// 5. Store the user message
-	userMessageID, err := store.AddMessage(ctx, session.ID, agent.RoleUser, update.Message.Text)
+	// 6. Store the user message, attributed to its speaker in groups, and its images
+	userText, speaker := text, ""
+	if group {
+		speaker = speakerName(update.Message.From)
+		userText = agent.SpeakerContent(speaker, userText)
+	}
+	userMessageID, err := store.AddSpeakerMessage(ctx, session.ID, agent.RoleUser, speaker, text)
 	if err != nil {
 		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to store user message")
+	} else {
+		for _, image := range images {
+			if err := store.AddImage(ctx, userMessageID, image); err != nil {
+				log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to store image")
+			}
+		}
 	}
 
-	// 6. Send typing indicator
-	tg.SendChatAction(ctx, &tbot.SendChatActionParams{
-		ChatID: chatID,
-		Action: models.ChatActionTyping,
-	})
-
-	// Route to agentic or simple mode
-	if cfg.AgenticMode {
-		handleAgenticMessage(ctx, tg, chatID, session.ID, userMessageID, update.Message.Text, querier, store, cfg, log)
+	var response string
+	if agentic {
+		var approver agent.Approver
+		if policy != nil {
+			approver = approvals.approverFor(tg, chatID, threadID, update.Message.From.ID)
+		}
+		budget := allowance.RunBudget()
+		response = handleAgenticMessage(ctx, tg, chatID, threadID, session.ID, user.ID, userMessageID, userText, images, systemPrompt, toolPrompt, model, profile, budget, querier, executors, contexts, store, loader, policy, approver, cfg, log)
 	} else {
-		handleSimpleMessage(ctx, tg, chatID, session.ID, userMessageID, querier, store, cfg, log)
+		response = handleSimpleMessage(ctx, tg, chatID, threadID, session.ID, user.ID, userMessageID, images, systemPrompt, model, profile, querier, contexts, store, loader, cfg, log)
+	}
+
+	// 7. Remember what the exchange taught about the user
+	if cfg.Memories && !group && response != "" {
+		go rememberExchange(ctx, chatID, user.ID, session.ID, userMessageID, text, response, model, querier, memories, store, cfg, log)
+	}
+}
+
+// identifyUser upserts the sender of a message and resolves their role. It
+// replies and returns false when the user may not use the bot.
+func identifyUser(
+	ctx context.Context,
+	tg *tbot.Bot,
+	message *models.Message,
+	userStore *agent.UserStore,
+	access *accessControl,
+	log *zerolog.Logger,
+) (*dbgen.DataUser, agent.UserRole, bool) {
+	chatID := message.Chat.ID
+
+	user, err := userStore.UpsertUser(
+		ctx,
+		message.From.ID,
+		message.From.Username,
+		message.From.FirstName,
+		message.From.LastName,
+		message.From.LanguageCode,
+		access.defaultRole,
+	)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to upsert user")
+		tg.SendMessage(ctx, &tbot.SendMessageParams{
+			ChatID:          chatID,
+			MessageThreadID: messageThread(message),
+			Text:            "Sorry, I encountered an error. Please try again.",
+		})
+		return nil, "", false
+	}
+
+	role := access.role(ctx, userStore, user, log)
+	if !role.CanChat() {
+		log.Info().Int64("chat_id", chatID).Int64("user_id", user.ID).Msg("ignored message from blocked user")
+		tg.SendMessage(ctx, &tbot.SendMessageParams{
+			ChatID:          chatID,
+			MessageThreadID: messageThread(message),
+			Text:            "Sorry, you don't have access to this bot.",
+		})
+		return nil, "", false
 	}
+
+	return user, role, true
 }
 
-// handleSimpleMessage handles messages in simple (non-agentic) mode
+// handleSimpleMessage handles messages in simple (non-agentic) mode and
+// returns the response, or an empty string if there was none
 func handleSimpleMessage(
 	ctx context.Context,
 	tg *tbot.Bot,
 	chatID int64,
+	threadID int,
 	sessionID int64,
+	userID int64,
 	userMessageID int64,
+	images []agent.Image,
+	systemPrompt string,
+	model string,
+	profile config.Profile,
 	querier agent.Querier,
+	contexts *agent.ContextManager,
 	store *agent.Store,
+	loader *imageLoader,
 	cfg *config.Config,
 	log *zerolog.Logger,
-) {
-	// Build messages for LLM (system prompt + history)
+) string {
+	// Build messages for LLM (system prompt + as much history as fits)
 	messages := []agent.Message{
 		{
 			Role:    agent.RoleSystem,
-			Content: cfg.SimplePrompt(),
+			Content: systemPrompt,
 		},
 	}
 
@@ -221,29 +424,83 @@ func handleSimpleMessage(
 	if err != nil {
 		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to get session messages")
 	}
-	messages = append(messages, history...)
+	// The message just stored has its images downloaded already. Others in a
+	// group may have been stored after it, so it isn't always the last one.
+	for i := range history {
+		if history[i].ID == userMessageID {
+			history[i].Images = images
+		}
+	}
+	history = fitHistory(chatID, sessionID, model, systemPrompt, history, contexts, log)
+	messages = append(messages, loader.loadHistory(ctx, tg, chatID, history, log)...)
+
+	// Stream into a placeholder message when the querier supports it
+	streamer, canStream := querier.(agent.StreamingQuerier)
+	var live *liveMessage
+	if canStream && cfg.StreamResponses {
+		live, err = newLiveMessage(ctx, tg, chatID, threadID, "…", log)
+		if err != nil {
+			log.Warn().Err(err).Int64("chat_id", chatID).Msg("unable to send placeholder message")
+		}
+	}
 
 	// Query the LLM
-	log.Info().Int64("chat_id", chatID).Int64("session_id", sessionID).Msg("ai request sending")
-	result, err := querier.Query(ctx, messages)
+	log.Info().Int64("chat_id", chatID).Int64("session_id", sessionID).Str("model", model).Str("profile", profile.Name).Bool("streaming", live != nil).Msg("ai request sending")
+	opts := []agent.QueryOption{agent.WithModel(model)}
+	if profile.Temperature != nil {
+		opts = append(opts, agent.WithTemperature(*profile.Temperature))
+	}
+	var result agent.QueryResult
+	if live != nil {
+		var text strings.Builder
+		result, err = streamer.QueryStream(ctx, messages, func(chunk string) error {
+			text.WriteString(chunk)
+			live.Set(text.String())
+			return nil
+		}, opts...)
+	} else {
+		result, err = querier.Query(ctx, messages, opts...)
+	}
 	if err != nil {
 		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to generate ai response")
+		errorText := "Sorry, I encountered an error while processing your request."
+		if live != nil {
+			live.Finish(ctx, errorText)
+			return ""
+		}
 		tg.SendMessage(ctx, &tbot.SendMessageParams{
-			ChatID: chatID,
-			Text:   "Sorry, I encountered an error while processing your request.",
+			ChatID:          chatID,
+			MessageThreadID: threadID,
+			Text:            errorText,
 		})
-		return
+		return ""
+	}
+	// A fallback model may have answered instead of the one asked for
+	if result.Model != "" {
+		model = result.Model
 	}
+	cost := agent.Pricing(cfg.Pricing).QueryCost(model, result)
 	log.Info().
 		Int64("chat_id", chatID).
 		Int64("session_id", sessionID).
+		Str("model", model).
 		Int("input_tokens", result.InputTokens).
 		Int("output_tokens", result.OutputTokens).
 		Int("total_tokens", result.TotalTokens).
+		Float64("cost_usd", cost).
+		Bool("cost_reported", result.CostReported).
 		Msg("ai response received")
 
 	// Record LLM request for usage tracking
-	if err := store.RecordLLMRequest(ctx, sessionID, userMessageID, result.InputTokens, result.OutputTokens, result.TotalTokens, cfg.Model); err != nil {
+	usage := agent.RequestUsage{
+		Purpose:      agent.PurposeMain,
+		Model:        model,
+		InputTokens:  result.InputTokens,
+		OutputTokens: result.OutputTokens,
+		TotalTokens:  result.TotalTokens,
+		CostUSD:      cost,
+	}
+	if _, err := store.RecordLLMRequest(ctx, sessionID, userID, userMessageID, usage); err != nil {
 		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to record llm request")
 	}
 
@@ -253,35 +510,94 @@ func handleSimpleMessage(
 	}
 
 	// Send response to user
-	tg.SendMessage(ctx, &tbot.SendMessageParams{
-		ChatID: chatID,
-		Text:   result.Content,
-	})
+	if live != nil {
+		live.Finish(ctx, result.Content)
+		return result.Content
+	}
+	sendReply(ctx, tg, chatID, threadID, result.Content, log)
+	return result.Content
+}
+
+// fitHistory cuts a session's history down to what fits next to the system
+// prompt in the model's context window, leaving out the oldest messages.
+func fitHistory(
+	chatID int64,
+	sessionID int64,
+	model string,
+	systemPrompt string,
+	history []agent.Message,
+	contexts *agent.ContextManager,
+	log *zerolog.Logger,
+) []agent.Message {
+	fitted, trim := contexts.FitHistory(model, systemPrompt, history)
+	if trim.Trimmed() {
+		log.Info().
+			Int64("chat_id", chatID).
+			Int64("session_id", sessionID).
+			Str("model", model).
+			Int("compressed", trim.Compressed).
+			Int("dropped", trim.Dropped).
+			Int("history_tokens", trim.Tokens).
+			Msg("history trimmed to fit context")
+	}
+	return fitted
 }
 
-// handleAgenticMessage handles messages in agentic mode with bash access
+// handleAgenticMessage handles messages in agentic mode with bash access and
+// returns the response, or an empty string if there was none
 func handleAgenticMessage(
 	ctx context.Context,
 	tg *tbot.Bot,
 	chatID int64,
+	threadID int,
 	sessionID int64,
+	userID int64,
 	userMessageID int64,
 	userText string,
+	images []agent.Image,
+	systemPrompt string,
+	toolPrompt string,
+	model string,
+	profile config.Profile,
+	budget agent.RunBudget,
 	querier agent.Querier,
+	executors *agent.ExecutorPool,
+	contexts *agent.ContextManager,
 	store *agent.Store,
+	loader *imageLoader,
+	policy agent.ApprovalPolicy,
+	approver agent.Approver,
 	cfg *config.Config,
 	log *zerolog.Logger,
-) {
+) string {
 	// Load conversation history (excluding the current message we just stored)
 	allMessages, err := store.GetSessionMessages(ctx, sessionID)
 	if err != nil {
 		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to get session messages")
 	}
 
-	// Exclude the last message (the one we just stored) since we pass userText separately
-	var history []agent.Message
-	if len(allMessages) > 1 {
-		history = allMessages[:len(allMessages)-1]
+	// Exclude the message we just stored since we pass userText separately. Others
+	// in a group may have been stored after it, so it isn't always the last one.
+	history := make([]agent.Message, 0, len(allMessages))
+	for _, msg := range allMessages {
+		if msg.ID != userMessageID {
+			history = append(history, msg)
+		}
+	}
+	history = fitHistory(chatID, sessionID, model, systemPrompt, history, contexts, log)
+	history = loader.loadHistory(ctx, tg, chatID, history, log)
+
+	// Commands run in the user's executor for the session, which may keep state
+	// between messages. Group members each get their own.
+	executor, err := executors.Get(sessionID, userID)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to create session executor")
+		tg.SendMessage(ctx, &tbot.SendMessageParams{
+			ChatID:          chatID,
+			MessageThreadID: threadID,
+			Text:            "Sorry, I encountered an error while processing your request.",
+		})
+		return ""
 	}
 
 	// Create runner config
@@ -289,12 +605,43 @@ func handleAgenticMessage(
 		MaxSteps:         cfg.MaxSteps,
 		CommandTimeout:   cfg.CommandTimeout,
 		WorkingDir:       cfg.WorkingDir,
-		SystemPrompt:     cfg.AgentPrompt(),
+		SystemPrompt:     systemPrompt,
+		ToolPrompt:       toolPrompt,
 		ContextThreshold: cfg.ContextThreshold,
+		ToolCalling:      cfg.ToolCalling,
+		SQLDSN:           cfg.SQLToolDSN,
+		HTTPHosts:        cfg.HTTPToolHosts,
+		Executor:         executor,
+		Sandboxed:        cfg.Executor == config.ExecutorSandbox,
+		Model:            model,
+		Temperature:      profile.Temperature,
+		Budget:           budget,
+		Pricing:          agent.Pricing(cfg.Pricing),
+		Summarizer:       querier,
+		SummarizerModel:  cfg.SummarizerModel,
+		Context:          contexts,
+	}
+	for _, name := range profile.Tools {
+		runnerConfig.Tools = append(runnerConfig.Tools, agent.ActionType(name))
 	}
 
 	// Create the runner
 	runner := agent.NewRunner(runnerConfig, querier, log)
+	if policy != nil && approver != nil {
+		runner.WithApproval(policy, approver, cfg.Approval.Timeout)
+	}
+
+	// Mirror the run into a status message that is edited in place
+	var progress *progressWriter
+	if cfg.ShowProgress {
+		live, err := newLiveMessage(ctx, tg, chatID, threadID, "Working on it…", log)
+		if err != nil {
+			log.Warn().Err(err).Int64("chat_id", chatID).Msg("unable to send progress message")
+		} else {
+			progress = newProgressWriter(live)
+			runner.WithOutput(progress)
+		}
+	}
 
 	// Start a goroutine to send typing indicators periodically
 	typingCtx, cancelTyping := context.WithCancel(ctx)
@@ -308,8 +655,9 @@ func handleAgenticMessage(
 				return
 			case <-ticker.C:
 				tg.SendChatAction(typingCtx, &tbot.SendChatActionParams{
-					ChatID: chatID,
-					Action: models.ChatActionTyping,
+					ChatID:          chatID,
+					MessageThreadID: threadID,
+					Action:          models.ChatActionTyping,
 				})
 			}
 		}
@@ -320,26 +668,63 @@ func handleAgenticMessage(
 		Int64("chat_id", chatID).
 		Int64("session_id", sessionID).
 		Bool("agentic_mode", true).
+		Str("model", model).
+		Str("profile", profile.Name).
 		Int("max_steps", cfg.MaxSteps).
 		Int("history_messages", len(history)).
 		Msg("starting agentic run")
 
-	result, err := runner.Run(ctx, history, userText)
+	result, runErr := runner.Run(ctx, history, userText, images...)
+	if progress != nil {
+		progress.Finish(ctx, result, runErr)
+	}
+
+	// A fallback model may have answered instead of the one asked for
+	if result.Model != "" {
+		model = result.Model
+	}
+
+	// Record the run's aggregated LLM request and every step of the run,
+	// including failed runs so they can be audited later
+	usage := result.MainUsage()
+	usage.Model = model
+	llmRequestID, err := store.RecordLLMRequest(ctx, sessionID, userID, userMessageID, usage)
 	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to record llm request")
+	}
+	for _, summary := range result.Summaries {
+		if _, err := store.RecordLLMRequest(ctx, sessionID, userID, userMessageID, summary); err != nil {
+			log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to record summarization request")
+		}
+	}
+	if err := store.RecordAgentSteps(ctx, sessionID, userMessageID, llmRequestID, result.StepResults); err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Int("steps", len(result.StepResults)).Msg("unable to record agent steps")
+	}
+
+	if runErr != nil {
 		// Check if it's a step limit termination
 		var termErr *agent.TerminatingErr
-		if errors.As(err, &termErr) && termErr.Reason == agent.ReasonStepLimit {
+		if errors.As(runErr, &termErr) && termErr.Reason == agent.ReasonStepLimit {
 			log.Warn().
 				Int64("chat_id", chatID).
 				Int("steps", result.Steps).
 				Msg("agentic run hit step limit")
+		} else if errors.As(runErr, &termErr) && termErr.Reason == agent.ReasonBudgetExceeded {
+			log.Warn().
+				Int64("chat_id", chatID).
+				Int("steps", result.Steps).
+				Int("total_tokens", result.TokenUsage.TotalTokens).
+				Float64("cost_usd", result.TokenUsage.CostUSD).
+				Msg("agentic run stopped at budget")
+			result.Response = strings.TrimSpace(result.Response + "\n\n(Stopped early: continuing would exceed your remaining budget.)")
 		} else {
-			log.Error().Err(err).Int64("chat_id", chatID).Msg("agentic run failed")
+			log.Error().Err(runErr).Int64("chat_id", chatID).Msg("agentic run failed")
 			tg.SendMessage(ctx, &tbot.SendMessageParams{
-				ChatID: chatID,
-				Text:   "Sorry, I encountered an error while processing your request.",
+				ChatID:          chatID,
+				MessageThreadID: threadID,
+				Text:            "Sorry, I encountered an error while processing your request.",
 			})
-			return
+			return ""
 		}
 	}
 
@@ -347,25 +732,37 @@ func handleAgenticMessage(
 		Int64("chat_id", chatID).
 		Int64("session_id", sessionID).
 		Int("steps", result.Steps).
+		Str("model", model).
 		Int("input_tokens", result.TokenUsage.InputTokens).
 		Int("output_tokens", result.TokenUsage.OutputTokens).
 		Int("total_tokens", result.TokenUsage.TotalTokens).
+		Float64("cost_usd", result.TokenUsage.CostUSD).
+		Int("summaries", len(result.Summaries)).
 		Str("terminated_by", string(result.Reason)).
 		Msg("agentic run complete")
 
-	// Record LLM request with aggregated tokens
-	if err := store.RecordLLMRequest(ctx, sessionID, userMessageID, result.TokenUsage.InputTokens, result.TokenUsage.OutputTokens, result.TokenUsage.TotalTokens, cfg.Model); err != nil {
-		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to record llm request")
-	}
-
 	// Store the assistant response
 	if _, err := store.AddMessage(ctx, sessionID, agent.RoleAssistant, result.Response); err != nil {
 		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to store bot message")
 	}
 
 	// Send response to user
-	tg.SendMessage(ctx, &tbot.SendMessageParams{
-		ChatID: chatID,
-		Text:   result.Response,
+	sendReply(ctx, tg, chatID, threadID, result.Response, log)
+	return result.Response
+}
+
+// sendReply sends the model's answer, or emptyReplyText if it's empty, which
+// Telegram would refuse.
+func sendReply(ctx context.Context, tg *tbot.Bot, chatID int64, threadID int, text string, log *zerolog.Logger) {
+	if strings.TrimSpace(text) == "" {
+		text = emptyReplyText
+	}
+	_, err := tg.SendMessage(ctx, &tbot.SendMessageParams{
+		ChatID:          chatID,
+		MessageThreadID: threadID,
+		Text:            text,
 	})
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to send message")
+	}
 }
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	tbot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/j0lvera/banray/internal/config"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+	"github.com/rs/zerolog"
+)
+
+// botIdentity is who the bot is on Telegram, learned when the bot starts.
+type botIdentity struct {
+	id       int64
+	username string
+}
+
+// addressed reports whether a group message is meant for the bot: it
+// mentions the bot, replies to one of its messages, or is a command sent to
+// it by name. Bare commands in groups could be for any bot, so the known
+// ones are handled by the command registry and the rest are ignored. Photos
+// mention the bot in their caption.
+func (b *botIdentity) addressed(message *models.Message) bool {
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == b.id {
+		return true
+	}
+	text, entities := message.Text, message.Entities
+	if text == "" {
+		text, entities = message.Caption, message.CaptionEntities
+	}
+	for _, entity := range entities {
+		switch entity.Type {
+		case models.MessageEntityTypeMention:
+			if strings.EqualFold(entityText(text, entity), "@"+b.username) {
+				return true
+			}
+		case models.MessageEntityTypeTextMention:
+			if entity.User != nil && entity.User.ID == b.id {
+				return true
+			}
+		case models.MessageEntityTypeBotCommand:
+			_, bot, _ := strings.Cut(entityText(text, entity), "@")
+			if b.username != "" && strings.EqualFold(bot, b.username) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// entityText returns the part of text an entity covers. Telegram measures
+// entities in UTF-16 code units.
+func entityText(text string, entity models.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if entity.Offset < 0 || entity.Length < 0 || entity.Offset+entity.Length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+}
+
+// isGroup reports whether a chat of the given type is shared by several users.
+func isGroup(chatType models.ChatType) bool {
+	return chatType == models.ChatTypeGroup || chatType == models.ChatTypeSupergroup
+}
+
+// messageThread returns the forum topic a message was sent in, or 0 outside
+// of topics. Replies in ordinary supergroups carry a thread ID too, but they
+// belong to the chat's conversation.
+func messageThread(message *models.Message) int {
+	if !message.IsTopicMessage {
+		return 0
+	}
+	return message.MessageThreadID
+}
+
+// chatTitle returns a chat's title, or the user's name for private chats.
+func chatTitle(chat models.Chat) string {
+	if chat.Title != "" {
+		return chat.Title
+	}
+	return strings.TrimSpace(chat.FirstName + " " + chat.LastName)
+}
+
+// speakerName returns the name a group message is attributed to in history.
+func speakerName(user *models.User) string {
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		return name
+	}
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return fmt.Sprintf("User %d", user.ID)
+}
+
+// identifyChat upserts the chat a message was sent in. It replies and
+// returns false when the chat can't be stored.
+func identifyChat(
+	ctx context.Context,
+	tg *tbot.Bot,
+	message *models.Message,
+	chats *agent.ChatStore,
+	log *zerolog.Logger,
+) (*dbgen.DataChat, bool) {
+	chat, err := chats.UpsertChat(ctx, message.Chat.ID, string(message.Chat.Type), chatTitle(message.Chat))
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", message.Chat.ID).Msg("unable to upsert chat")
+		tg.SendMessage(ctx, &tbot.SendMessageParams{
+			ChatID:          message.Chat.ID,
+			MessageThreadID: messageThread(message),
+			Text:            "Sorry, I encountered an error. Please try again.",
+		})
+		return nil, false
+	}
+	return chat, true
+}
+
+// chatProfile returns the profile messages in a chat are answered with: the
+// one picked for the group in group chats, the user's own in private chats.
+func chatProfile(cfg *config.Config, chat *dbgen.DataChat, user *dbgen.DataUser, log *zerolog.Logger) config.Profile {
+	if !isGroup(models.ChatType(chat.Type)) {
+		return userProfile(cfg, user, log)
+	}
+	profile, ok := cfg.Profile(chat.Profile.String)
+	if !ok {
+		log.Warn().Int64("chat_id", chat.TelegramID).Str("profile", chat.Profile.String).Msg("unknown profile, using default")
+		profile, _ = cfg.Profile(config.DefaultProfile)
+	}
+	return profile
+}
+
+// canManageChat reports whether the sender of a command may change what the
+// whole chat shares, like its profile or session. Anyone may in private
+// chats; in groups only the group's administrators and bot admins may.
+func canManageChat(ctx context.Context, req commandRequest, log *zerolog.Logger) bool {
+	if !req.group || req.role.IsAdmin() {
+		return true
+	}
+	member, err := req.tg.GetChatMember(ctx, &tbot.GetChatMemberParams{
+		ChatID: req.chatID,
+		UserID: req.user.TelegramID,
+	})
+	if err != nil {
+		log.Warn().Err(err).Int64("chat_id", req.chatID).Int64("user_id", req.user.ID).Msg("unable to get chat member")
+		return false
+	}
+	return member.Type == models.ChatMemberTypeOwner || member.Type == models.ChatMemberTypeAdministrator
+}
+
+// groupPrompt tells the model it is in a group chat, where user messages
+// start with the name of who sent them.
+func groupPrompt(chat *dbgen.DataChat) string {
+	where := "a Telegram group"
+	if chat.Title.Valid {
+		where = fmt.Sprintf("the Telegram group %q", chat.Title.String)
+	}
+	return fmt.Sprintf("\n\n## Group Chat\n\nYou are in %s. Several people talk to you here; each user message starts with the name of who sent it. Address people by name when it helps, and don't prefix your own replies with a name.", where)
+}
@@ -0,0 +1,204 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	tbot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/j0lvera/banray/internal/agent"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+	"github.com/rs/zerolog"
+)
+
+// commandRequest carries what a command handler needs.
+type commandRequest struct {
+	tg       *tbot.Bot
+	chatID   int64
+	threadID int  // Forum topic the command came from, 0 outside of topics
+	group    bool // Sent in a group chat
+	chat     *dbgen.DataChat
+	user     *dbgen.DataUser
+	role     agent.UserRole
+	args     string // Text after the command, trimmed
+}
+
+// reply sends a plain text message to the chat, and topic, the command came from.
+func (r commandRequest) reply(ctx context.Context, text string) {
+	r.tg.SendMessage(ctx, &tbot.SendMessageParams{
+		ChatID:          r.chatID,
+		MessageThreadID: r.threadID,
+		Text:            text,
+	})
+}
+
+// command is a slash command the bot understands.
+type command struct {
+	name        string // Without the leading slash
+	description string // Shown in /help and Telegram's command menu
+	adminOnly   bool
+	hidden      bool // Left out of /help and the command menu
+	handler     func(ctx context.Context, req commandRequest)
+}
+
+// commandIdentifier resolves the sender of a command and the chat it was
+// sent in, replying and returning false when they may not use the bot.
+type commandIdentifier func(ctx context.Context, tg *tbot.Bot, message *models.Message) (*dbgen.DataUser, *dbgen.DataChat, agent.UserRole, bool)
+
+// commandRegistry holds the bot's slash commands, registers a Telegram
+// handler for each and publishes them to the command menu.
+type commandRegistry struct {
+	commands []command
+	me       *botIdentity // Learned in publish
+	log      *zerolog.Logger
+}
+
+func newCommandRegistry(me *botIdentity, log *zerolog.Logger) *commandRegistry {
+	return &commandRegistry{me: me, log: log}
+}
+
+// register adds commands in the order they should be listed.
+func (r *commandRegistry) register(cmds ...command) {
+	r.commands = append(r.commands, cmds...)
+}
+
+// install registers a handler for every command. Messages that aren't
+// commands, or name unknown ones, still go to the default handler.
+func (r *commandRegistry) install(tg *tbot.Bot, identify commandIdentifier) {
+	for _, cmd := range r.commands {
+		tg.RegisterHandlerMatchFunc(r.matcher(cmd.name), func(ctx context.Context, tg *tbot.Bot, update *models.Update) {
+			r.run(ctx, tg, update.Message, cmd, identify)
+		})
+	}
+}
+
+// run identifies the sender and runs the command if they may use it.
+func (r *commandRegistry) run(ctx context.Context, tg *tbot.Bot, message *models.Message, cmd command, identify commandIdentifier) {
+	user, chat, role, ok := identify(ctx, tg, message)
+	if !ok {
+		return
+	}
+
+	_, args, _ := r.parse(message.Text)
+	req := commandRequest{
+		tg:       tg,
+		chatID:   message.Chat.ID,
+		threadID: messageThread(message),
+		group:    isGroup(message.Chat.Type),
+		chat:     chat,
+		user:     user,
+		role:     role,
+		args:     args,
+	}
+	if cmd.adminOnly && !role.IsAdmin() {
+		req.reply(ctx, "Unknown command. Send /help to see what I can do.")
+		return
+	}
+	// Admin commands report on every user, which the rest of a group mustn't see
+	if cmd.adminOnly && req.group {
+		req.reply(ctx, adminPrivateOnly)
+		return
+	}
+
+	r.log.Info().Int64("chat_id", req.chatID).Int64("user_id", user.ID).Str("command", cmd.name).Msg("handling command")
+	cmd.handler(ctx, req)
+}
+
+// matcher matches messages that invoke the named command.
+func (r *commandRegistry) matcher(name string) tbot.MatchFunc {
+	return func(update *models.Update) bool {
+		if update.Message == nil || update.Message.From == nil {
+			return false
+		}
+		n, _, ok := r.parse(update.Message.Text)
+		return ok && n == name
+	}
+}
+
+// parse splits "/name@bot args" into its name and arguments. Commands
+// addressed to another bot are not ours.
+func (r *commandRegistry) parse(text string) (name, args string, ok bool) {
+	name, bot, args, ok := parseCommand(text)
+	if !ok || (bot != "" && r.me.username != "" && !strings.EqualFold(bot, r.me.username)) {
+		return "", "", false
+	}
+	return name, args, true
+}
+
+// publish sets Telegram's command menu: user commands for everyone and every
+// command for the allowlisted admins. It also learns who the bot is, so it
+// must run before updates are processed.
+func (r *commandRegistry) publish(ctx context.Context, tg *tbot.Bot, admins []int64) {
+	me, err := tg.GetMe(ctx)
+	if err != nil {
+		r.log.Warn().Err(err).Msg("unable to get bot username")
+	} else {
+		r.me.id, r.me.username = me.ID, me.Username
+	}
+
+	if _, err := tg.SetMyCommands(ctx, &tbot.SetMyCommandsParams{
+		Commands: r.menu(agent.UserRoleUser),
+		Scope:    &models.BotCommandScopeDefault{},
+	}); err != nil {
+		r.log.Warn().Err(err).Msg("unable to publish bot commands")
+	}
+
+	for _, admin := range admins {
+		if _, err := tg.SetMyCommands(ctx, &tbot.SetMyCommandsParams{
+			Commands: r.menu(agent.UserRoleAdmin),
+			Scope:    &models.BotCommandScopeChat{ChatID: admin},
+		}); err != nil {
+			r.log.Warn().Err(err).Int64("telegram_id", admin).Msg("unable to publish admin commands")
+		}
+	}
+}
+
+// visible returns the commands a role may see.
+func (r *commandRegistry) visible(role agent.UserRole) []command {
+	var cmds []command
+	for _, cmd := range r.commands {
+		if cmd.hidden || (cmd.adminOnly && !role.IsAdmin()) {
+			continue
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// menu returns the command menu entries for a role.
+func (r *commandRegistry) menu(role agent.UserRole) []models.BotCommand {
+	var entries []models.BotCommand
+	for _, cmd := range r.visible(role) {
+		entries = append(entries, models.BotCommand{Command: cmd.name, Description: cmd.description})
+	}
+	return entries
+}
+
+// help lists the commands a role may use.
+func (r *commandRegistry) help(role agent.UserRole) string {
+	var b strings.Builder
+	b.WriteString("Send me a message to chat. Commands:\n")
+	for _, cmd := range r.visible(role) {
+		fmt.Fprintf(&b, "\n/%s - %s", cmd.name, cmd.description)
+	}
+	return b.String()
+}
+
+// parseCommand splits "/name@bot args" into its parts.
+func parseCommand(text string) (name, bot, args string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", "", "", false
+	}
+
+	head := text[1:]
+	if i := strings.IndexFunc(head, unicode.IsSpace); i >= 0 {
+		head, args = head[:i], head[i:]
+	}
+	name, bot, _ = strings.Cut(head, "@")
+	if name == "" {
+		return "", "", "", false
+	}
+	return strings.ToLower(name), bot, strings.TrimSpace(args), true
+}
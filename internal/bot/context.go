@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/j0lvera/banray/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// retrieveContext returns the chunks of a context directory most relevant to
+// text, formatted for the system prompt, or an empty string when none are.
+func retrieveContext(
+	ctx context.Context,
+	chatID int64,
+	text string,
+	dir string,
+	index *agent.ContextIndex,
+	cfg *config.Config,
+	log *zerolog.Logger,
+) string {
+	if cfg.ContextTopK <= 0 {
+		return ""
+	}
+
+	chunks, err := index.Search(ctx, dir, text, cfg.ContextTopK)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to search context index")
+		return ""
+	}
+	if len(chunks) > 0 {
+		log.Debug().Int64("chat_id", chatID).Int("chunks", len(chunks)).Msg("context retrieved")
+	}
+	return agent.ContextPrompt(chunks)
+}
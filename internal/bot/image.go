@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+
+	tbot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// maxImageBytes is the largest image sent to the model, which is what
+	// Anthropic accepts. Telegram compresses photos well below it.
+	maxImageBytes = 5 << 20
+
+	// imageCacheSize is how many downloaded images are kept, so replaying
+	// recent history doesn't download them again on every message.
+	imageCacheSize = 32
+)
+
+// imageTypes are the image formats every provider accepts. Images sent as
+// files in other formats are ignored.
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// messageText returns the text of a message, or the caption of a photo or file.
+func messageText(message *models.Message) string {
+	if message.Text != "" {
+		return message.Text
+	}
+	return message.Caption
+}
+
+// messageImages returns the images of a message, without their data: the
+// largest size of a photo small enough to send, or a file that is an image.
+func messageImages(message *models.Message) []agent.Image {
+	var images []agent.Image
+	// Telegram lists the sizes of a photo from smallest to largest
+	for _, size := range slices.Backward(message.Photo) {
+		if size.FileSize <= maxImageBytes {
+			images = append(images, agent.Image{
+				MIMEType: "image/jpeg",
+				FileID:   size.FileID,
+				Width:    size.Width,
+				Height:   size.Height,
+			})
+			break
+		}
+	}
+	if doc := message.Document; doc != nil && slices.Contains(imageTypes, doc.MimeType) && doc.FileSize <= maxImageBytes {
+		images = append(images, agent.Image{MIMEType: doc.MimeType, FileID: doc.FileID})
+	}
+	return images
+}
+
+// imageLoader downloads images from Telegram, keeping the most recent ones.
+type imageLoader struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string][]byte // File ID -> data
+	order []string          // File IDs, oldest first
+}
+
+// newImageLoader creates an image loader with an empty cache.
+func newImageLoader() *imageLoader {
+	return &imageLoader{
+		client: http.DefaultClient,
+		cache:  make(map[string][]byte),
+	}
+}
+
+// load returns the image with its data, downloading it unless it's cached.
+func (l *imageLoader) load(ctx context.Context, tg *tbot.Bot, image agent.Image) (agent.Image, error) {
+	l.mu.Lock()
+	data, ok := l.cache[image.FileID]
+	l.mu.Unlock()
+	if ok {
+		image.Data = data
+		return image, nil
+	}
+
+	file, err := tg.GetFile(ctx, &tbot.GetFileParams{FileID: image.FileID})
+	if err != nil {
+		return image, fmt.Errorf("failed to get file: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tg.FileDownloadLink(file), nil)
+	if err != nil {
+		return image, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		// The error holds the download link, which holds the bot token
+		return image, fmt.Errorf("failed to download file %s", file.FilePath)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return image, fmt.Errorf("failed to download file %s: status %d", file.FilePath, resp.StatusCode)
+	}
+	data, err = io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return image, fmt.Errorf("failed to read file %s: %w", file.FilePath, err)
+	}
+	if len(data) > maxImageBytes {
+		return image, fmt.Errorf("file %s is larger than %d bytes", file.FilePath, maxImageBytes)
+	}
+
+	l.mu.Lock()
+	if _, ok := l.cache[image.FileID]; !ok {
+		l.cache[image.FileID] = data
+		l.order = append(l.order, image.FileID)
+		if len(l.order) > imageCacheSize {
+			delete(l.cache, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+	l.mu.Unlock()
+
+	image.Data = data
+	return image, nil
+}
+
+// loadHistory loads the images of the messages in a session's history.
+// Images that can't be downloaded anymore are left out, so the rest of the
+// conversation still replays. messages is not modified.
+func (l *imageLoader) loadHistory(ctx context.Context, tg *tbot.Bot, chatID int64, messages []agent.Message, log *zerolog.Logger) []agent.Message {
+	loaded := slices.Clone(messages)
+	for i, msg := range loaded {
+		if len(msg.Images) == 0 {
+			continue
+		}
+		images := make([]agent.Image, 0, len(msg.Images))
+		for _, image := range msg.Images {
+			if !image.Loaded() {
+				var err error
+				image, err = l.load(ctx, tg, image)
+				if err != nil {
+					log.Warn().Err(err).Int64("chat_id", chatID).Msg("unable to load image from history")
+					continue
+				}
+			}
+			images = append(images, image)
+		}
+		loaded[i].Images = images
+	}
+	return loaded
+}
@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	tbot "github.com/go-telegram/bot"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// editInterval keeps message edits within Telegram's per-chat rate limits.
+	editInterval = 1500 * time.Millisecond
+
+	// maxMessageLength is Telegram's limit for a single text message.
+	maxMessageLength = 4096
+
+	// emptyReplyText replaces empty answers, which Telegram refuses to send.
+	emptyReplyText = "Sorry, I didn't get a response. Please try again."
+)
+
+// liveMessage is a Telegram message that is edited in place as its text
+// changes. Updates are coalesced and flushed at most once per interval.
+type liveMessage struct {
+	tg        *tbot.Bot
+	chatID    int64
+	threadID  int // Forum topic, 0 outside of topics
+	messageID int
+	interval  time.Duration
+	log       *zerolog.Logger
+
+	mu    sync.Mutex
+	text  string // Latest text
+	shown string // Text currently displayed in the chat
+	until time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newLiveMessage sends a placeholder message and starts flushing updates to it.
+func newLiveMessage(ctx context.Context, tg *tbot.Bot, chatID int64, threadID int, placeholder string, log *zerolog.Logger) (*liveMessage, error) {
+	msg, err := tg.SendMessage(ctx, &tbot.SendMessageParams{
+		ChatID:          chatID,
+		MessageThreadID: threadID,
+		Text:            placeholder,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &liveMessage{
+		tg:        tg,
+		chatID:    chatID,
+		threadID:  threadID,
+		messageID: msg.ID,
+		interval:  editInterval,
+		log:       log,
+		text:      placeholder,
+		shown:     placeholder,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go m.loop(ctx)
+
+	return m, nil
+}
+
+// Set replaces the message text; the chat is updated on the next flush.
+func (m *liveMessage) Set(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.text = text
+}
+
+// Finish stops the periodic flushing and sets the final text. Text longer
+// than a single Telegram message continues in follow-up messages, and empty
+// text is replaced with emptyReplyText so the placeholder doesn't stay.
+func (m *liveMessage) Finish(ctx context.Context, text string) {
+	close(m.stop)
+	<-m.done
+
+	// Wait out any rate limit so the final text is not dropped
+	m.mu.Lock()
+	wait := time.Until(m.until)
+	m.mu.Unlock()
+	if wait > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+
+	if strings.TrimSpace(text) == "" {
+		text = emptyReplyText
+	}
+	parts := splitMessage(text)
+	if err := m.edit(ctx, parts[0]); err != nil {
+		// Fall back to a new message so the user still gets the answer
+		m.send(ctx, parts[0])
+	}
+	for _, part := range parts[1:] {
+		m.send(ctx, part)
+	}
+}
+
+// send sends text as a new message in the same chat and topic.
+func (m *liveMessage) send(ctx context.Context, text string) {
+	_, err := m.tg.SendMessage(ctx, &tbot.SendMessageParams{
+		ChatID:          m.chatID,
+		MessageThreadID: m.threadID,
+		Text:            text,
+	})
+	if err != nil {
+		m.log.Error().Err(err).Int64("chat_id", m.chatID).Msg("unable to send message")
+	}
+}
+
+// loop flushes pending text until Finish is called.
+func (m *liveMessage) loop(ctx context.Context) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			text := m.text
+			m.mu.Unlock()
+			m.edit(ctx, truncateMessage(text))
+		}
+	}
+}
+
+// edit updates the message if the text changed and no rate limit is in effect.
+func (m *liveMessage) edit(ctx context.Context, text string) error {
+	m.mu.Lock()
+	if text == m.shown || strings.TrimSpace(text) == "" || time.Now().Before(m.until) {
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	_, err := m.tg.EditMessageText(ctx, &tbot.EditMessageTextParams{
+		ChatID:    m.chatID,
+		MessageID: m.messageID,
+		Text:      text,
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		var tooMany *tbot.TooManyRequestsError
+		if errors.As(err, &tooMany) {
+			m.until = time.Now().Add(time.Duration(tooMany.RetryAfter) * time.Second)
+		}
+		m.log.Debug().Err(err).Int64("chat_id", m.chatID).Msg("unable to edit live message")
+		return err
+	}
+	m.shown = text
+	return nil
+}
+
+// truncateMessage shortens text to fit a single Telegram message, keeping the start.
+func truncateMessage(text string) string {
+	return truncate(text, maxMessageLength)
+}
+
+// splitMessage splits text into chunks that fit Telegram's message limit.
+func splitMessage(text string) []string {
+	runes := []rune(text)
+	if len(runes) <= maxMessageLength {
+		return []string{text}
+	}
+
+	var parts []string
+	for len(runes) > 0 {
+		n := min(len(runes), maxMessageLength)
+		parts = append(parts, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return parts
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/j0lvera/banray/internal/config"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// memoryTimeout bounds extracting memories from one exchange.
+	memoryTimeout = time.Minute
+
+	// knownMemories is how many related memories the extractor sees, so it
+	// doesn't propose them again.
+	knownMemories = 20
+)
+
+// recallMemories returns the system prompt section with the user's memories
+// most relevant to text, or an empty string when there are none.
+func recallMemories(
+	ctx context.Context,
+	chatID int64,
+	userID int64,
+	text string,
+	memories *agent.MemoryStore,
+	cfg *config.Config,
+	log *zerolog.Logger,
+) string {
+	if !cfg.Memories || cfg.MemoryLimit <= 0 {
+		return ""
+	}
+
+	recalled, err := memories.SearchMemories(ctx, userID, text, cfg.MemoryLimit)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to search memories")
+		return ""
+	}
+	if len(recalled) > 0 {
+		log.Debug().Int64("chat_id", chatID).Int("memories", len(recalled)).Msg("memories recalled")
+	}
+	return agent.MemoryPrompt(recalled)
+}
+
+// rememberExchange stores the facts worth remembering from an exchange. It
+// runs once the response is out, so it never delays it.
+func rememberExchange(
+	ctx context.Context,
+	chatID int64,
+	userID int64,
+	sessionID int64,
+	userMessageID int64,
+	userText string,
+	response string,
+	model string,
+	querier agent.Querier,
+	memories *agent.MemoryStore,
+	store *agent.Store,
+	cfg *config.Config,
+	log *zerolog.Logger,
+) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), memoryTimeout)
+	defer cancel()
+
+	known, err := memories.SearchMemories(ctx, userID, userText+"\n"+response, knownMemories)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to search memories")
+		return
+	}
+
+	if cfg.SummarizerModel != "" {
+		model = cfg.SummarizerModel
+	}
+	facts, usage, err := agent.ExtractMemories(ctx, querier, model, agent.Pricing(cfg.Pricing), known, userText, response)
+	if usage.TotalTokens > 0 {
+		if _, err := store.RecordLLMRequest(ctx, sessionID, userID, userMessageID, usage); err != nil {
+			log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to record memory extraction request")
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Int64("chat_id", chatID).Msg("unable to extract memories")
+		return
+	}
+
+	added := 0
+	for _, fact := range facts {
+		ok, err := memories.AddMemory(ctx, userID, userMessageID, fact)
+		if err != nil {
+			log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to store memory")
+			continue
+		}
+		if ok {
+			added++
+		}
+	}
+	if added > 0 {
+		log.Info().Int64("chat_id", chatID).Int64("user_id", userID).Int("memories", added).Msg("memories added")
+	}
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/j0lvera/banray/internal/agent"
+)
+
+const (
+	// maxProgressCommand caps how much of the current command is shown.
+	maxProgressCommand = 300
+
+	// maxProgressTail caps how much trailing output is shown.
+	maxProgressTail = 800
+)
+
+// progressWriter receives Runner output and mirrors it into a single status
+// message: the current step, the command being run and the tail of its output.
+// The runner writes each command as one "$ <command>" write, which marks the
+// start of a new step.
+type progressWriter struct {
+	live *liveMessage
+
+	mu      sync.Mutex
+	step    int
+	command string
+	output  string
+}
+
+// newProgressWriter creates a progress writer backed by a live message.
+func newProgressWriter(live *liveMessage) *progressWriter {
+	return &progressWriter{live: live}
+}
+
+// Write implements io.Writer.
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	text := string(b)
+	if strings.HasPrefix(text, "$ ") {
+		p.step++
+		p.command = strings.TrimSpace(strings.TrimPrefix(text, "$ "))
+		p.output = ""
+	} else {
+		p.output = tail(p.output+text, maxProgressTail)
+	}
+
+	p.live.Set(p.render())
+	return len(b), nil
+}
+
+// Finish replaces the status message with a summary of how the run ended.
+func (p *progressWriter) Finish(ctx context.Context, result agent.RunResult, err error) {
+	var status string
+	switch {
+	case result.Reason == agent.ReasonComplete:
+		status = fmt.Sprintf("Done in %s.", pluralSteps(result.Steps))
+	case result.Reason == agent.ReasonStepLimit:
+		status = fmt.Sprintf("Stopped after reaching the step limit (%s).", pluralSteps(result.Steps))
+	case result.Reason == agent.ReasonBudgetExceeded:
+		usage := result.TokenUsage
+		status = fmt.Sprintf("Stopped after %s because your budget was reached: %d tokens (%d in, %d out), %s.",
+			pluralSteps(result.Steps), usage.TotalTokens, usage.InputTokens, usage.OutputTokens, formatUSD(usage.CostUSD))
+	case err != nil:
+		status = fmt.Sprintf("Run failed after %s.", pluralSteps(result.Steps))
+	default:
+		status = fmt.Sprintf("Finished after %s.", pluralSteps(result.Steps))
+	}
+
+	p.mu.Lock()
+	if p.command != "" {
+		status += "\nLast command:\n$ " + truncate(p.command, maxProgressCommand)
+	}
+	p.mu.Unlock()
+
+	p.live.Finish(ctx, status)
+}
+
+// render formats the current progress. Callers must hold p.mu.
+func (p *progressWriter) render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Step %d: running\n$ %s", p.step, truncate(p.command, maxProgressCommand))
+	if out := strings.TrimSpace(p.output); out != "" {
+		b.WriteString("\n\n")
+		b.WriteString(out)
+	}
+	return b.String()
+}
+
+// pluralSteps formats a step count.
+func pluralSteps(n int) string {
+	if n == 1 {
+		return "1 step"
+	}
+	return fmt.Sprintf("%d steps", n)
+}
+
+// truncate shortens s to at most n runes, keeping the start.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// tail shortens s to at most n runes, keeping the end.
+func tail(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return "…" + string(runes[len(runes)-n+1:])
+}
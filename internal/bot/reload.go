@@ -0,0 +1,290 @@
+package bot
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/j0lvera/banray/internal/config"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// reloadDelay lets a burst of file events settle, like an editor writing
+	// a temporary file and renaming it, before reloading once.
+	reloadDelay = 500 * time.Millisecond
+
+	// reindexTimeout bounds reindexing the context directories.
+	reindexTimeout = time.Minute
+)
+
+// reloader swaps in new prompts and profiles when config.toml changes and
+// reindexes the context directories when their files change, without a
+// restart. SIGHUP reloads both. Sessions keep the prompts they started with,
+// so only new sessions see new prompts; retrieved context is always current.
+type reloader struct {
+	cfg         *config.Config
+	index       *agent.ContextIndex
+	log         *zerolog.Logger
+	configPath  string   // Absolute path of config.toml
+	contextDirs []string // Absolute paths of the context directories, refreshed by watch
+
+	watcher *fsnotify.Watcher
+	signals chan os.Signal
+	done    chan struct{}
+	wg      sync.WaitGroup
+	stats   agent.IndexStats // What the last reindex stored
+}
+
+// newReloader creates a reloader for the config file and context directories.
+func newReloader(cfg *config.Config, index *agent.ContextIndex, log *zerolog.Logger) *reloader {
+	return &reloader{
+		cfg:        cfg,
+		index:      index,
+		log:        log,
+		configPath: absPath(cfg.ConfigPath()),
+		signals:    make(chan os.Signal, 1),
+		done:       make(chan struct{}),
+	}
+}
+
+// absPath returns the absolute form of path, or path itself when it can't
+// be resolved.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// start indexes the context directories and starts watching for changes and
+// SIGHUP. The bot runs without hot reload when the watcher can't be set up.
+func (r *reloader) start(ctx context.Context) {
+	r.reindex(ctx)
+
+	signal.Notify(r.signals, syscall.SIGHUP)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.log.Error().Err(err).Msg("unable to watch config files, reload with SIGHUP")
+	} else {
+		r.watcher = watcher
+		r.watch()
+	}
+
+	r.wg.Add(1)
+	go r.run()
+}
+
+// stop stops watching and waits for a reload in progress to finish.
+func (r *reloader) stop() {
+	signal.Stop(r.signals)
+	close(r.done)
+	if r.watcher != nil {
+		r.watcher.Close()
+	}
+	r.wg.Wait()
+}
+
+// watch adds the directory of config.toml, which catches editors replacing
+// the file, and every directory of the context trees, which fsnotify doesn't
+// watch recursively. Profiles may add context directories, so they're read
+// from the config again each time. Adding a watched directory again is
+// harmless; directories no profile uses anymore stay watched until restart,
+// but their events are ignored.
+func (r *reloader) watch() {
+	if err := r.watcher.Add(filepath.Dir(r.configPath)); err != nil {
+		r.log.Warn().Err(err).Str("path", r.configPath).Msg("unable to watch config file")
+	}
+
+	r.contextDirs = r.contextDirs[:0]
+	for _, dir := range r.cfg.ContextDirs() {
+		contextDir := absPath(dir)
+		r.contextDirs = append(r.contextDirs, contextDir)
+
+		err := filepath.WalkDir(contextDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() {
+				return nil
+			}
+			if path != contextDir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return r.watcher.Add(path)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			r.log.Warn().Err(err).Str("path", contextDir).Msg("unable to watch context directory")
+		}
+	}
+}
+
+// run reloads on SIGHUP, and once file events have settled.
+func (r *reloader) run() {
+	defer r.wg.Done()
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if r.watcher != nil {
+		events, errs = r.watcher.Events, r.watcher.Errors
+	}
+
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	configChanged, contextChanged := false, false
+
+	for {
+		select {
+		case <-r.done:
+			timer.Stop()
+			return
+		case <-r.signals:
+			r.log.Info().Msg("reloading on SIGHUP")
+			r.reloadPrompts()
+			if r.watcher != nil {
+				r.watch()
+			}
+			r.reindex(context.Background())
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			switch {
+			case event.Name == r.configPath:
+				configChanged = true
+			case r.inContextDir(event):
+				contextChanged = true
+				if event.Has(fsnotify.Create) {
+					// New subdirectories need watches of their own
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						r.watch()
+					}
+				}
+			default:
+				continue
+			}
+			timer.Reset(reloadDelay)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			r.log.Warn().Err(err).Msg("config watcher error")
+		case <-timer.C:
+			// Profiles may have added or dropped context directories
+			if configChanged && r.reloadPrompts() {
+				if r.watcher != nil {
+					r.watch()
+				}
+				contextChanged = true
+			}
+			if contextChanged {
+				r.reindex(context.Background())
+			}
+			configChanged, contextChanged = false, false
+		}
+	}
+}
+
+// inContextDir reports whether an event may change the context index: a
+// markdown file, or a directory appearing or going away, within one of the
+// context directories. Hidden files such as editor swap files are ignored.
+func (r *reloader) inContextDir(event fsnotify.Event) bool {
+	for _, dir := range r.contextDirs {
+		if inDir(dir, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDir reports whether an event may change the chunks of one context
+// directory.
+func inDir(dir string, event fsnotify.Event) bool {
+	rel, err := filepath.Rel(dir, event.Name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	if rel == "." {
+		return true
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	if filepath.Ext(event.Name) == ".md" {
+		return true
+	}
+	// Directories can't be told apart once removed or renamed
+	return event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)
+}
+
+// reloadPrompts swaps in the prompts and profiles of config.toml, keeping
+// the current ones when it doesn't validate. It reports whether profiles
+// changed, which may change the context directories.
+func (r *reloader) reloadPrompts() bool {
+	changes, err := r.cfg.ReloadPrompts()
+	if err != nil {
+		r.log.Error().Err(err).Msg("unable to reload config, keeping current prompts")
+		return false
+	}
+
+	event := r.log.Debug()
+	if changes.Changed() {
+		event = r.log.Info()
+	}
+	if changes.Simple != "" {
+		event = event.Str("simple_prompt", changes.Simple)
+	}
+	if changes.Agent != "" {
+		event = event.Str("agent_prompt", changes.Agent)
+	}
+	if changes.Tools != "" {
+		event = event.Str("agent_tools_prompt", changes.Tools)
+	}
+	if len(changes.Profiles) > 0 {
+		event = event.Strs("profiles", changes.Profiles)
+	}
+	event.Msg("config reloaded")
+
+	if len(changes.Restart) > 0 {
+		r.log.Warn().Strs("sections", changes.Restart).Msg("config sections changed that only take effect after a restart")
+	}
+	return len(changes.Profiles) > 0
+}
+
+// reindex indexes the context directories again and logs how the index changed.
+func (r *reloader) reindex(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, reindexTimeout)
+	defer cancel()
+
+	stats, err := r.index.Reindex(ctx)
+	if err != nil {
+		r.log.Error().Err(err).Msg("unable to index context directories, keeping current index")
+		return
+	}
+
+	previous := r.stats
+	r.stats = stats
+
+	r.log.Info().
+		Int("dirs", stats.Dirs).
+		Int("files", stats.Files).
+		Int("chunks", stats.Chunks).
+		Int("tokens", stats.Tokens).
+		Int("files_delta", stats.Files-previous.Files).
+		Int("chunks_delta", stats.Chunks-previous.Chunks).
+		Int("tokens_delta", stats.Tokens-previous.Tokens).
+		Msg("context directories indexed")
+}
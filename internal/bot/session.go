@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/j0lvera/banray/internal/config"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+	"github.com/rs/zerolog"
+)
+
+// rotateSession ends a session whose history has outgrown its budget and
+// starts a new one that continues from a summary of it. The summary is
+// stored on the ended session and as the first message of the new one, so
+// the next rotation folds it into its own. The session is kept when it still
+// fits or the summary fails, leaving the history to be trimmed instead. The
+// new session starts in the same chat and topic with the current prompts of
+// the profile.
+func rotateSession(
+	ctx context.Context,
+	chatID int64,
+	userID int64,
+	session *dbgen.DataSession,
+	profile config.Profile,
+	model string,
+	systemPrompt string,
+	querier agent.Querier,
+	executors *agent.ExecutorPool,
+	contexts *agent.ContextManager,
+	store *agent.Store,
+	cfg *config.Config,
+	log *zerolog.Logger,
+) *dbgen.DataSession {
+	history, err := store.GetSessionMessages(ctx, session.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to get session messages")
+		return session
+	}
+	tokens := contexts.CountMessages(history)
+	if tokens <= contexts.HistoryBudget(model, systemPrompt) {
+		return session
+	}
+
+	summaryModel := cfg.SummarizerModel
+	if summaryModel == "" {
+		summaryModel = model
+	}
+	summary, usage, err := agent.SummarizeConversation(ctx, querier, summaryModel, contexts, agent.Pricing(cfg.Pricing), history)
+	if usage.TotalTokens > 0 {
+		if _, err := store.RecordLLMRequest(ctx, session.ID, userID, 0, usage); err != nil {
+			log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to record session summary request")
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Int64("chat_id", chatID).Int64("session_id", session.ID).Msg("unable to summarize session, keeping it")
+		return session
+	}
+
+	if err := store.EndSessionWithSummary(ctx, session.ID, summary); err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to end session with summary")
+		return session
+	}
+	if err := executors.Release(session.ID); err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to release session executor")
+	}
+
+	next, err := store.CreateSession(ctx, session.ChatID, session.ThreadID, userID, profile.Name, profile.Prompt, profile.AgentPrompt)
+	if err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to create new session")
+		return nil
+	}
+	if _, err := store.AddMessage(ctx, next.ID, agent.RoleSystem, agent.SessionSummaryPrefix+summary); err != nil {
+		log.Error().Err(err).Int64("chat_id", chatID).Msg("unable to store session summary")
+	}
+
+	log.Info().
+		Int64("chat_id", chatID).
+		Int64("user_id", userID).
+		Int64("ended_session_id", session.ID).
+		Int64("session_id", next.ID).
+		Int("history_tokens", tokens).
+		Int("summary_tokens", contexts.Count(summary)).
+		Str("model", usage.Model).
+		Msg("session rotated with summary")
+
+	return next
+}
+
+// sessionPrompts returns the prompts a session started with, so reloading
+// config.toml doesn't change a conversation halfway through. Sessions from
+// before prompts were kept get current, and so does the tool calling agent
+// prompt, which sessions don't keep.
+func sessionPrompts(session *dbgen.DataSession, current config.Prompts) config.Prompts {
+	if !session.SystemPrompt.Valid || !session.AgentPrompt.Valid {
+		return current
+	}
+	return config.Prompts{
+		Simple:     session.SystemPrompt.String,
+		Agent:      session.AgentPrompt.String,
+		AgentTools: current.AgentTools,
+	}
+}
@@ -0,0 +1,475 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/j0lvera/banray/internal/agent"
+	"github.com/j0lvera/banray/internal/config"
+	dbgen "github.com/j0lvera/banray/internal/db/gen"
+	"github.com/rs/zerolog"
+)
+
+// historySessions is how many sessions /history lists.
+const historySessions = 10
+
+// userCommands implements the commands every user can run.
+type userCommands struct {
+	store     *agent.Store
+	userStore *agent.UserStore
+	chats     *agent.ChatStore
+	memories  *agent.MemoryStore
+	executors *agent.ExecutorPool
+	access    *accessControl
+	budgets   *agent.Budgets
+	registry  *commandRegistry
+	admin     *adminCommands // Handles /usage for other users
+	cfg       *config.Config
+	log       *zerolog.Logger
+}
+
+// commands returns the user commands for the registry.
+func (u *userCommands) commands() []command {
+	return []command{
+		{name: "start", description: "Show what the bot can do", hidden: true, handler: u.help},
+		{name: "help", description: "Show available commands", handler: u.help},
+		{name: "clear", description: "Start a new conversation", handler: u.clear},
+		{name: "mode", description: "Show or switch mode: /mode simple|agent|default", handler: u.mode},
+		{name: "model", description: "Show or pick the model: /model [number|name|default]", handler: u.model},
+		{name: "profile", description: "Show or pick a profile: /profile [name]", handler: u.profile},
+		{name: "history", description: "List your recent conversations", handler: u.history},
+		{name: "usage", description: "Show your token usage and cost", handler: u.usage},
+		{name: "memory", description: "Show or forget what I remember: /memory [list|forget <number|all>]", handler: u.memory},
+	}
+}
+
+// help lists the commands available to the user.
+func (u *userCommands) help(ctx context.Context, req commandRequest) {
+	req.reply(ctx, u.registry.help(req.role))
+}
+
+// clear ends the session of the chat, or topic, so the next message starts
+// fresh. In groups only administrators may clear it.
+func (u *userCommands) clear(ctx context.Context, req commandRequest) {
+	if !canManageChat(ctx, req, u.log) {
+		req.reply(ctx, "Only group administrators can clear the conversation.")
+		return
+	}
+	u.endSession(ctx, req)
+	req.reply(ctx, "Conversation cleared. Starting fresh!")
+	u.log.Info().Int64("chat_id", req.chatID).Int64("user_id", req.user.ID).Msg("session ended by user")
+}
+
+// endSession ends the active session of the chat or topic, if any, and
+// releases its executor.
+func (u *userCommands) endSession(ctx context.Context, req commandRequest) {
+	session, err := u.store.GetActiveSession(ctx, req.chat.ID, int64(req.threadID))
+	if err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to get active session")
+		return
+	}
+	if session == nil {
+		return
+	}
+	if err := u.store.EndSession(ctx, session.ID); err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to end session")
+	}
+	if err := u.executors.Release(session.ID); err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to release session executor")
+	}
+}
+
+// mode shows or switches between simple and agentic mode. "default"
+// forgets the user's pick, so their profile's mode applies.
+func (u *userCommands) mode(ctx context.Context, req commandRequest) {
+	profile := chatProfile(u.cfg, req.chat, req.user, u.log)
+	current := u.access.mode(req.role, req.user, profile)
+	if req.args == "" {
+		text := fmt.Sprintf("You're in %s mode.", current)
+		if req.role.CanUseAgent() {
+			text += " Switch with /mode simple or /mode agent."
+		}
+		req.reply(ctx, text)
+		return
+	}
+
+	if strings.EqualFold(req.args, "default") {
+		user, err := u.userStore.SetUserMode(ctx, req.user.ID, "")
+		if err != nil {
+			u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to set user mode")
+			req.reply(ctx, "Sorry, I couldn't switch modes.")
+			return
+		}
+		mode := u.access.mode(req.role, user, profile)
+		u.log.Info().Int64("user_id", req.user.ID).Str("mode", string(mode)).Msg("user mode reset")
+		req.reply(ctx, fmt.Sprintf("Switched to the default %s mode.", mode))
+		return
+	}
+
+	mode, err := agent.ParseUserMode(strings.ToLower(req.args))
+	if err != nil {
+		req.reply(ctx, "Usage: /mode simple|agent|default")
+		return
+	}
+	if mode == agent.UserModeAgent && !req.role.CanUseAgent() {
+		req.reply(ctx, "Agent mode isn't available for your account.")
+		return
+	}
+
+	if _, err := u.userStore.SetUserMode(ctx, req.user.ID, mode); err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to set user mode")
+		req.reply(ctx, "Sorry, I couldn't switch modes.")
+		return
+	}
+	u.log.Info().Int64("user_id", req.user.ID).Str("mode", string(mode)).Msg("user mode changed")
+	req.reply(ctx, fmt.Sprintf("Switched to %s mode.", mode))
+}
+
+// model shows the allowed models or picks one by number or name. "default"
+// picks the model of the user's profile.
+func (u *userCommands) model(ctx context.Context, req commandRequest) {
+	models := u.cfg.Models()
+	profile := chatProfile(u.cfg, req.chat, req.user, u.log)
+	current := userModel(u.cfg, profile, req.user)
+
+	if req.args == "" {
+		var b strings.Builder
+		fmt.Fprintf(&b, "Current model: %s\n", current)
+		if len(models) == 1 {
+			b.WriteString("\nNo other models are available.")
+			req.reply(ctx, b.String())
+			return
+		}
+		b.WriteString("\nAvailable models:\n")
+		for i, model := range models {
+			marker := ""
+			if model == current {
+				marker = " (current)"
+			}
+			fmt.Fprintf(&b, "\n%d. %s%s", i+1, model, marker)
+		}
+		b.WriteString("\n\nPick one with /model <number>.")
+		req.reply(ctx, b.String())
+		return
+	}
+
+	model := req.args
+	if n, err := strconv.Atoi(req.args); err == nil && n >= 1 && n <= len(models) {
+		model = models[n-1]
+	}
+	if strings.EqualFold(model, "default") {
+		model = profile.Model
+	}
+	if model != profile.Model && !slices.Contains(models, model) {
+		req.reply(ctx, "That model isn't available. Send /model to see the list.")
+		return
+	}
+
+	// Picking the default is stored as no preference, so it follows the profile
+	stored := model
+	if model == profile.Model {
+		stored = ""
+	}
+	if _, err := u.userStore.SetUserModel(ctx, req.user.ID, stored); err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to set user model")
+		req.reply(ctx, "Sorry, I couldn't switch models.")
+		return
+	}
+	u.log.Info().Int64("user_id", req.user.ID).Str("model", model).Msg("user model changed")
+	req.reply(ctx, fmt.Sprintf("Now using %s.", model))
+}
+
+// profile lists the profiles or picks one by name: the user's own in private
+// chats, the group's in groups, where only administrators may pick. Picking
+// a profile ends the chat's session, so the next message starts one with its
+// prompts.
+func (u *userCommands) profile(ctx context.Context, req commandRequest) {
+	current := chatProfile(u.cfg, req.chat, req.user, u.log)
+
+	if req.args == "" {
+		var b strings.Builder
+		fmt.Fprintf(&b, "Current profile: %s\n", current.Name)
+		names := u.cfg.ProfileNames()
+		if len(names) == 1 {
+			b.WriteString("\nNo other profiles are available.")
+			req.reply(ctx, b.String())
+			return
+		}
+		b.WriteString("\nAvailable profiles:\n")
+		for _, name := range names {
+			profile, _ := u.cfg.Profile(name)
+			line := "\n" + name
+			if profile.Description != "" {
+				line += " – " + profile.Description
+			}
+			if name == current.Name {
+				line += " (current)"
+			}
+			b.WriteString(line)
+		}
+		b.WriteString("\n\nPick one with /profile <name>.")
+		req.reply(ctx, b.String())
+		return
+	}
+
+	if !canManageChat(ctx, req, u.log) {
+		req.reply(ctx, "Only group administrators can switch the group's profile.")
+		return
+	}
+	profile, ok := u.cfg.Profile(strings.ToLower(req.args))
+	if !ok {
+		req.reply(ctx, "That profile isn't available. Send /profile to see the list.")
+		return
+	}
+
+	// Picking the default is stored as no preference
+	stored := profile.Name
+	if profile.Name == config.DefaultProfile {
+		stored = ""
+	}
+	var err error
+	if req.group {
+		_, err = u.chats.SetChatProfile(ctx, req.chat.ID, stored)
+	} else {
+		_, err = u.userStore.SetUserProfile(ctx, req.user.ID, stored)
+	}
+	if err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to set profile")
+		req.reply(ctx, "Sorry, I couldn't switch profiles.")
+		return
+	}
+
+	// The active session keeps the prompts it started with, so end it
+	u.endSession(ctx, req)
+	u.log.Info().Int64("chat_id", req.chatID).Int64("user_id", req.user.ID).Str("profile", profile.Name).Bool("group", req.group).Msg("profile changed")
+
+	text := fmt.Sprintf("Switched to the %s profile. Starting fresh!", profile.Name)
+	if req.user.Model.Valid && req.user.Model.String != profile.Model {
+		text += fmt.Sprintf("\n\nYou picked %s with /model, which overrides the profile's model. Send /model default to use it.", req.user.Model.String)
+	}
+	if req.user.Mode.Valid && profile.Mode != "" && req.user.Mode.String != profile.Mode && req.role.CanUseAgent() {
+		text += fmt.Sprintf("\n\nYou picked %s mode with /mode, which overrides the profile's. Send /mode default to use it.", req.user.Mode.String)
+	}
+	req.reply(ctx, text)
+}
+
+// history lists the user's recent sessions.
+func (u *userCommands) history(ctx context.Context, req commandRequest) {
+	sessions, err := u.store.GetUserSessions(ctx, req.user.ID, historySessions)
+	if err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to get user sessions")
+		req.reply(ctx, "Sorry, I couldn't load your history.")
+		return
+	}
+	if len(sessions) == 0 {
+		req.reply(ctx, "No conversations yet.")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("Recent conversations:\n")
+	for _, session := range sessions {
+		count, err := u.store.CountSessionMessages(ctx, session.ID)
+		if err != nil {
+			u.log.Error().Err(err).Int64("session_id", session.ID).Msg("unable to count session messages")
+		}
+		status := "ended"
+		if !session.EndedAt.Valid {
+			status = "active"
+		}
+		fmt.Fprintf(&b, "\n#%d · %s · %d messages · %s",
+			session.ID, session.CreatedAt.Time.Format("2006-01-02 15:04"), count, status)
+		if session.Profile.Valid {
+			fmt.Fprintf(&b, " · %s", session.Profile.String)
+		}
+	}
+	req.reply(ctx, b.String())
+}
+
+// memory lists what the bot remembers about the user, or forgets one
+// memory by its number in the list, or all of them. Memories are private, so
+// groups are turned away.
+func (u *userCommands) memory(ctx context.Context, req commandRequest) {
+	if req.group {
+		req.reply(ctx, "Memories are private. Send /memory in a chat with me.")
+		return
+	}
+
+	action, arg, _ := strings.Cut(req.args, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch strings.ToLower(action) {
+	case "", "list":
+		u.listMemories(ctx, req)
+	case "forget":
+		u.forgetMemory(ctx, req, arg)
+	default:
+		req.reply(ctx, "Usage: /memory [list|forget <number|all>]")
+	}
+}
+
+// listMemories replies with the user's memories, numbered for /memory forget.
+func (u *userCommands) listMemories(ctx context.Context, req commandRequest) {
+	memories, err := u.memories.ListMemories(ctx, req.user.ID)
+	if err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to list memories")
+		req.reply(ctx, "Sorry, I couldn't load your memories.")
+		return
+	}
+	if len(memories) == 0 {
+		text := "I don't remember anything about you yet."
+		if !u.cfg.Memories {
+			text = "Memory is turned off."
+		}
+		req.reply(ctx, text)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("What I remember about you:\n")
+	for i, memory := range memories {
+		line := fmt.Sprintf("\n%d. %s", i+1, memory.Content)
+		if b.Len()+len(line) > maxMessageLength-100 {
+			fmt.Fprintf(&b, "\n\n…and %d more.", len(memories)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+	b.WriteString("\n\nForget one with /memory forget <number>, or everything with /memory forget all.")
+	req.reply(ctx, b.String())
+}
+
+// forgetMemory deletes the memory numbered arg in the list, or all of them.
+func (u *userCommands) forgetMemory(ctx context.Context, req commandRequest, arg string) {
+	if strings.EqualFold(arg, "all") {
+		deleted, err := u.memories.DeleteMemories(ctx, req.user.ID)
+		if err != nil {
+			u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to delete memories")
+			req.reply(ctx, "Sorry, I couldn't forget your memories.")
+			return
+		}
+		u.log.Info().Int64("user_id", req.user.ID).Int("memories", deleted).Msg("memories deleted by user")
+		req.reply(ctx, fmt.Sprintf("Forgot %d memories.", deleted))
+		return
+	}
+
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		req.reply(ctx, "Usage: /memory forget <number|all>")
+		return
+	}
+
+	memories, err := u.memories.ListMemories(ctx, req.user.ID)
+	if err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to list memories")
+		req.reply(ctx, "Sorry, I couldn't load your memories.")
+		return
+	}
+	if n > len(memories) {
+		req.reply(ctx, "There's no memory with that number. Send /memory list to see them.")
+		return
+	}
+
+	memory := memories[n-1]
+	if _, err := u.memories.DeleteMemory(ctx, req.user.ID, memory.ID); err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to delete memory")
+		req.reply(ctx, "Sorry, I couldn't forget that.")
+		return
+	}
+	u.log.Info().Int64("user_id", req.user.ID).Int64("memory_id", memory.ID).Msg("memory deleted by user")
+	req.reply(ctx, fmt.Sprintf("Forgot: %s", memory.Content))
+}
+
+// usage shows the user's token usage and cost in the current session and
+// overall. Admins can pass a user, or "all", "models" or "days" for reports.
+func (u *userCommands) usage(ctx context.Context, req commandRequest) {
+	if req.args != "" {
+		if !req.role.IsAdmin() {
+			req.reply(ctx, "Usage: /usage")
+			return
+		}
+		if req.group {
+			req.reply(ctx, adminPrivateOnly)
+			return
+		}
+		u.admin.usage(ctx, req)
+		return
+	}
+
+	overall, err := u.store.GetUserTokenUsage(ctx, req.user.ID)
+	if err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to get user token usage")
+		req.reply(ctx, "Sorry, I couldn't load your usage.")
+		return
+	}
+
+	current := &dbgen.GetSessionUserTokenUsageRow{}
+	session, err := u.store.GetActiveSession(ctx, req.chat.ID, int64(req.threadID))
+	if err == nil && session != nil {
+		current, err = u.store.GetSessionUserTokenUsage(ctx, session.ID, req.user.ID)
+	}
+	if err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to get session token usage")
+		req.reply(ctx, "Sorry, I couldn't load your usage.")
+		return
+	}
+
+	text := fmt.Sprintf("Current conversation: %d tokens (%d in, %d out), %s in %d requests\nOverall: %d tokens (%d in, %d out), %s in %d requests",
+		current.TotalTokens, current.TotalInputTokens, current.TotalOutputTokens, formatUSD(current.TotalCostUsd), current.RequestCount,
+		overall.TotalTokens, overall.TotalInputTokens, overall.TotalOutputTokens, formatUSD(overall.TotalCostUsd), overall.RequestCount)
+
+	allowance, err := u.budgets.Remaining(ctx, req.user.ID, req.role)
+	if err != nil {
+		u.log.Error().Err(err).Int64("chat_id", req.chatID).Msg("unable to check budget")
+	} else if !allowance.Unlimited() {
+		text += "\n\nBudget left: " + describeAllowance(allowance)
+	}
+	req.reply(ctx, text)
+}
+
+// describeAllowance formats what's left of a user's budget.
+func describeAllowance(allowance agent.Allowance) string {
+	if allowance.Exhausted != "" {
+		return fmt.Sprintf("none, the %s budget is used up", allowance.Exhausted)
+	}
+	var parts []string
+	if allowance.Tokens != math.MaxInt {
+		parts = append(parts, fmt.Sprintf("%d tokens", allowance.Tokens))
+	}
+	if !math.IsInf(allowance.CostUSD, 1) {
+		parts = append(parts, formatUSD(allowance.CostUSD))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatUSD formats a dollar amount, keeping sub-cent costs visible.
+func formatUSD(usd float64) string {
+	if usd != 0 && math.Abs(usd) < 0.01 {
+		return fmt.Sprintf("$%.4f", usd)
+	}
+	return fmt.Sprintf("$%.2f", usd)
+}
+
+// userModel returns the model for a user's requests: their pick if it's
+// still allowed, otherwise their profile's.
+func userModel(cfg *config.Config, profile config.Profile, user *dbgen.DataUser) string {
+	if user.Model.Valid && slices.Contains(cfg.Models(), user.Model.String) {
+		return user.Model.String
+	}
+	return profile.Model
+}
+
+// userProfile returns the profile a user picked, or the default one when
+// they haven't picked any or theirs was removed from config.toml.
+func userProfile(cfg *config.Config, user *dbgen.DataUser, log *zerolog.Logger) config.Profile {
+	profile, ok := cfg.Profile(user.Profile.String)
+	if !ok {
+		log.Warn().Int64("user_id", user.ID).Str("profile", user.Profile.String).Msg("unknown profile, using default")
+		profile, _ = cfg.Profile(config.DefaultProfile)
+	}
+	return profile
+}
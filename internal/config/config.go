@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -14,42 +16,209 @@ import (
 
 // Config holds all configuration from environment variables.
 type Config struct {
-	Token        string `envconfig:"TELEGRAM_API_TOKEN" required:"true"`
-	APIKey       string `envconfig:"OPENROUTER_API_KEY" required:"true"`
-	BaseURL      string `envconfig:"OPENROUTER_BASE_URL" default:"https://openrouter.ai/api/v1"`
-	Model        string `envconfig:"OPENROUTER_MODEL" default:"anthropic/claude-3.5-sonnet"`
-	HistoryLimit int    `envconfig:"HISTORY_LIMIT" default:"10"`
-	DatabaseURL  string `envconfig:"DATABASE_URL" required:"true"`
+	Token       string `envconfig:"TELEGRAM_API_TOKEN" required:"true"`
+	APIKey      string `envconfig:"OPENROUTER_API_KEY" required:"true"`
+	BaseURL     string `envconfig:"OPENROUTER_BASE_URL" default:"https://openrouter.ai/api/v1"`
+	Model       string `envconfig:"OPENROUTER_MODEL" default:"anthropic/claude-3.5-sonnet"`
+	DatabaseURL string `envconfig:"DATABASE_URL" required:"true"`
+
+	// Context budgeting, in tokens
+	ContextWindow int `envconfig:"CONTEXT_WINDOW" default:"128000"` // Window of models missing from [context_windows]
+	HistoryTokens int `envconfig:"HISTORY_TOKENS" default:"8000"`   // History sent with each message (0 = whatever fits the window)
+
+	// Long-term memory of facts about each user
+	Memories    bool `envconfig:"MEMORIES" default:"true"`  // Extract memories after each exchange and recall them into prompts
+	MemoryLimit int  `envconfig:"MEMORY_LIMIT" default:"5"` // Most memories recalled into a prompt
+
+	// Models users may pick with /model, besides OPENROUTER_MODEL
+	AllowedModels []string `envconfig:"ALLOWED_MODELS" default:""`
+
+	// Access control
+	AdminTelegramIDs []int64 `envconfig:"ADMIN_TELEGRAM_IDS" default:""` // Always granted the admin role
+	DefaultRole      string  `envconfig:"DEFAULT_ROLE" default:"user"`   // Role for new users: blocked, user, agent or admin
+
+	// Stream simple mode responses by editing a placeholder message
+	StreamResponses bool `envconfig:"STREAM_RESPONSES" default:"true"`
 
 	// Agentic mode settings
 	AgenticMode      bool          `envconfig:"AGENTIC_MODE" default:"false"`
 	MaxSteps         int           `envconfig:"MAX_STEPS" default:"10"`
 	CommandTimeout   time.Duration `envconfig:"COMMAND_TIMEOUT" default:"30s"`
 	WorkingDir       string        `envconfig:"WORKING_DIR" default:""`
-	ContextThreshold int           `envconfig:"CONTEXT_THRESHOLD" default:"8000"` // Chars before summarization kicks in
+	ContextThreshold int           `envconfig:"CONTEXT_THRESHOLD" default:"2000"` // Tokens of context before large output is summarized
+	SummarizerModel  string        `envconfig:"SUMMARIZER_MODEL" default:""`      // Model that summarizes large output and rotated sessions and extracts memories (default: the user's model)
+	ShowProgress     bool          `envconfig:"SHOW_PROGRESS" default:"true"`     // Live status message during agentic runs
+	ToolCalling      bool          `envconfig:"TOOL_CALLING" default:"false"`     // Native tool calling instead of markdown bash blocks
+	AgentTools       []string      `envconfig:"AGENT_TOOLS" default:""`           // Extra tools: read_file,write_file,http_fetch,sql_query
+	SQLToolDSN       string        `envconfig:"SQL_TOOL_DSN" default:""`          // Database for the read-only sql_query tool
+	HTTPToolHosts    []string      `envconfig:"HTTP_TOOL_HOSTS" default:""`       // Hosts http_fetch may reach, e.g. api.github.com,*.example.com (default: any public host)
+
+	// Command executor settings
+	Executor          string `envconfig:"EXECUTOR" default:"bash"`          // bash or sandbox
+	PersistentShell   bool   `envconfig:"PERSISTENT_SHELL" default:"false"` // Keep one bash per session so cd and exports carry over
+	SandboxDir        string `envconfig:"SANDBOX_DIR" default:""`           // Parent of per-session scratch dirs (default: $TMPDIR/banray-sandbox)
+	SandboxNetwork    bool   `envconfig:"SANDBOX_NETWORK" default:"true"`   // Set to false to cut sandboxed commands off the network
+	SandboxMemoryMB   int    `envconfig:"SANDBOX_MEMORY_MB" default:"1024"` // Address space limit per process
+	SandboxMaxPids    int    `envconfig:"SANDBOX_MAX_PIDS" default:"256"`   // Process limit for the bot's OS user
+	SandboxCPUSeconds int    `envconfig:"SANDBOX_CPU_SECONDS" default:"60"` // CPU time limit per process
 
 	// Path to config.toml file
 	ConfigFile string `envconfig:"CONFIG_FILE" default:"config.toml"`
 
-	// Path to context directory containing .md files to inject into prompts
-	ContextDir string `envconfig:"CONTEXT_DIR" default:".context"`
+	// Context directory whose .md files, subdirectories included, are indexed
+	// so the chunks relevant to each message are injected into prompts
+	ContextDir         string `envconfig:"CONTEXT_DIR" default:".context"`
+	ContextTopK        int    `envconfig:"CONTEXT_TOP_K" default:"4"`          // Most chunks injected into a prompt
+	ContextChunkTokens int    `envconfig:"CONTEXT_CHUNK_TOKENS" default:"400"` // Size of indexed chunks
+
+	// Prompts and profiles loaded from config.toml, swapped together on reload
+	prompts atomic.Value // promptSet
+
+	// Command approval policy loaded from config.toml
+	Approval Approval
+
+	// Command validation policy loaded from config.toml
+	Commands Commands
 
-	// Prompts loaded from config.toml
-	Prompts Prompts
+	// Fallback chain loaded from config.toml, tried in order (empty = OPENROUTER_MODEL only)
+	ModelChain []ModelEndpoint
 
-	// Context loaded from .context/*.md files
-	Context string
+	// Retry and circuit breaking policy for the fallback chain, loaded from config.toml
+	Retry Retry
+
+	// Context windows in tokens loaded from config.toml, keyed by model name
+	ContextWindows map[string]int
+
+	// Model prices loaded from config.toml, keyed by model name
+	Pricing map[string]ModelPrice
+
+	// Spending limits loaded from config.toml, keyed by role
+	Budgets map[string]Budget
 }
 
 // Prompts holds system prompts loaded from config.toml.
 type Prompts struct {
-	Simple string `toml:"simple"`
-	Agent  string `toml:"agent"`
+	Simple     string `toml:"simple"`
+	Agent      string `toml:"agent"`
+	AgentTools string `toml:"agent_tools"` // Agent prompt with TOOL_CALLING, which calls tools instead of writing bash blocks
+}
+
+// Executors for agentic commands.
+const (
+	ExecutorBash    = "bash"    // Run commands directly as the bot's OS user
+	ExecutorSandbox = "sandbox" // Run commands in isolated Linux namespaces
+)
+
+// Approval modes for agentic commands.
+const (
+	ApprovalModeOff      = "off"      // Never ask for approval
+	ApprovalModePatterns = "patterns" // Ask for commands matching Patterns
+	ApprovalModeStrict   = "strict"   // Ask for every command
+)
+
+// Approval holds the human-in-the-loop approval policy loaded from config.toml.
+type Approval struct {
+	Mode     string        `toml:"mode"`
+	Patterns []string      `toml:"patterns"` // Regexes matched against the command
+	Timeout  time.Duration `toml:"timeout"`  // How long to wait for an answer, e.g. "5m"
+}
+
+// Validators for agentic commands.
+const (
+	ValidatorBlocklist = "blocklist" // Match regexes against the raw command
+	ValidatorPolicy    = "policy"    // Parse the command and check it against the policy
+)
+
+// Commands holds the command validation policy loaded from config.toml.
+type Commands struct {
+	Validator     string   `toml:"validator"`
+	Allow         []string `toml:"allow"`          // Programs that may run; empty allows anything not denied
+	Deny          []string `toml:"deny"`           // Programs that may never run
+	DenyRedirects []string `toml:"deny_redirects"` // Paths output may not be redirected to
+	DenySubshells bool     `toml:"deny_subshells"` // Reject (...), $(...), backticks and <(...)
+}
+
+// Model providers.
+const (
+	ProviderOpenAI    = "openai"    // OpenAI-compatible API: OpenRouter, OpenAI, llama.cpp server
+	ProviderAnthropic = "anthropic" // Anthropic Messages API, with prompt caching
+	ProviderOllama    = "ollama"    // Ollama's native chat API
+)
+
+// ModelEndpoint is one model of the fallback chain.
+type ModelEndpoint struct {
+	Name      string `toml:"name"`        // Model ID sent to the provider
+	Provider  string `toml:"provider"`    // openai (default), anthropic or ollama
+	BaseURL   string `toml:"base_url"`    // Defaults to OPENROUTER_BASE_URL, or the provider's public endpoint
+	APIKeyEnv string `toml:"api_key_env"` // Environment variable holding the API key (default: OPENROUTER_API_KEY, or ANTHROPIC_API_KEY)
+	Auxiliary bool   `toml:"auxiliary"`   // Only used when asked for by name, e.g. as SUMMARIZER_MODEL; never a fallback
+}
+
+// Retry holds how failed queries are retried and when a model is taken out
+// of the fallback chain. Retries are counted per error class.
+type Retry struct {
+	RateLimit        int           `toml:"rate_limit"`        // Retries after a 429
+	Server           int           `toml:"server"`            // Retries after a 5xx
+	Network          int           `toml:"network"`           // Retries after timeouts and connection errors
+	BaseDelay        time.Duration `toml:"base_delay"`        // First backoff, doubled on each retry
+	MaxDelay         time.Duration `toml:"max_delay"`         // Longest backoff
+	BreakerThreshold int           `toml:"breaker_threshold"` // Consecutive failures that take a model out
+	BreakerCooldown  time.Duration `toml:"breaker_cooldown"`  // How long before it's tried again
+}
+
+// ModelPrice is what a model costs in USD per million tokens.
+type ModelPrice struct {
+	Input      float64 `toml:"input"`
+	Output     float64 `toml:"output"`
+	CacheRead  float64 `toml:"cache_read"`  // Prompt cache hits (0 = billed as input)
+	CacheWrite float64 `toml:"cache_write"` // Prompt cache writes (0 = billed as input)
+}
+
+// Budget caps what each user with a role may spend. Zero means unlimited.
+type Budget struct {
+	DailyTokens   int     `toml:"daily_tokens"`
+	MonthlyTokens int     `toml:"monthly_tokens"`
+	DailyUSD      float64 `toml:"daily_usd"`
+	MonthlyUSD    float64 `toml:"monthly_usd"`
 }
 
 // FileConfig represents the structure of config.toml.
 type FileConfig struct {
-	Prompts Prompts `toml:"prompts"`
+	Prompts        Prompts               `toml:"prompts"`
+	Approval       Approval              `toml:"approval"`
+	Commands       Commands              `toml:"commands"`
+	Models         []ModelEndpoint       `toml:"models"`
+	Retry          Retry                 `toml:"retry"`
+	ContextWindows map[string]int        `toml:"context_windows"`
+	Pricing        map[string]ModelPrice `toml:"pricing"`
+	Budgets        map[string]Budget     `toml:"budgets"`
+	Profiles       map[string]Profile    `toml:"profiles"`
+}
+
+// DefaultApproval disables approvals unless configured.
+var DefaultApproval = Approval{
+	Mode:    ApprovalModeOff,
+	Timeout: 5 * time.Minute,
+}
+
+// DefaultCommands keeps the regex blocklist unless configured. The lists
+// apply once the policy validator is selected.
+var DefaultCommands = Commands{
+	Validator:     ValidatorBlocklist,
+	Deny:          []string{"shutdown", "reboot", "halt", "poweroff", "init", "mkfs*", "fdisk", "dd", "sudo", "su"},
+	DenyRedirects: []string{"/etc", "/boot", "/dev/sd*", "/dev/nvme*", "/sys", "/proc"},
+}
+
+// DefaultRetry retries transient failures a few times and takes a model out
+// for a minute after five failures in a row.
+var DefaultRetry = Retry{
+	RateLimit:        3,
+	Server:           2,
+	Network:          2,
+	BaseDelay:        500 * time.Millisecond,
+	MaxDelay:         10 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  time.Minute,
 }
 
 // DefaultPrompts provides fallback prompts if config.toml is not found.
@@ -76,6 +245,22 @@ echo "Your final summary here"
 5. If a command fails, try an alternative approach.
 
 6. You have access to common tools: curl, jq, python3, node, etc.`,
+	AgentTools: `You are an autonomous agent. You accomplish tasks by calling the tools you are offered.
+
+TOOLS:
+- bash runs one shell command and returns its output and exit code.
+- Other tools, when offered, read and write files, make HTTP requests or query a database; their descriptions say how to call them.
+
+RULES:
+1. Call exactly ONE tool per response. After each call, you'll see its result. Use it to decide your next action.
+
+2. When the task is complete, reply with your final answer as plain text, without calling a tool. That reply is sent to the user.
+
+3. Be concise. Call tools, observe results, iterate.
+
+4. If a call fails, try an alternative approach.
+
+5. bash has access to common tools: curl, jq, python3, node, etc.`,
 }
 
 // LoadEnv loads the configuration from environment variables.
@@ -89,9 +274,10 @@ func (c Config) LoadEnv() (Config, error) {
 	return cfg, nil
 }
 
-// LoadFile loads prompts from config.toml file.
-func (c *Config) LoadFile() error {
-	// Try to find config file
+// ConfigPath returns where config.toml is read from: CONFIG_FILE, or next to
+// the executable when a relative CONFIG_FILE isn't found in the current
+// directory.
+func (c *Config) ConfigPath() string {
 	configPath := c.ConfigFile
 	if !filepath.IsAbs(configPath) {
 		// Try current directory first
@@ -104,84 +290,239 @@ func (c *Config) LoadFile() error {
 			}
 		}
 	}
+	return configPath
+}
+
+// LoadFile loads prompts from config.toml file.
+func (c *Config) LoadFile() error {
+	configPath := c.ConfigPath()
 
 	// Check if file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// Use defaults if no config file
-		c.Prompts = DefaultPrompts
+		c.prompts.Store(promptSet{prompts: DefaultPrompts})
+		c.Approval = DefaultApproval
+		c.Commands = DefaultCommands
+		c.Retry = DefaultRetry
 		return nil
 	}
 
 	// Load TOML file
 	var fileConfig FileConfig
-	if _, err := toml.DecodeFile(configPath, &fileConfig); err != nil {
+	meta, err := toml.DecodeFile(configPath, &fileConfig)
+	if err != nil {
 		return err
 	}
 
-	c.Prompts = fileConfig.Prompts
+	prompts := fileConfig.Prompts
 
 	// Use defaults for empty prompts
-	if c.Prompts.Simple == "" {
-		c.Prompts.Simple = DefaultPrompts.Simple
+	if prompts.Simple == "" {
+		prompts.Simple = DefaultPrompts.Simple
 	}
-	if c.Prompts.Agent == "" {
-		c.Prompts.Agent = DefaultPrompts.Agent
+	if prompts.Agent == "" {
+		prompts.Agent = DefaultPrompts.Agent
+	}
+	if prompts.AgentTools == "" {
+		prompts.AgentTools = DefaultPrompts.AgentTools
 	}
 
-	return nil
-}
+	c.Approval = fileConfig.Approval
 
-// LoadContext loads all .md files from the context directory and concatenates them.
-func (c *Config) LoadContext() error {
-	// Check if context directory exists
-	if _, err := os.Stat(c.ContextDir); os.IsNotExist(err) {
-		// No context directory, that's fine
-		return nil
+	// Use defaults for unset approval settings
+	if c.Approval.Mode == "" {
+		c.Approval.Mode = DefaultApproval.Mode
+	}
+	if c.Approval.Timeout <= 0 {
+		c.Approval.Timeout = DefaultApproval.Timeout
 	}
 
-	// Find all .md files in context directory
-	pattern := filepath.Join(c.ContextDir, "*.md")
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		return fmt.Errorf("failed to glob context files: %w", err)
+	switch c.Approval.Mode {
+	case ApprovalModeOff, ApprovalModePatterns, ApprovalModeStrict:
+	default:
+		return fmt.Errorf("invalid approval mode %q: must be %s, %s or %s", c.Approval.Mode, ApprovalModeOff, ApprovalModePatterns, ApprovalModeStrict)
 	}
 
-	if len(files) == 0 {
-		return nil
+	c.Commands = fileConfig.Commands
+
+	// Use defaults for unset validation settings
+	if c.Commands.Validator == "" {
+		c.Commands.Validator = DefaultCommands.Validator
+	}
+	if c.Commands.Deny == nil {
+		c.Commands.Deny = DefaultCommands.Deny
+	}
+	if c.Commands.DenyRedirects == nil {
+		c.Commands.DenyRedirects = DefaultCommands.DenyRedirects
+	}
+
+	switch c.Commands.Validator {
+	case ValidatorBlocklist, ValidatorPolicy:
+	default:
+		return fmt.Errorf("invalid command validator %q: must be %s or %s", c.Commands.Validator, ValidatorBlocklist, ValidatorPolicy)
+	}
+
+	c.ModelChain = fileConfig.Models
+	for i, model := range c.ModelChain {
+		if model.Name == "" {
+			return fmt.Errorf("invalid model %d: name is required", i+1)
+		}
+		if model.Provider == "" {
+			c.ModelChain[i].Provider = ProviderOpenAI
+		}
+		switch c.ModelChain[i].Provider {
+		case ProviderOpenAI, ProviderAnthropic, ProviderOllama:
+		default:
+			return fmt.Errorf("invalid provider %q for model %q: must be %s, %s or %s", model.Provider, model.Name, ProviderOpenAI, ProviderAnthropic, ProviderOllama)
+		}
+		if model.APIKeyEnv != "" && os.Getenv(model.APIKeyEnv) == "" {
+			return fmt.Errorf("invalid model %q: %s is not set", model.Name, model.APIKeyEnv)
+		}
+	}
+
+	// The first model of the chain is the default
+	if len(c.ModelChain) > 0 {
+		i := slices.IndexFunc(c.ModelChain, func(m ModelEndpoint) bool { return !m.Auxiliary })
+		if i < 0 {
+			return fmt.Errorf("invalid models: at least one model must not be auxiliary")
+		}
+		c.Model = c.ModelChain[i].Name
+	}
+
+	c.Retry = fileConfig.Retry
+
+	// Use defaults for unset retry settings; zero retries is a valid choice
+	if !meta.IsDefined("retry", "rate_limit") {
+		c.Retry.RateLimit = DefaultRetry.RateLimit
+	}
+	if !meta.IsDefined("retry", "server") {
+		c.Retry.Server = DefaultRetry.Server
+	}
+	if !meta.IsDefined("retry", "network") {
+		c.Retry.Network = DefaultRetry.Network
+	}
+	if c.Retry.BaseDelay <= 0 {
+		c.Retry.BaseDelay = DefaultRetry.BaseDelay
+	}
+	if c.Retry.MaxDelay <= 0 {
+		c.Retry.MaxDelay = DefaultRetry.MaxDelay
+	}
+	if c.Retry.BreakerThreshold <= 0 {
+		c.Retry.BreakerThreshold = DefaultRetry.BreakerThreshold
+	}
+	if c.Retry.BreakerCooldown <= 0 {
+		c.Retry.BreakerCooldown = DefaultRetry.BreakerCooldown
+	}
+
+	if c.Retry.RateLimit < 0 || c.Retry.Server < 0 || c.Retry.Network < 0 {
+		return fmt.Errorf("invalid retry policy: retries must not be negative")
+	}
+
+	c.ContextWindows = fileConfig.ContextWindows
+	for model, window := range c.ContextWindows {
+		if window <= 0 {
+			return fmt.Errorf("invalid context window for model %q: must be positive", model)
+		}
+	}
+
+	c.Pricing = fileConfig.Pricing
+	for model, price := range c.Pricing {
+		if price.Input < 0 || price.Output < 0 || price.CacheRead < 0 || price.CacheWrite < 0 {
+			return fmt.Errorf("invalid price for model %q: must not be negative", model)
+		}
 	}
 
-	// Read and concatenate all files
-	var parts []string
-	for _, file := range files {
-		content, err := os.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("failed to read context file %s: %w", file, err)
+	c.Budgets = fileConfig.Budgets
+	for role, budget := range c.Budgets {
+		if budget.DailyTokens < 0 || budget.MonthlyTokens < 0 || budget.DailyUSD < 0 || budget.MonthlyUSD < 0 {
+			return fmt.Errorf("invalid budget for role %q: limits must not be negative", role)
 		}
-		parts = append(parts, string(content))
 	}
 
-	c.Context = strings.Join(parts, "\n\n---\n\n")
+	profiles, err := loadProfiles(fileConfig.Profiles, meta)
+	if err != nil {
+		return err
+	}
+	c.prompts.Store(promptSet{prompts: prompts, profiles: profiles})
 
 	return nil
 }
 
-// AgentPrompt returns the agent prompt with context injected.
-func (c *Config) AgentPrompt() string {
+// promptSet is what reloading config.toml swaps in.
+type promptSet struct {
+	prompts  Prompts
+	profiles map[string]Profile
+}
+
+// loadedPrompts returns the current prompts and profiles.
+func (c *Config) loadedPrompts() promptSet {
+	if set, ok := c.prompts.Load().(promptSet); ok {
+		return set
+	}
+	return promptSet{prompts: DefaultPrompts}
+}
+
+// Prompts returns the current prompts. They change when config.toml is
+// reloaded, so callers that need them to stay put keep the returned copy.
+func (c *Config) Prompts() Prompts {
+	return c.loadedPrompts().prompts
+}
+
+// AgentPrompt returns the current agent prompt with the retrieved context injected.
+func (c *Config) AgentPrompt(context string) string {
+	return c.Prompts().AgentPrompt(context)
+}
+
+// SimplePrompt returns the current simple prompt with the retrieved context injected.
+func (c *Config) SimplePrompt(context string) string {
+	return c.Prompts().SimplePrompt(context)
+}
+
+// AgentPrompt returns the agent prompt with the retrieved context injected.
+func (p Prompts) AgentPrompt(context string) string {
+	return agentPrompt(p.Agent, context)
+}
+
+// AgentToolsPrompt returns the tool calling agent prompt with the retrieved
+// context injected.
+func (p Prompts) AgentToolsPrompt(context string) string {
+	return agentPrompt(p.AgentTools, context)
+}
+
+// agentPrompt injects the date and the retrieved context into an agent prompt.
+func agentPrompt(prompt, context string) string {
 	// Prepend today's date so the agent knows the current date
 	dateHeader := fmt.Sprintf("Today's date: %s\n\n", time.Now().Format("2006-01-02"))
 
-	if c.Context == "" {
-		return dateHeader + c.Prompts.Agent
+	if context == "" {
+		return dateHeader + prompt
+	}
+	return dateHeader + prompt + "\n\n## Available Tools & Context\n\n" + context
+}
+
+// SimplePrompt returns the simple prompt with the retrieved context injected.
+func (p Prompts) SimplePrompt(context string) string {
+	if context == "" {
+		return p.Simple
 	}
-	return dateHeader + c.Prompts.Agent + "\n\n## Available Tools & Context\n\n" + c.Context
+	return p.Simple + "\n\n## Available Tools & Context\n\n" + context
 }
 
-// SimplePrompt returns the simple prompt with context injected.
-func (c *Config) SimplePrompt() string {
-	if c.Context == "" {
-		return c.Prompts.Simple
+// Models returns the models users may pick, starting with the default,
+// then the rest of the fallback chain and ALLOWED_MODELS.
+func (c *Config) Models() []string {
+	models := []string{c.Model}
+	for _, endpoint := range c.ModelChain {
+		if !endpoint.Auxiliary && !slices.Contains(models, endpoint.Name) {
+			models = append(models, endpoint.Name)
+		}
+	}
+	for _, model := range c.AllowedModels {
+		if model = strings.TrimSpace(model); model != "" && !slices.Contains(models, model) {
+			models = append(models, model)
+		}
 	}
-	return c.Prompts.Simple + "\n\n## Available Tools & Context\n\n" + c.Context
+	return models
 }
 
 func NewConfig() (*Config, error) {
@@ -196,11 +537,6 @@ func NewConfig() (*Config, error) {
 		return nil, err
 	}
 
-	// Load context from .context/*.md files
-	if err := loadedCfg.LoadContext(); err != nil {
-		return nil, err
-	}
-
 	return &loadedCfg, nil
 }
 
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultProfile is the name of the profile built from [prompts] and the
+// environment, used by users who haven't picked one.
+const DefaultProfile = "default"
+
+// Modes a profile may start users in.
+const (
+	ModeSimple = "simple"
+	ModeAgent  = "agent"
+)
+
+// profileName is what a profile may be called, so /profile can name it.
+var profileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Profile is a persona loaded from a [profiles.<name>] section of
+// config.toml. Unset fields fall back to [prompts] and the environment.
+type Profile struct {
+	Name             string   `toml:"-"`
+	Description      string   `toml:"description"`        // Shown by /profile
+	Prompt           string   `toml:"prompt"`             // System prompt in simple mode (default: [prompts] simple)
+	AgentPrompt      string   `toml:"agent_prompt"`       // System prompt in agentic mode (default: [prompts] agent)
+	AgentToolsPrompt string   `toml:"agent_tools_prompt"` // System prompt in agentic mode with TOOL_CALLING (default: [prompts] agent_tools)
+	Model            string   `toml:"model"`              // Model used unless the user picked one with /model (default: OPENROUTER_MODEL)
+	Temperature      *float64 `toml:"temperature"`        // Sampling temperature (default: the provider's)
+	Mode             string   `toml:"mode"`               // simple or agent, unless the user picked one with /mode (default: AGENTIC_MODE)
+	ContextDir       string   `toml:"context_dir"`        // Directory retrieved context comes from (default: CONTEXT_DIR)
+	Tools            []string `toml:"tools"`              // Extra tools in tool calling mode (default: AGENT_TOOLS)
+}
+
+// Prompts returns the simple and agent prompts of the profile.
+func (p Profile) Prompts() Prompts {
+	return Prompts{Simple: p.Prompt, Agent: p.AgentPrompt, AgentTools: p.AgentToolsPrompt}
+}
+
+// loadProfiles validates the [profiles] of config.toml and names them. Tools
+// stay nil only when a profile doesn't list any, so an empty list can turn
+// AGENT_TOOLS off.
+func loadProfiles(profiles map[string]Profile, meta toml.MetaData) (map[string]Profile, error) {
+	for name, profile := range profiles {
+		if name == DefaultProfile {
+			return nil, fmt.Errorf("invalid profile %q: the name is reserved for [prompts]", name)
+		}
+		if !profileName.MatchString(name) {
+			return nil, fmt.Errorf("invalid profile %q: use lowercase letters, digits, - and _", name)
+		}
+		switch profile.Mode {
+		case "", ModeSimple, ModeAgent:
+		default:
+			return nil, fmt.Errorf("invalid mode %q for profile %q: must be %s or %s", profile.Mode, name, ModeSimple, ModeAgent)
+		}
+		if profile.Temperature != nil && (*profile.Temperature < 0 || *profile.Temperature > 2) {
+			return nil, fmt.Errorf("invalid temperature for profile %q: must be between 0 and 2", name)
+		}
+		if profile.Tools == nil && meta.IsDefined("profiles", name, "tools") {
+			profile.Tools = []string{}
+		}
+		profile.Name = name
+		profiles[name] = profile
+	}
+	return profiles, nil
+}
+
+// Profile returns the named profile with what it inherits filled in, and
+// false when there is no such profile. DefaultProfile, or an empty name, is
+// [prompts] and the environment alone.
+func (c *Config) Profile(name string) (Profile, bool) {
+	set := c.loadedPrompts()
+	profile := Profile{Name: DefaultProfile}
+	if name != "" && name != DefaultProfile {
+		var ok bool
+		profile, ok = set.profiles[name]
+		if !ok {
+			return Profile{}, false
+		}
+	}
+
+	if profile.Prompt == "" {
+		profile.Prompt = set.prompts.Simple
+	}
+	if profile.AgentPrompt == "" {
+		profile.AgentPrompt = set.prompts.Agent
+	}
+	if profile.AgentToolsPrompt == "" {
+		profile.AgentToolsPrompt = set.prompts.AgentTools
+	}
+	if profile.Model == "" {
+		profile.Model = c.Model
+	}
+	if profile.ContextDir == "" {
+		profile.ContextDir = c.ContextDir
+	}
+	if profile.Tools == nil {
+		profile.Tools = c.AgentTools
+	}
+	return profile, true
+}
+
+// ProfileNames returns the names of the profiles users may pick, starting
+// with DefaultProfile.
+func (c *Config) ProfileNames() []string {
+	names := []string{DefaultProfile}
+	for name := range c.loadedPrompts().profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names[1:])
+	return names
+}
+
+// ContextDirs returns every directory retrieved context may come from:
+// CONTEXT_DIR and those of the profiles.
+func (c *Config) ContextDirs() []string {
+	dirs := []string{c.ContextDir}
+	for _, profile := range c.loadedPrompts().profiles {
+		if profile.ContextDir != "" && !slices.Contains(dirs, profile.ContextDir) {
+			dirs = append(dirs, profile.ContextDir)
+		}
+	}
+	slices.Sort(dirs[1:])
+	return dirs
+}
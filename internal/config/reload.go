@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// PromptChanges describes what reloading config.toml changed.
+type PromptChanges struct {
+	Simple   string   // Line diff of the simple prompt, empty when unchanged
+	Agent    string   // Line diff of the agent prompt, empty when unchanged
+	Tools    string   // Line diff of the tool calling agent prompt, empty when unchanged
+	Profiles []string // Profiles added, removed or changed
+	Restart  []string // Other sections that changed, which only take effect after a restart
+}
+
+// Changed reports whether the reload swapped in new prompts or profiles.
+func (p PromptChanges) Changed() bool {
+	return p.Simple != "" || p.Agent != "" || p.Tools != "" || len(p.Profiles) > 0
+}
+
+// ReloadPrompts reads config.toml again and swaps in its prompts and
+// profiles together. The whole file is validated first, so a broken edit
+// leaves the current prompts in place, as does a missing file. Other
+// sections are wired into the bot at startup; changes to them are reported
+// but not applied.
+func (c *Config) ReloadPrompts() (PromptChanges, error) {
+	// A missing file would mean the defaults, but it's more likely an editor
+	// halfway through saving it
+	configPath := c.ConfigPath()
+	if _, err := os.Stat(configPath); err != nil {
+		return PromptChanges{}, fmt.Errorf("unable to read %s: %w", configPath, err)
+	}
+
+	next := &Config{ConfigFile: c.ConfigFile, Model: c.Model}
+	if err := next.LoadFile(); err != nil {
+		return PromptChanges{}, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+
+	current, loaded := c.loadedPrompts(), next.loadedPrompts()
+	changes := PromptChanges{
+		Simple: lineDiff(current.prompts.Simple, loaded.prompts.Simple),
+		Agent:  lineDiff(current.prompts.Agent, loaded.prompts.Agent),
+		Tools:  lineDiff(current.prompts.AgentTools, loaded.prompts.AgentTools),
+	}
+	for name, profile := range loaded.profiles {
+		if previous, ok := current.profiles[name]; !ok || !reflect.DeepEqual(previous, profile) {
+			changes.Profiles = append(changes.Profiles, name)
+		}
+	}
+	for name := range current.profiles {
+		if _, ok := loaded.profiles[name]; !ok {
+			changes.Profiles = append(changes.Profiles, name)
+		}
+	}
+	slices.Sort(changes.Profiles)
+
+	sections := []struct {
+		name          string
+		current, next any
+	}{
+		{"approval", c.Approval, next.Approval},
+		{"commands", c.Commands, next.Commands},
+		{"models", c.ModelChain, next.ModelChain},
+		{"retry", c.Retry, next.Retry},
+		{"context_windows", c.ContextWindows, next.ContextWindows},
+		{"pricing", c.Pricing, next.Pricing},
+		{"budgets", c.Budgets, next.Budgets},
+	}
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.current, section.next) {
+			changes.Restart = append(changes.Restart, section.name)
+		}
+	}
+
+	c.prompts.Store(loaded)
+	return changes, nil
+}
+
+// lineDiff summarizes how many lines were added to and removed from a
+// prompt, e.g. "+3 -1 lines", or returns an empty string when it's unchanged.
+func lineDiff(before, after string) string {
+	if before == after {
+		return ""
+	}
+
+	counts := map[string]int{}
+	for _, line := range strings.Split(before, "\n") {
+		counts[line]++
+	}
+	added := 0
+	for _, line := range strings.Split(after, "\n") {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		added++
+	}
+	removed := 0
+	for _, n := range counts {
+		removed += n
+	}
+	return fmt.Sprintf("+%d -%d lines", added, removed)
+}
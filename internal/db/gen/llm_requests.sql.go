@@ -12,9 +12,9 @@ import (
 )
 
 const createLLMRequest = `-- name: CreateLLMRequest :one
-INSERT INTO data.llm_requests (session_id, message_id, input_tokens, output_tokens, total_tokens, model)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id
+INSERT INTO data.llm_requests (session_id, message_id, input_tokens, output_tokens, total_tokens, model, cost_usd, purpose, user_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id, cost_usd, purpose, user_id
 `
 
 type CreateLLMRequestParams struct {
@@ -24,13 +24,16 @@ type CreateLLMRequestParams struct {
 	OutputTokens int32       `json:"output_tokens"`
 	TotalTokens  int32       `json:"total_tokens"`
 	Model        string      `json:"model"`
+	CostUsd      float64     `json:"cost_usd"`
+	Purpose      string      `json:"purpose"`
+	UserID       int64       `json:"user_id"`
 }
 
 // CreateLLMRequest
 //
-//	INSERT INTO data.llm_requests (session_id, message_id, input_tokens, output_tokens, total_tokens, model)
-//	VALUES ($1, $2, $3, $4, $5, $6)
-//	RETURNING id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id
+//	INSERT INTO data.llm_requests (session_id, message_id, input_tokens, output_tokens, total_tokens, model, cost_usd, purpose, user_id)
+//	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+//	RETURNING id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id, cost_usd, purpose, user_id
 func (q *Queries) CreateLLMRequest(ctx context.Context, arg CreateLLMRequestParams) (*DataLlmRequest, error) {
 	row := q.db.QueryRow(ctx, createLLMRequest,
 		arg.SessionID,
@@ -39,6 +42,9 @@ func (q *Queries) CreateLLMRequest(ctx context.Context, arg CreateLLMRequestPara
 		arg.OutputTokens,
 		arg.TotalTokens,
 		arg.Model,
+		arg.CostUsd,
+		arg.Purpose,
+		arg.UserID,
 	)
 	var i DataLlmRequest
 	err := row.Scan(
@@ -51,19 +57,22 @@ func (q *Queries) CreateLLMRequest(ctx context.Context, arg CreateLLMRequestPara
 		&i.Model,
 		&i.CreatedAt,
 		&i.MessageID,
+		&i.CostUsd,
+		&i.Purpose,
+		&i.UserID,
 	)
 	return &i, err
 }
 
 const getSessionLLMRequests = `-- name: GetSessionLLMRequests :many
-SELECT id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id FROM data.llm_requests
+SELECT id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id, cost_usd, purpose, user_id FROM data.llm_requests
 WHERE session_id = $1
 ORDER BY created_at ASC
 `
 
 // GetSessionLLMRequests
 //
-//	SELECT id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id FROM data.llm_requests
+//	SELECT id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id, cost_usd, purpose, user_id FROM data.llm_requests
 //	WHERE session_id = $1
 //	ORDER BY created_at ASC
 func (q *Queries) GetSessionLLMRequests(ctx context.Context, sessionID int64) ([]*DataLlmRequest, error) {
@@ -85,6 +94,9 @@ func (q *Queries) GetSessionLLMRequests(ctx context.Context, sessionID int64) ([
 			&i.Model,
 			&i.CreatedAt,
 			&i.MessageID,
+			&i.CostUsd,
+			&i.Purpose,
+			&i.UserID,
 		); err != nil {
 			return nil, err
 		}
@@ -96,39 +108,48 @@ func (q *Queries) GetSessionLLMRequests(ctx context.Context, sessionID int64) ([
 	return items, nil
 }
 
-const getSessionTokenUsage = `-- name: GetSessionTokenUsage :one
+const getSessionUserTokenUsage = `-- name: GetSessionUserTokenUsage :one
 SELECT
     COALESCE(SUM(input_tokens), 0)::INT AS total_input_tokens,
     COALESCE(SUM(output_tokens), 0)::INT AS total_output_tokens,
     COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
     COUNT(*)::INT AS request_count
 FROM data.llm_requests
-WHERE session_id = $1
+WHERE session_id = $1 AND user_id = $2
 `
 
-type GetSessionTokenUsageRow struct {
-	TotalInputTokens  int32 `json:"total_input_tokens"`
-	TotalOutputTokens int32 `json:"total_output_tokens"`
-	TotalTokens       int32 `json:"total_tokens"`
-	RequestCount      int32 `json:"request_count"`
+type GetSessionUserTokenUsageParams struct {
+	SessionID int64 `json:"session_id"`
+	UserID    int64 `json:"user_id"`
+}
+
+type GetSessionUserTokenUsageRow struct {
+	TotalInputTokens  int32   `json:"total_input_tokens"`
+	TotalOutputTokens int32   `json:"total_output_tokens"`
+	TotalTokens       int32   `json:"total_tokens"`
+	TotalCostUsd      float64 `json:"total_cost_usd"`
+	RequestCount      int32   `json:"request_count"`
 }
 
-// GetSessionTokenUsage
+// GetSessionUserTokenUsage
 //
 //	SELECT
 //	    COALESCE(SUM(input_tokens), 0)::INT AS total_input_tokens,
 //	    COALESCE(SUM(output_tokens), 0)::INT AS total_output_tokens,
 //	    COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+//	    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
 //	    COUNT(*)::INT AS request_count
 //	FROM data.llm_requests
-//	WHERE session_id = $1
-func (q *Queries) GetSessionTokenUsage(ctx context.Context, sessionID int64) (*GetSessionTokenUsageRow, error) {
-	row := q.db.QueryRow(ctx, getSessionTokenUsage, sessionID)
-	var i GetSessionTokenUsageRow
+//	WHERE session_id = $1 AND user_id = $2
+func (q *Queries) GetSessionUserTokenUsage(ctx context.Context, arg GetSessionUserTokenUsageParams) (*GetSessionUserTokenUsageRow, error) {
+	row := q.db.QueryRow(ctx, getSessionUserTokenUsage, arg.SessionID, arg.UserID)
+	var i GetSessionUserTokenUsageRow
 	err := row.Scan(
 		&i.TotalInputTokens,
 		&i.TotalOutputTokens,
 		&i.TotalTokens,
+		&i.TotalCostUsd,
 		&i.RequestCount,
 	)
 	return &i, err
@@ -139,17 +160,18 @@ SELECT
     COALESCE(SUM(lr.input_tokens), 0)::INT AS total_input_tokens,
     COALESCE(SUM(lr.output_tokens), 0)::INT AS total_output_tokens,
     COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd,
     COUNT(*)::INT AS request_count
 FROM data.llm_requests lr
-JOIN data.sessions s ON lr.session_id = s.id
-WHERE s.user_id = $1
+WHERE lr.user_id = $1
 `
 
 type GetUserTokenUsageRow struct {
-	TotalInputTokens  int32 `json:"total_input_tokens"`
-	TotalOutputTokens int32 `json:"total_output_tokens"`
-	TotalTokens       int32 `json:"total_tokens"`
-	RequestCount      int32 `json:"request_count"`
+	TotalInputTokens  int32   `json:"total_input_tokens"`
+	TotalOutputTokens int32   `json:"total_output_tokens"`
+	TotalTokens       int32   `json:"total_tokens"`
+	TotalCostUsd      float64 `json:"total_cost_usd"`
+	RequestCount      int32   `json:"request_count"`
 }
 
 // GetUserTokenUsage
@@ -158,10 +180,10 @@ type GetUserTokenUsageRow struct {
 //	    COALESCE(SUM(lr.input_tokens), 0)::INT AS total_input_tokens,
 //	    COALESCE(SUM(lr.output_tokens), 0)::INT AS total_output_tokens,
 //	    COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+//	    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd,
 //	    COUNT(*)::INT AS request_count
 //	FROM data.llm_requests lr
-//	JOIN data.sessions s ON lr.session_id = s.id
-//	WHERE s.user_id = $1
+//	WHERE lr.user_id = $1
 func (q *Queries) GetUserTokenUsage(ctx context.Context, userID int64) (*GetUserTokenUsageRow, error) {
 	row := q.db.QueryRow(ctx, getUserTokenUsage, userID)
 	var i GetUserTokenUsageRow
@@ -169,7 +191,234 @@ func (q *Queries) GetUserTokenUsage(ctx context.Context, userID int64) (*GetUser
 		&i.TotalInputTokens,
 		&i.TotalOutputTokens,
 		&i.TotalTokens,
+		&i.TotalCostUsd,
 		&i.RequestCount,
 	)
 	return &i, err
 }
+
+const getUserUsageSince = `-- name: GetUserUsageSince :one
+SELECT
+    COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd
+FROM data.llm_requests lr
+WHERE lr.user_id = $1 AND lr.created_at >= $2
+`
+
+type GetUserUsageSinceParams struct {
+	UserID    int64              `json:"user_id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type GetUserUsageSinceRow struct {
+	TotalTokens  int32   `json:"total_tokens"`
+	TotalCostUsd float64 `json:"total_cost_usd"`
+}
+
+// GetUserUsageSince
+//
+//	SELECT
+//	    COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+//	    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd
+//	FROM data.llm_requests lr
+//	WHERE lr.user_id = $1 AND lr.created_at >= $2
+func (q *Queries) GetUserUsageSince(ctx context.Context, arg GetUserUsageSinceParams) (*GetUserUsageSinceRow, error) {
+	row := q.db.QueryRow(ctx, getUserUsageSince, arg.UserID, arg.CreatedAt)
+	var i GetUserUsageSinceRow
+	err := row.Scan(
+		&i.TotalTokens,
+		&i.TotalCostUsd,
+	)
+	return &i, err
+}
+
+const listDailyUsage = `-- name: ListDailyUsage :many
+SELECT
+    (created_at AT TIME ZONE 'UTC')::DATE AS day,
+    COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
+    COUNT(*)::INT AS request_count
+FROM data.llm_requests
+WHERE created_at >= $1
+GROUP BY day
+ORDER BY day DESC
+`
+
+type ListDailyUsageRow struct {
+	Day          pgtype.Date `json:"day"`
+	TotalTokens  int32       `json:"total_tokens"`
+	TotalCostUsd float64     `json:"total_cost_usd"`
+	RequestCount int32       `json:"request_count"`
+}
+
+// ListDailyUsage
+//
+//	SELECT
+//	    (created_at AT TIME ZONE 'UTC')::DATE AS day,
+//	    COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+//	    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
+//	    COUNT(*)::INT AS request_count
+//	FROM data.llm_requests
+//	WHERE created_at >= $1
+//	GROUP BY day
+//	ORDER BY day DESC
+func (q *Queries) ListDailyUsage(ctx context.Context, createdAt pgtype.Timestamptz) ([]*ListDailyUsageRow, error) {
+	rows, err := q.db.Query(ctx, listDailyUsage, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListDailyUsageRow
+	for rows.Next() {
+		var i ListDailyUsageRow
+		if err := rows.Scan(
+			&i.Day,
+			&i.TotalTokens,
+			&i.TotalCostUsd,
+			&i.RequestCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listModelUsage = `-- name: ListModelUsage :many
+SELECT
+    model,
+    COALESCE(SUM(input_tokens), 0)::INT AS total_input_tokens,
+    COALESCE(SUM(output_tokens), 0)::INT AS total_output_tokens,
+    COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
+    COUNT(*)::INT AS request_count
+FROM data.llm_requests
+WHERE created_at >= $1
+GROUP BY model
+ORDER BY total_cost_usd DESC, total_tokens DESC
+`
+
+type ListModelUsageRow struct {
+	Model             string  `json:"model"`
+	TotalInputTokens  int32   `json:"total_input_tokens"`
+	TotalOutputTokens int32   `json:"total_output_tokens"`
+	TotalTokens       int32   `json:"total_tokens"`
+	TotalCostUsd      float64 `json:"total_cost_usd"`
+	RequestCount      int32   `json:"request_count"`
+}
+
+// ListModelUsage
+//
+//	SELECT
+//	    model,
+//	    COALESCE(SUM(input_tokens), 0)::INT AS total_input_tokens,
+//	    COALESCE(SUM(output_tokens), 0)::INT AS total_output_tokens,
+//	    COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+//	    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
+//	    COUNT(*)::INT AS request_count
+//	FROM data.llm_requests
+//	WHERE created_at >= $1
+//	GROUP BY model
+//	ORDER BY total_cost_usd DESC, total_tokens DESC
+func (q *Queries) ListModelUsage(ctx context.Context, createdAt pgtype.Timestamptz) ([]*ListModelUsageRow, error) {
+	rows, err := q.db.Query(ctx, listModelUsage, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListModelUsageRow
+	for rows.Next() {
+		var i ListModelUsageRow
+		if err := rows.Scan(
+			&i.Model,
+			&i.TotalInputTokens,
+			&i.TotalOutputTokens,
+			&i.TotalTokens,
+			&i.TotalCostUsd,
+			&i.RequestCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserTokenUsage = `-- name: ListUserTokenUsage :many
+SELECT
+    u.id AS user_id,
+    u.telegram_id,
+    u.username,
+    COALESCE(SUM(lr.input_tokens), 0)::INT AS total_input_tokens,
+    COALESCE(SUM(lr.output_tokens), 0)::INT AS total_output_tokens,
+    COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd,
+    COUNT(*)::INT AS request_count
+FROM data.llm_requests lr
+JOIN data.users u ON lr.user_id = u.id
+GROUP BY u.id
+ORDER BY total_cost_usd DESC, total_tokens DESC
+LIMIT $1
+`
+
+type ListUserTokenUsageRow struct {
+	UserID            int64       `json:"user_id"`
+	TelegramID        int64       `json:"telegram_id"`
+	Username          pgtype.Text `json:"username"`
+	TotalInputTokens  int32       `json:"total_input_tokens"`
+	TotalOutputTokens int32       `json:"total_output_tokens"`
+	TotalTokens       int32       `json:"total_tokens"`
+	TotalCostUsd      float64     `json:"total_cost_usd"`
+	RequestCount      int32       `json:"request_count"`
+}
+
+// ListUserTokenUsage
+//
+//	SELECT
+//	    u.id AS user_id,
+//	    u.telegram_id,
+//	    u.username,
+//	    COALESCE(SUM(lr.input_tokens), 0)::INT AS total_input_tokens,
+//	    COALESCE(SUM(lr.output_tokens), 0)::INT AS total_output_tokens,
+//	    COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+//	    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd,
+//	    COUNT(*)::INT AS request_count
+//	FROM data.llm_requests lr
+//	JOIN data.users u ON lr.user_id = u.id
+//	GROUP BY u.id
+//	ORDER BY total_cost_usd DESC, total_tokens DESC
+//	LIMIT $1
+func (q *Queries) ListUserTokenUsage(ctx context.Context, limit int32) ([]*ListUserTokenUsageRow, error) {
+	rows, err := q.db.Query(ctx, listUserTokenUsage, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListUserTokenUsageRow
+	for rows.Next() {
+		var i ListUserTokenUsageRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.TelegramID,
+			&i.Username,
+			&i.TotalInputTokens,
+			&i.TotalOutputTokens,
+			&i.TotalTokens,
+			&i.TotalCostUsd,
+			&i.RequestCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -7,27 +7,35 @@ package dbgen
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const addMessage = `-- name: AddMessage :one
-INSERT INTO data.messages (session_id, role, content)
-VALUES ($1, $2, $3)
+INSERT INTO data.messages (session_id, role, content, speaker)
+VALUES ($1, $2, $3, $4)
 RETURNING id
 `
 
 type AddMessageParams struct {
-	SessionID int64  `json:"session_id"`
-	Role      string `json:"role"`
-	Content   string `json:"content"`
+	SessionID int64       `json:"session_id"`
+	Role      string      `json:"role"`
+	Content   string      `json:"content"`
+	Speaker   pgtype.Text `json:"speaker"`
 }
 
 // AddMessage
 //
-//	INSERT INTO data.messages (session_id, role, content)
-//	VALUES ($1, $2, $3)
+//	INSERT INTO data.messages (session_id, role, content, speaker)
+//	VALUES ($1, $2, $3, $4)
 //	RETURNING id
 func (q *Queries) AddMessage(ctx context.Context, arg AddMessageParams) (int64, error) {
-	row := q.db.QueryRow(ctx, addMessage, arg.SessionID, arg.Role, arg.Content)
+	row := q.db.QueryRow(ctx, addMessage,
+		arg.SessionID,
+		arg.Role,
+		arg.Content,
+		arg.Speaker,
+	)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
@@ -52,7 +60,7 @@ func (q *Queries) CountSessionMessages(ctx context.Context, sessionID int64) (in
 }
 
 const getSessionMessages = `-- name: GetSessionMessages :many
-SELECT id, uuid, session_id, role, content, created_at
+SELECT id, uuid, session_id, role, content, created_at, speaker
 FROM data.messages
 WHERE session_id = $1
 ORDER BY created_at ASC
@@ -60,7 +68,7 @@ ORDER BY created_at ASC
 
 // GetSessionMessages
 //
-//	SELECT id, uuid, session_id, role, content, created_at
+//	SELECT id, uuid, session_id, role, content, created_at, speaker
 //	FROM data.messages
 //	WHERE session_id = $1
 //	ORDER BY created_at ASC
@@ -80,6 +88,7 @@ func (q *Queries) GetSessionMessages(ctx context.Context, sessionID int64) ([]*D
 			&i.Role,
 			&i.Content,
 			&i.CreatedAt,
+			&i.Speaker,
 		); err != nil {
 			return nil, err
 		}
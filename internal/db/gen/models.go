@@ -8,6 +8,63 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type DataAgentStep struct {
+	ID           int64              `json:"id"`
+	Uuid         string             `json:"uuid"`
+	SessionID    int64              `json:"session_id"`
+	MessageID    pgtype.Int8        `json:"message_id"`
+	LlmRequestID pgtype.Int8        `json:"llm_request_id"`
+	StepNumber   int32              `json:"step_number"`
+	Response     string             `json:"response"`
+	Command      pgtype.Text        `json:"command"`
+	Stdout       string             `json:"stdout"`
+	Stderr       string             `json:"stderr"`
+	ExitCode     pgtype.Int4        `json:"exit_code"`
+	TimedOut     bool               `json:"timed_out"`
+	ErrorMessage pgtype.Text        `json:"error_message"`
+	InputTokens  int32              `json:"input_tokens"`
+	OutputTokens int32              `json:"output_tokens"`
+	TotalTokens  int32              `json:"total_tokens"`
+	DurationMs   int32              `json:"duration_ms"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type DataAttachment struct {
+	ID             int64              `json:"id"`
+	Uuid           string             `json:"uuid"`
+	MessageID      int64              `json:"message_id"`
+	Type           string             `json:"type"`
+	TelegramFileID string             `json:"telegram_file_id"`
+	MimeType       string             `json:"mime_type"`
+	SizeBytes      int32              `json:"size_bytes"`
+	Width          pgtype.Int4        `json:"width"`
+	Height         pgtype.Int4        `json:"height"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+type DataChat struct {
+	ID         int64              `json:"id"`
+	Uuid       string             `json:"uuid"`
+	TelegramID int64              `json:"telegram_id"`
+	Type       string             `json:"type"`
+	Title      pgtype.Text        `json:"title"`
+	Profile    pgtype.Text        `json:"profile"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt  pgtype.Timestamptz `json:"updated_at"`
+}
+
+type DataContextChunk struct {
+	ID         int64              `json:"id"`
+	Uuid       string             `json:"uuid"`
+	Path       string             `json:"path"`
+	ChunkIndex int32              `json:"chunk_index"`
+	Heading    string             `json:"heading"`
+	Content    string             `json:"content"`
+	Tokens     int32              `json:"tokens"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	Dir        string             `json:"dir"`
+}
+
 type DataLlmRequest struct {
 	ID           int64              `json:"id"`
 	Uuid         string             `json:"uuid"`
@@ -18,6 +75,18 @@ type DataLlmRequest struct {
 	Model        string             `json:"model"`
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
 	MessageID    pgtype.Int8        `json:"message_id"`
+	CostUsd      float64            `json:"cost_usd"`
+	Purpose      string             `json:"purpose"`
+	UserID       int64              `json:"user_id"`
+}
+
+type DataMemory struct {
+	ID        int64              `json:"id"`
+	Uuid      string             `json:"uuid"`
+	UserID    int64              `json:"user_id"`
+	MessageID pgtype.Int8        `json:"message_id"`
+	Content   string             `json:"content"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
 type DataMessage struct {
@@ -27,6 +96,7 @@ type DataMessage struct {
 	Role      string             `json:"role"`
 	Content   string             `json:"content"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Speaker   pgtype.Text        `json:"speaker"`
 }
 
 type DataSession struct {
@@ -36,6 +106,11 @@ type DataSession struct {
 	SystemPrompt pgtype.Text        `json:"system_prompt"`
 	EndedAt      pgtype.Timestamptz `json:"ended_at"`
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	Summary      pgtype.Text        `json:"summary"`
+	AgentPrompt  pgtype.Text        `json:"agent_prompt"`
+	Profile      pgtype.Text        `json:"profile"`
+	ChatID       int64              `json:"chat_id"`
+	ThreadID     int64              `json:"thread_id"`
 }
 
 type DataUser struct {
@@ -48,4 +123,8 @@ type DataUser struct {
 	LanguageCode pgtype.Text        `json:"language_code"`
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+	Role         string             `json:"role"`
+	Mode         pgtype.Text        `json:"mode"`
+	Model        pgtype.Text        `json:"model"`
+	Profile      pgtype.Text        `json:"profile"`
 }
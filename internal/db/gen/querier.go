@@ -6,13 +6,15 @@ package dbgen
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
 	//AddMessage
 	//
-	//  INSERT INTO data.messages (session_id, role, content)
-	//  VALUES ($1, $2, $3)
+	//  INSERT INTO data.messages (session_id, role, content, speaker)
+	//  VALUES ($1, $2, $3, $4)
 	//  RETURNING id
 	AddMessage(ctx context.Context, arg AddMessageParams) (int64, error)
 	//CountSessionMessages
@@ -21,65 +23,131 @@ type Querier interface {
 	//  FROM data.messages
 	//  WHERE session_id = $1
 	CountSessionMessages(ctx context.Context, sessionID int64) (int64, error)
-	//CreateLLMRequest
+	//CreateAgentStep
+	//
+	//  INSERT INTO data.agent_steps (
+	//      session_id, message_id, llm_request_id, step_number, response, command,
+	//      stdout, stderr, exit_code, timed_out, error_message,
+	//      input_tokens, output_tokens, total_tokens, duration_ms
+	//  )
+	//  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	//  RETURNING id
+	CreateAgentStep(ctx context.Context, arg CreateAgentStepParams) (int64, error)
+	//CreateAttachment
 	//
-	//  INSERT INTO data.llm_requests (session_id, message_id, input_tokens, output_tokens, total_tokens, model)
+	//  INSERT INTO data.attachments (message_id, type, telegram_file_id, mime_type, size_bytes, width, height)
+	//  VALUES ($1, $2, $3, $4, $5, $6, $7)
+	//  RETURNING id, uuid, message_id, type, telegram_file_id, mime_type, size_bytes, width, height, created_at
+	CreateAttachment(ctx context.Context, arg CreateAttachmentParams) (*DataAttachment, error)
+	//CreateContextChunk
+	//
+	//  INSERT INTO data.context_chunks (dir, path, chunk_index, heading, content, tokens)
 	//  VALUES ($1, $2, $3, $4, $5, $6)
-	//  RETURNING id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id
+	CreateContextChunk(ctx context.Context, arg CreateContextChunkParams) error
+	//CreateLLMRequest
+	//
+	//  INSERT INTO data.llm_requests (session_id, message_id, input_tokens, output_tokens, total_tokens, model, cost_usd, purpose, user_id)
+	//  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	//  RETURNING id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id, cost_usd, purpose, user_id
 	CreateLLMRequest(ctx context.Context, arg CreateLLMRequestParams) (*DataLlmRequest, error)
+	//CreateMemory
+	//
+	//  INSERT INTO data.memories (user_id, message_id, content)
+	//  VALUES ($1, $2, $3)
+	//  ON CONFLICT (user_id, content) DO NOTHING
+	CreateMemory(ctx context.Context, arg CreateMemoryParams) (int64, error)
 	//CreateSession
 	//
-	//  INSERT INTO data.sessions (user_id, system_prompt)
-	//  VALUES ($1, $2)
-	//  RETURNING id, uuid, user_id, system_prompt, ended_at, created_at
+	//  INSERT INTO data.sessions (user_id, chat_id, thread_id, system_prompt, agent_prompt, profile)
+	//  VALUES ($1, $2, $3, $4, $5, $6)
+	//  RETURNING id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile, chat_id, thread_id
 	CreateSession(ctx context.Context, arg CreateSessionParams) (*DataSession, error)
 	//CreateUser
 	//
 	//  INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code)
 	//  VALUES ($1, $2, $3, $4, $5)
-	//  RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at
+	//  RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
 	CreateUser(ctx context.Context, arg CreateUserParams) (*DataUser, error)
+	//DeleteContextChunks
+	//
+	//  DELETE FROM data.context_chunks
+	DeleteContextChunks(ctx context.Context) (int64, error)
+	//DeleteUserMemories
+	//
+	//  DELETE FROM data.memories WHERE user_id = $1
+	DeleteUserMemories(ctx context.Context, userID int64) (int64, error)
+	//DeleteUserMemory
+	//
+	//  DELETE FROM data.memories WHERE id = $1 AND user_id = $2
+	DeleteUserMemory(ctx context.Context, arg DeleteUserMemoryParams) (int64, error)
 	//EndSession
 	//
 	//  UPDATE data.sessions SET ended_at = NOW() WHERE id = $1
 	EndSession(ctx context.Context, id int64) error
+	//EndSessionWithSummary
+	//
+	//  UPDATE data.sessions SET ended_at = NOW(), summary = $2 WHERE id = $1
+	EndSessionWithSummary(ctx context.Context, arg EndSessionWithSummaryParams) error
 	//GetActiveSession
 	//
-	//  SELECT id, uuid, user_id, system_prompt, ended_at, created_at FROM data.sessions
-	//  WHERE user_id = $1 AND ended_at IS NULL
+	//  SELECT id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile, chat_id, thread_id FROM data.sessions
+	//  WHERE chat_id = $1 AND thread_id = $2 AND ended_at IS NULL
 	//  ORDER BY created_at DESC
 	//  LIMIT 1
-	GetActiveSession(ctx context.Context, userID int64) (*DataSession, error)
+	GetActiveSession(ctx context.Context, arg GetActiveSessionParams) (*DataSession, error)
+	//GetMessageAgentSteps
+	//
+	//  SELECT id, uuid, session_id, message_id, llm_request_id, step_number, response, command, stdout, stderr, exit_code, timed_out, error_message, input_tokens, output_tokens, total_tokens, duration_ms, created_at FROM data.agent_steps
+	//  WHERE message_id = $1
+	//  ORDER BY step_number ASC
+	GetMessageAgentSteps(ctx context.Context, messageID pgtype.Int8) ([]*DataAgentStep, error)
+	//GetSessionAgentSteps
+	//
+	//  SELECT id, uuid, session_id, message_id, llm_request_id, step_number, response, command, stdout, stderr, exit_code, timed_out, error_message, input_tokens, output_tokens, total_tokens, duration_ms, created_at FROM data.agent_steps
+	//  WHERE session_id = $1
+	//  ORDER BY created_at ASC, step_number ASC
+	GetSessionAgentSteps(ctx context.Context, sessionID int64) ([]*DataAgentStep, error)
+	//GetSessionAttachments
+	//
+	//  SELECT id, uuid, message_id, type, telegram_file_id, mime_type, size_bytes, width, height, created_at FROM data.attachments
+	//  WHERE message_id IN (SELECT id FROM data.messages WHERE session_id = $1)
+	//  ORDER BY message_id, id
+	GetSessionAttachments(ctx context.Context, sessionID int64) ([]*DataAttachment, error)
 	//GetSessionLLMRequests
 	//
-	//  SELECT id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id FROM data.llm_requests
+	//  SELECT id, uuid, session_id, input_tokens, output_tokens, total_tokens, model, created_at, message_id, cost_usd, purpose, user_id FROM data.llm_requests
 	//  WHERE session_id = $1
 	//  ORDER BY created_at ASC
 	GetSessionLLMRequests(ctx context.Context, sessionID int64) ([]*DataLlmRequest, error)
 	//GetSessionMessages
 	//
-	//  SELECT id, uuid, session_id, role, content, created_at
+	//  SELECT id, uuid, session_id, role, content, created_at, speaker
 	//  FROM data.messages
 	//  WHERE session_id = $1
 	//  ORDER BY created_at ASC
 	GetSessionMessages(ctx context.Context, sessionID int64) ([]*DataMessage, error)
-	//GetSessionTokenUsage
+	//GetSessionUserTokenUsage
 	//
 	//  SELECT
 	//      COALESCE(SUM(input_tokens), 0)::INT AS total_input_tokens,
 	//      COALESCE(SUM(output_tokens), 0)::INT AS total_output_tokens,
 	//      COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+	//      COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
 	//      COUNT(*)::INT AS request_count
 	//  FROM data.llm_requests
-	//  WHERE session_id = $1
-	GetSessionTokenUsage(ctx context.Context, sessionID int64) (*GetSessionTokenUsageRow, error)
+	//  WHERE session_id = $1 AND user_id = $2
+	GetSessionUserTokenUsage(ctx context.Context, arg GetSessionUserTokenUsageParams) (*GetSessionUserTokenUsageRow, error)
 	//GetUserByTelegramID
 	//
-	//  SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at FROM data.users WHERE telegram_id = $1
+	//  SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users WHERE telegram_id = $1
 	GetUserByTelegramID(ctx context.Context, telegramID int64) (*DataUser, error)
+	//GetUserByUsername
+	//
+	//  SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users WHERE LOWER(username) = LOWER($1)
+	GetUserByUsername(ctx context.Context, username string) (*DataUser, error)
 	//GetUserSessions
 	//
-	//  SELECT id, uuid, user_id, system_prompt, ended_at, created_at FROM data.sessions
+	//  SELECT id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile FROM data.sessions
 	//  WHERE user_id = $1
 	//  ORDER BY created_at DESC
 	//  LIMIT $2
@@ -90,22 +158,157 @@ type Querier interface {
 	//      COALESCE(SUM(lr.input_tokens), 0)::INT AS total_input_tokens,
 	//      COALESCE(SUM(lr.output_tokens), 0)::INT AS total_output_tokens,
 	//      COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+	//      COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd,
 	//      COUNT(*)::INT AS request_count
 	//  FROM data.llm_requests lr
-	//  JOIN data.sessions s ON lr.session_id = s.id
-	//  WHERE s.user_id = $1
+	//  WHERE lr.user_id = $1
 	GetUserTokenUsage(ctx context.Context, userID int64) (*GetUserTokenUsageRow, error)
+	//GetUserUsageSince
+	//
+	//  SELECT
+	//      COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+	//      COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd
+	//  FROM data.llm_requests lr
+	//  WHERE lr.user_id = $1 AND lr.created_at >= $2
+	GetUserUsageSince(ctx context.Context, arg GetUserUsageSinceParams) (*GetUserUsageSinceRow, error)
+	//ListBroadcastRecipients
+	//
+	//  SELECT telegram_id FROM data.users
+	//  WHERE role <> 'blocked'
+	//  ORDER BY id ASC
+	ListBroadcastRecipients(ctx context.Context) ([]int64, error)
+	//ListDailyUsage
+	//
+	//  SELECT
+	//      (created_at AT TIME ZONE 'UTC')::DATE AS day,
+	//      COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+	//      COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
+	//      COUNT(*)::INT AS request_count
+	//  FROM data.llm_requests
+	//  WHERE created_at >= $1
+	//  GROUP BY day
+	//  ORDER BY day DESC
+	ListDailyUsage(ctx context.Context, createdAt pgtype.Timestamptz) ([]*ListDailyUsageRow, error)
+	//ListModelUsage
+	//
+	//  SELECT
+	//      model,
+	//      COALESCE(SUM(input_tokens), 0)::INT AS total_input_tokens,
+	//      COALESCE(SUM(output_tokens), 0)::INT AS total_output_tokens,
+	//      COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+	//      COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
+	//      COUNT(*)::INT AS request_count
+	//  FROM data.llm_requests
+	//  WHERE created_at >= $1
+	//  GROUP BY model
+	//  ORDER BY total_cost_usd DESC, total_tokens DESC
+	ListModelUsage(ctx context.Context, createdAt pgtype.Timestamptz) ([]*ListModelUsageRow, error)
+	//ListUserMemories
+	//
+	//  SELECT id, uuid, user_id, message_id, content, created_at FROM data.memories
+	//  WHERE user_id = $1
+	//  ORDER BY created_at, id
+	ListUserMemories(ctx context.Context, userID int64) ([]*DataMemory, error)
+	//ListUserTokenUsage
+	//
+	//  SELECT
+	//      u.id AS user_id,
+	//      u.telegram_id,
+	//      u.username,
+	//      COALESCE(SUM(lr.input_tokens), 0)::INT AS total_input_tokens,
+	//      COALESCE(SUM(lr.output_tokens), 0)::INT AS total_output_tokens,
+	//      COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+	//      COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd,
+	//      COUNT(*)::INT AS request_count
+	//  FROM data.llm_requests lr
+	//  JOIN data.users u ON lr.user_id = u.id
+	//  GROUP BY u.id
+	//  ORDER BY total_cost_usd DESC, total_tokens DESC
+	//  LIMIT $1
+	ListUserTokenUsage(ctx context.Context, limit int32) ([]*ListUserTokenUsageRow, error)
+	//ListUsers
+	//
+	//  SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users
+	//  ORDER BY id ASC
+	//  LIMIT $1 OFFSET $2
+	ListUsers(ctx context.Context, arg ListUsersParams) ([]*DataUser, error)
+	//SearchContextChunks
+	//
+	//  SELECT id, uuid, path, chunk_index, heading, content, tokens, created_at, dir FROM data.context_chunks
+	//  WHERE dir = $1
+	//    AND to_tsvector('english', heading || ' ' || content) @@ replace(plainto_tsquery('english', $2::TEXT)::TEXT, ' & ', ' | ')::TSQUERY
+	//  ORDER BY ts_rank(to_tsvector('english', heading || ' ' || content), replace(plainto_tsquery('english', $2::TEXT)::TEXT, ' & ', ' | ')::TSQUERY) DESC, path, chunk_index
+	//  LIMIT $3
+	SearchContextChunks(ctx context.Context, arg SearchContextChunksParams) ([]*DataContextChunk, error)
+	//SearchUserMemories
+	//
+	//  SELECT id, uuid, user_id, message_id, content, created_at FROM data.memories
+	//  WHERE user_id = $1
+	//    AND to_tsvector('english', content) @@ replace(plainto_tsquery('english', $2::TEXT)::TEXT, ' & ', ' | ')::TSQUERY
+	//  ORDER BY ts_rank(to_tsvector('english', content), replace(plainto_tsquery('english', $2::TEXT)::TEXT, ' & ', ' | ')::TSQUERY) DESC, created_at DESC
+	//  LIMIT $3
+	SearchUserMemories(ctx context.Context, arg SearchUserMemoriesParams) ([]*DataMemory, error)
+	//SetChatProfile
+	//
+	//  UPDATE data.chats
+	//  SET profile = $2,
+	//      updated_at = NOW()
+	//  WHERE id = $1
+	//  RETURNING id, uuid, telegram_id, type, title, profile, created_at, updated_at
+	SetChatProfile(ctx context.Context, arg SetChatProfileParams) (*DataChat, error)
+	//SetUserMode
+	//
+	//  UPDATE data.users
+	//  SET mode = $2,
+	//      updated_at = NOW()
+	//  WHERE id = $1
+	//  RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+	SetUserMode(ctx context.Context, arg SetUserModeParams) (*DataUser, error)
+	//SetUserModel
+	//
+	//  UPDATE data.users
+	//  SET model = $2,
+	//      updated_at = NOW()
+	//  WHERE id = $1
+	//  RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+	SetUserModel(ctx context.Context, arg SetUserModelParams) (*DataUser, error)
+	//SetUserProfile
+	//
+	//  UPDATE data.users
+	//  SET profile = $2,
+	//      updated_at = NOW()
+	//  WHERE id = $1
+	//  RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+	SetUserProfile(ctx context.Context, arg SetUserProfileParams) (*DataUser, error)
+	//SetUserRole
+	//
+	//  UPDATE data.users
+	//  SET role = $2,
+	//      updated_at = NOW()
+	//  WHERE id = $1
+	//  RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+	SetUserRole(ctx context.Context, arg SetUserRoleParams) (*DataUser, error)
+	//UpsertChat
+	//
+	//  INSERT INTO data.chats (telegram_id, type, title)
+	//  VALUES ($1, $2, $3)
+	//  ON CONFLICT (telegram_id) DO UPDATE
+	//  SET type = EXCLUDED.type,
+	//      title = EXCLUDED.title,
+	//      updated_at = NOW()
+	//  RETURNING id, uuid, telegram_id, type, title, profile, created_at, updated_at
+	UpsertChat(ctx context.Context, arg UpsertChatParams) (*DataChat, error)
 	//UpsertUser
 	//
-	//  INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code)
-	//  VALUES ($1, $2, $3, $4, $5)
+	//  INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code, role)
+	//  VALUES ($1, $2, $3, $4, $5, $6)
 	//  ON CONFLICT (telegram_id) DO UPDATE
 	//  SET username = EXCLUDED.username,
 	//      first_name = EXCLUDED.first_name,
 	//      last_name = EXCLUDED.last_name,
 	//      language_code = EXCLUDED.language_code,
 	//      updated_at = NOW()
-	//  RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at
+	//  RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
 	UpsertUser(ctx context.Context, arg UpsertUserParams) (*DataUser, error)
 }
 
@@ -12,23 +12,34 @@ import (
 )
 
 const createSession = `-- name: CreateSession :one
-INSERT INTO data.sessions (user_id, system_prompt)
-VALUES ($1, $2)
-RETURNING id, uuid, user_id, system_prompt, ended_at, created_at
+INSERT INTO data.sessions (user_id, chat_id, thread_id, system_prompt, agent_prompt, profile)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile, chat_id, thread_id
 `
 
 type CreateSessionParams struct {
 	UserID       int64       `json:"user_id"`
+	ChatID       int64       `json:"chat_id"`
+	ThreadID     int64       `json:"thread_id"`
 	SystemPrompt pgtype.Text `json:"system_prompt"`
+	AgentPrompt  pgtype.Text `json:"agent_prompt"`
+	Profile      pgtype.Text `json:"profile"`
 }
 
 // CreateSession
 //
-//	INSERT INTO data.sessions (user_id, system_prompt)
-//	VALUES ($1, $2)
-//	RETURNING id, uuid, user_id, system_prompt, ended_at, created_at
+//	INSERT INTO data.sessions (user_id, chat_id, thread_id, system_prompt, agent_prompt, profile)
+//	VALUES ($1, $2, $3, $4, $5, $6)
+//	RETURNING id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile, chat_id, thread_id
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (*DataSession, error) {
-	row := q.db.QueryRow(ctx, createSession, arg.UserID, arg.SystemPrompt)
+	row := q.db.QueryRow(ctx, createSession,
+		arg.UserID,
+		arg.ChatID,
+		arg.ThreadID,
+		arg.SystemPrompt,
+		arg.AgentPrompt,
+		arg.Profile,
+	)
 	var i DataSession
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +48,11 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (*
 		&i.SystemPrompt,
 		&i.EndedAt,
 		&i.CreatedAt,
+		&i.Summary,
+		&i.AgentPrompt,
+		&i.Profile,
+		&i.ChatID,
+		&i.ThreadID,
 	)
 	return &i, err
 }
@@ -53,21 +69,43 @@ func (q *Queries) EndSession(ctx context.Context, id int64) error {
 	return err
 }
 
+const endSessionWithSummary = `-- name: EndSessionWithSummary :exec
+UPDATE data.sessions SET ended_at = NOW(), summary = $2 WHERE id = $1
+`
+
+type EndSessionWithSummaryParams struct {
+	ID      int64       `json:"id"`
+	Summary pgtype.Text `json:"summary"`
+}
+
+// EndSessionWithSummary
+//
+//	UPDATE data.sessions SET ended_at = NOW(), summary = $2 WHERE id = $1
+func (q *Queries) EndSessionWithSummary(ctx context.Context, arg EndSessionWithSummaryParams) error {
+	_, err := q.db.Exec(ctx, endSessionWithSummary, arg.ID, arg.Summary)
+	return err
+}
+
 const getActiveSession = `-- name: GetActiveSession :one
-SELECT id, uuid, user_id, system_prompt, ended_at, created_at FROM data.sessions
-WHERE user_id = $1 AND ended_at IS NULL
+SELECT id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile, chat_id, thread_id FROM data.sessions
+WHERE chat_id = $1 AND thread_id = $2 AND ended_at IS NULL
 ORDER BY created_at DESC
 LIMIT 1
 `
 
+type GetActiveSessionParams struct {
+	ChatID   int64 `json:"chat_id"`
+	ThreadID int64 `json:"thread_id"`
+}
+
 // GetActiveSession
 //
-//	SELECT id, uuid, user_id, system_prompt, ended_at, created_at FROM data.sessions
-//	WHERE user_id = $1 AND ended_at IS NULL
+//	SELECT id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile, chat_id, thread_id FROM data.sessions
+//	WHERE chat_id = $1 AND thread_id = $2 AND ended_at IS NULL
 //	ORDER BY created_at DESC
 //	LIMIT 1
-func (q *Queries) GetActiveSession(ctx context.Context, userID int64) (*DataSession, error) {
-	row := q.db.QueryRow(ctx, getActiveSession, userID)
+func (q *Queries) GetActiveSession(ctx context.Context, arg GetActiveSessionParams) (*DataSession, error) {
+	row := q.db.QueryRow(ctx, getActiveSession, arg.ChatID, arg.ThreadID)
 	var i DataSession
 	err := row.Scan(
 		&i.ID,
@@ -76,12 +114,17 @@ func (q *Queries) GetActiveSession(ctx context.Context, userID int64) (*DataSess
 		&i.SystemPrompt,
 		&i.EndedAt,
 		&i.CreatedAt,
+		&i.Summary,
+		&i.AgentPrompt,
+		&i.Profile,
+		&i.ChatID,
+		&i.ThreadID,
 	)
 	return &i, err
 }
 
 const getUserSessions = `-- name: GetUserSessions :many
-SELECT id, uuid, user_id, system_prompt, ended_at, created_at FROM data.sessions
+SELECT id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile, chat_id, thread_id FROM data.sessions
 WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2
@@ -94,7 +137,7 @@ type GetUserSessionsParams struct {
 
 // GetUserSessions
 //
-//	SELECT id, uuid, user_id, system_prompt, ended_at, created_at FROM data.sessions
+//	SELECT id, uuid, user_id, system_prompt, ended_at, created_at, summary, agent_prompt, profile, chat_id, thread_id FROM data.sessions
 //	WHERE user_id = $1
 //	ORDER BY created_at DESC
 //	LIMIT $2
@@ -114,6 +157,11 @@ func (q *Queries) GetUserSessions(ctx context.Context, arg GetUserSessionsParams
 			&i.SystemPrompt,
 			&i.EndedAt,
 			&i.CreatedAt,
+			&i.Summary,
+			&i.AgentPrompt,
+			&i.Profile,
+			&i.ChatID,
+			&i.ThreadID,
 		); err != nil {
 			return nil, err
 		}
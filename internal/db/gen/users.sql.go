@@ -14,7 +14,7 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at
+RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
 `
 
 type CreateUserParams struct {
@@ -29,7 +29,7 @@ type CreateUserParams struct {
 //
 //	INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code)
 //	VALUES ($1, $2, $3, $4, $5)
-//	RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at
+//	RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (*DataUser, error) {
 	row := q.db.QueryRow(ctx, createUser,
 		arg.TelegramID,
@@ -49,17 +49,21 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (*DataUs
 		&i.LanguageCode,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.Mode,
+		&i.Model,
+		&i.Profile,
 	)
 	return &i, err
 }
 
 const getUserByTelegramID = `-- name: GetUserByTelegramID :one
-SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at FROM data.users WHERE telegram_id = $1
+SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users WHERE telegram_id = $1
 `
 
 // GetUserByTelegramID
 //
-//	SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at FROM data.users WHERE telegram_id = $1
+//	SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users WHERE telegram_id = $1
 func (q *Queries) GetUserByTelegramID(ctx context.Context, telegramID int64) (*DataUser, error) {
 	row := q.db.QueryRow(ctx, getUserByTelegramID, telegramID)
 	var i DataUser
@@ -73,20 +77,297 @@ func (q *Queries) GetUserByTelegramID(ctx context.Context, telegramID int64) (*D
 		&i.LanguageCode,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.Mode,
+		&i.Model,
+		&i.Profile,
+	)
+	return &i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users WHERE LOWER(username) = LOWER($1)
+`
+
+// GetUserByUsername
+//
+//	SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users WHERE LOWER(username) = LOWER($1)
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (*DataUser, error) {
+	row := q.db.QueryRow(ctx, getUserByUsername, username)
+	var i DataUser
+	err := row.Scan(
+		&i.ID,
+		&i.Uuid,
+		&i.TelegramID,
+		&i.Username,
+		&i.FirstName,
+		&i.LastName,
+		&i.LanguageCode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.Mode,
+		&i.Model,
+		&i.Profile,
+	)
+	return &i, err
+}
+
+const listBroadcastRecipients = `-- name: ListBroadcastRecipients :many
+SELECT telegram_id FROM data.users
+WHERE role <> 'blocked'
+ORDER BY id ASC
+`
+
+// ListBroadcastRecipients
+//
+//	SELECT telegram_id FROM data.users
+//	WHERE role <> 'blocked'
+//	ORDER BY id ASC
+func (q *Queries) ListBroadcastRecipients(ctx context.Context) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listBroadcastRecipients)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var telegram_id int64
+		if err := rows.Scan(&telegram_id); err != nil {
+			return nil, err
+		}
+		items = append(items, telegram_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users
+ORDER BY id ASC
+LIMIT $1 OFFSET $2
+`
+
+type ListUsersParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+// ListUsers
+//
+//	SELECT id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile FROM data.users
+//	ORDER BY id ASC
+//	LIMIT $1 OFFSET $2
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]*DataUser, error) {
+	rows, err := q.db.Query(ctx, listUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*DataUser
+	for rows.Next() {
+		var i DataUser
+		if err := rows.Scan(
+			&i.ID,
+			&i.Uuid,
+			&i.TelegramID,
+			&i.Username,
+			&i.FirstName,
+			&i.LastName,
+			&i.LanguageCode,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Role,
+			&i.Mode,
+			&i.Model,
+			&i.Profile,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserMode = `-- name: SetUserMode :one
+UPDATE data.users
+SET mode = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+`
+
+type SetUserModeParams struct {
+	ID   int64       `json:"id"`
+	Mode pgtype.Text `json:"mode"`
+}
+
+// SetUserMode
+//
+//	UPDATE data.users
+//	SET mode = $2,
+//	    updated_at = NOW()
+//	WHERE id = $1
+//	RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+func (q *Queries) SetUserMode(ctx context.Context, arg SetUserModeParams) (*DataUser, error) {
+	row := q.db.QueryRow(ctx, setUserMode, arg.ID, arg.Mode)
+	var i DataUser
+	err := row.Scan(
+		&i.ID,
+		&i.Uuid,
+		&i.TelegramID,
+		&i.Username,
+		&i.FirstName,
+		&i.LastName,
+		&i.LanguageCode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.Mode,
+		&i.Model,
+		&i.Profile,
+	)
+	return &i, err
+}
+
+const setUserModel = `-- name: SetUserModel :one
+UPDATE data.users
+SET model = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+`
+
+type SetUserModelParams struct {
+	ID    int64       `json:"id"`
+	Model pgtype.Text `json:"model"`
+}
+
+// SetUserModel
+//
+//	UPDATE data.users
+//	SET model = $2,
+//	    updated_at = NOW()
+//	WHERE id = $1
+//	RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+func (q *Queries) SetUserModel(ctx context.Context, arg SetUserModelParams) (*DataUser, error) {
+	row := q.db.QueryRow(ctx, setUserModel, arg.ID, arg.Model)
+	var i DataUser
+	err := row.Scan(
+		&i.ID,
+		&i.Uuid,
+		&i.TelegramID,
+		&i.Username,
+		&i.FirstName,
+		&i.LastName,
+		&i.LanguageCode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.Mode,
+		&i.Model,
+		&i.Profile,
+	)
+	return &i, err
+}
+
+const setUserProfile = `-- name: SetUserProfile :one
+UPDATE data.users
+SET profile = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+`
+
+type SetUserProfileParams struct {
+	ID      int64       `json:"id"`
+	Profile pgtype.Text `json:"profile"`
+}
+
+// SetUserProfile
+//
+//	UPDATE data.users
+//	SET profile = $2,
+//	    updated_at = NOW()
+//	WHERE id = $1
+//	RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+func (q *Queries) SetUserProfile(ctx context.Context, arg SetUserProfileParams) (*DataUser, error) {
+	row := q.db.QueryRow(ctx, setUserProfile, arg.ID, arg.Profile)
+	var i DataUser
+	err := row.Scan(
+		&i.ID,
+		&i.Uuid,
+		&i.TelegramID,
+		&i.Username,
+		&i.FirstName,
+		&i.LastName,
+		&i.LanguageCode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.Mode,
+		&i.Model,
+		&i.Profile,
+	)
+	return &i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE data.users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+`
+
+type SetUserRoleParams struct {
+	ID   int64  `json:"id"`
+	Role string `json:"role"`
+}
+
+// SetUserRole
+//
+//	UPDATE data.users
+//	SET role = $2,
+//	    updated_at = NOW()
+//	WHERE id = $1
+//	RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (*DataUser, error) {
+	row := q.db.QueryRow(ctx, setUserRole, arg.ID, arg.Role)
+	var i DataUser
+	err := row.Scan(
+		&i.ID,
+		&i.Uuid,
+		&i.TelegramID,
+		&i.Username,
+		&i.FirstName,
+		&i.LastName,
+		&i.LanguageCode,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.Mode,
+		&i.Model,
+		&i.Profile,
 	)
 	return &i, err
 }
 
 const upsertUser = `-- name: UpsertUser :one
-INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code, role)
+VALUES ($1, $2, $3, $4, $5, $6)
 ON CONFLICT (telegram_id) DO UPDATE
 SET username = EXCLUDED.username,
     first_name = EXCLUDED.first_name,
     last_name = EXCLUDED.last_name,
     language_code = EXCLUDED.language_code,
     updated_at = NOW()
-RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at
+RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
 `
 
 type UpsertUserParams struct {
@@ -95,19 +376,20 @@ type UpsertUserParams struct {
 	FirstName    pgtype.Text `json:"first_name"`
 	LastName     pgtype.Text `json:"last_name"`
 	LanguageCode pgtype.Text `json:"language_code"`
+	Role         string      `json:"role"`
 }
 
 // UpsertUser
 //
-//	INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code)
-//	VALUES ($1, $2, $3, $4, $5)
+//	INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code, role)
+//	VALUES ($1, $2, $3, $4, $5, $6)
 //	ON CONFLICT (telegram_id) DO UPDATE
 //	SET username = EXCLUDED.username,
 //	    first_name = EXCLUDED.first_name,
 //	    last_name = EXCLUDED.last_name,
 //	    language_code = EXCLUDED.language_code,
 //	    updated_at = NOW()
-//	RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at
+//	RETURNING id, uuid, telegram_id, username, first_name, last_name, language_code, created_at, updated_at, role, mode, model, profile
 func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (*DataUser, error) {
 	row := q.db.QueryRow(ctx, upsertUser,
 		arg.TelegramID,
@@ -115,6 +397,7 @@ func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (*DataUs
 		arg.FirstName,
 		arg.LastName,
 		arg.LanguageCode,
+		arg.Role,
 	)
 	var i DataUser
 	err := row.Scan(
@@ -127,6 +410,10 @@ func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (*DataUs
 		&i.LanguageCode,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.Mode,
+		&i.Model,
+		&i.Profile,
 	)
 	return &i, err
 }
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE data.agent_steps (
+    id BIGSERIAL PRIMARY KEY,
+    uuid TEXT NOT NULL DEFAULT utils.nanoid(8) UNIQUE,
+    session_id BIGINT NOT NULL REFERENCES data.sessions(id) ON DELETE CASCADE,
+    message_id BIGINT REFERENCES data.messages(id) ON DELETE SET NULL,
+    llm_request_id BIGINT REFERENCES data.llm_requests(id) ON DELETE SET NULL,
+    step_number INT NOT NULL,
+    response TEXT NOT NULL,
+    command TEXT,
+    stdout TEXT NOT NULL DEFAULT '',
+    stderr TEXT NOT NULL DEFAULT '',
+    exit_code INT,
+    timed_out BOOLEAN NOT NULL DEFAULT FALSE,
+    error_message TEXT,
+    input_tokens INT NOT NULL,
+    output_tokens INT NOT NULL,
+    total_tokens INT NOT NULL,
+    duration_ms INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_agent_steps_session_id ON data.agent_steps(session_id);
+CREATE INDEX idx_agent_steps_message_id ON data.agent_steps(message_id);
+CREATE INDEX idx_agent_steps_llm_request_id ON data.agent_steps(llm_request_id);
+CREATE INDEX idx_agent_steps_uuid ON data.agent_steps(uuid);
+
+-- +goose Down
+DROP TABLE IF EXISTS data.agent_steps;
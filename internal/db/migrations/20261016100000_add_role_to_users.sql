@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE data.users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+CHECK (role IN ('blocked', 'user', 'agent', 'admin'));
+
+-- +goose Down
+ALTER TABLE data.users DROP COLUMN role;
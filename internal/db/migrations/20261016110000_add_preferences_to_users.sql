@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE data.users
+ADD COLUMN mode TEXT CHECK (mode IN ('simple', 'agent')),
+ADD COLUMN model TEXT;
+
+-- +goose Down
+ALTER TABLE data.users
+DROP COLUMN model,
+DROP COLUMN mode;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE data.llm_requests
+ADD COLUMN cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE data.llm_requests DROP COLUMN cost_usd;
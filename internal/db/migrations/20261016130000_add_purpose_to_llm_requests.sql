@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE data.llm_requests
+ADD COLUMN purpose TEXT NOT NULL DEFAULT 'main';
+
+-- +goose Down
+ALTER TABLE data.llm_requests DROP COLUMN purpose;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE data.sessions
+ADD COLUMN summary TEXT;
+
+-- +goose Down
+ALTER TABLE data.sessions DROP COLUMN summary;
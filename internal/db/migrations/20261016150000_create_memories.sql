@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE data.memories (
+    id BIGSERIAL PRIMARY KEY,
+    uuid TEXT NOT NULL DEFAULT utils.nanoid(8) UNIQUE,
+    user_id BIGINT NOT NULL REFERENCES data.users(id) ON DELETE CASCADE,
+    message_id BIGINT REFERENCES data.messages(id) ON DELETE SET NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, content)
+);
+
+CREATE INDEX idx_memories_user_id ON data.memories(user_id);
+CREATE INDEX idx_memories_search ON data.memories USING GIN (to_tsvector('english', content));
+CREATE INDEX idx_memories_uuid ON data.memories(uuid);
+
+-- +goose Down
+DROP TABLE IF EXISTS data.memories;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE data.context_chunks (
+    id BIGSERIAL PRIMARY KEY,
+    uuid TEXT NOT NULL DEFAULT utils.nanoid(8) UNIQUE,
+    path TEXT NOT NULL,
+    chunk_index INT NOT NULL,
+    heading TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    tokens INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (path, chunk_index)
+);
+
+CREATE INDEX idx_context_chunks_search ON data.context_chunks USING GIN (to_tsvector('english', heading || ' ' || content));
+CREATE INDEX idx_context_chunks_uuid ON data.context_chunks(uuid);
+
+-- +goose Down
+DROP TABLE IF EXISTS data.context_chunks;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE data.sessions
+ADD COLUMN agent_prompt TEXT;
+
+-- +goose Down
+ALTER TABLE data.sessions DROP COLUMN agent_prompt;
@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE data.users
+ADD COLUMN profile TEXT;
+
+ALTER TABLE data.sessions
+ADD COLUMN profile TEXT;
+
+-- Chunks are indexed per context directory; the index is rebuilt on startup
+DELETE FROM data.context_chunks;
+
+ALTER TABLE data.context_chunks
+ADD COLUMN dir TEXT NOT NULL,
+DROP CONSTRAINT context_chunks_path_chunk_index_key,
+ADD CONSTRAINT context_chunks_dir_path_chunk_index_key UNIQUE (dir, path, chunk_index);
+
+-- +goose Down
+DELETE FROM data.context_chunks;
+
+ALTER TABLE data.context_chunks
+DROP CONSTRAINT context_chunks_dir_path_chunk_index_key,
+DROP COLUMN dir,
+ADD CONSTRAINT context_chunks_path_chunk_index_key UNIQUE (path, chunk_index);
+
+ALTER TABLE data.sessions DROP COLUMN profile;
+
+ALTER TABLE data.users DROP COLUMN profile;
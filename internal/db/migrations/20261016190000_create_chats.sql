@@ -0,0 +1,53 @@
+-- +goose Up
+CREATE TABLE data.chats (
+    id BIGSERIAL PRIMARY KEY,
+    uuid TEXT NOT NULL DEFAULT utils.nanoid(8) UNIQUE,
+    telegram_id BIGINT NOT NULL UNIQUE,
+    type TEXT NOT NULL CHECK (type IN ('private', 'group', 'supergroup', 'channel')),
+    title TEXT,
+    profile TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_chats_uuid ON data.chats(uuid);
+
+-- Sessions so far all belong to private chats, whose ID is the user's Telegram ID
+INSERT INTO data.chats (telegram_id, type, title)
+SELECT u.telegram_id, 'private', u.first_name
+FROM data.users u
+WHERE EXISTS (SELECT 1 FROM data.sessions s WHERE s.user_id = u.id);
+
+-- thread_id is the forum topic, 0 outside of topics
+ALTER TABLE data.sessions
+ADD COLUMN chat_id BIGINT REFERENCES data.chats(id) ON DELETE CASCADE,
+ADD COLUMN thread_id BIGINT NOT NULL DEFAULT 0;
+
+UPDATE data.sessions s
+SET chat_id = c.id
+FROM data.users u
+JOIN data.chats c ON c.telegram_id = u.telegram_id
+WHERE s.user_id = u.id;
+
+ALTER TABLE data.sessions ALTER COLUMN chat_id SET NOT NULL;
+
+DROP INDEX data.idx_sessions_active;
+CREATE INDEX idx_sessions_chat_id ON data.sessions(chat_id);
+CREATE INDEX idx_sessions_active ON data.sessions(chat_id, thread_id) WHERE ended_at IS NULL;
+
+-- Who sent a user message in a group chat
+ALTER TABLE data.messages
+ADD COLUMN speaker TEXT;
+
+-- +goose Down
+ALTER TABLE data.messages DROP COLUMN speaker;
+
+DROP INDEX data.idx_sessions_active;
+DROP INDEX data.idx_sessions_chat_id;
+CREATE INDEX idx_sessions_active ON data.sessions(user_id) WHERE ended_at IS NULL;
+
+ALTER TABLE data.sessions
+DROP COLUMN thread_id,
+DROP COLUMN chat_id;
+
+DROP TABLE IF EXISTS data.chats;
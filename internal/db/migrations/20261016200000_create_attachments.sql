@@ -0,0 +1,21 @@
+-- +goose Up
+-- Files sent with messages. Only metadata is stored; the file itself is
+-- downloaded from Telegram again when the message is replayed.
+CREATE TABLE data.attachments (
+    id BIGSERIAL PRIMARY KEY,
+    uuid TEXT NOT NULL DEFAULT utils.nanoid(8) UNIQUE,
+    message_id BIGINT NOT NULL REFERENCES data.messages(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('image')),
+    telegram_file_id TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    size_bytes INT NOT NULL,
+    width INT,
+    height INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_attachments_message_id ON data.attachments(message_id);
+CREATE INDEX idx_attachments_uuid ON data.attachments(uuid);
+
+-- +goose Down
+DROP TABLE IF EXISTS data.attachments;
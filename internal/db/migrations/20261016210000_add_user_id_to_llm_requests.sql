@@ -0,0 +1,17 @@
+-- +goose Up
+-- The user a request was made for. Group chats share a session between
+-- their members, so the session's user isn't always the one who asked.
+ALTER TABLE data.llm_requests
+ADD COLUMN user_id BIGINT REFERENCES data.users(id) ON DELETE CASCADE;
+
+UPDATE data.llm_requests lr
+SET user_id = s.user_id
+FROM data.sessions s
+WHERE lr.session_id = s.id;
+
+ALTER TABLE data.llm_requests ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX idx_llm_requests_user_id ON data.llm_requests(user_id, created_at);
+
+-- +goose Down
+ALTER TABLE data.llm_requests DROP COLUMN user_id;
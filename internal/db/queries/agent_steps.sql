@@ -0,0 +1,18 @@
+-- name: CreateAgentStep :one
+INSERT INTO data.agent_steps (
+    session_id, message_id, llm_request_id, step_number, response, command,
+    stdout, stderr, exit_code, timed_out, error_message,
+    input_tokens, output_tokens, total_tokens, duration_ms
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+RETURNING id;
+
+-- name: GetMessageAgentSteps :many
+SELECT * FROM data.agent_steps
+WHERE message_id = $1
+ORDER BY step_number ASC;
+
+-- name: GetSessionAgentSteps :many
+SELECT * FROM data.agent_steps
+WHERE session_id = $1
+ORDER BY created_at ASC, step_number ASC;
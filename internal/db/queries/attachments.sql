@@ -0,0 +1,9 @@
+-- name: CreateAttachment :one
+INSERT INTO data.attachments (message_id, type, telegram_file_id, mime_type, size_bytes, width, height)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetSessionAttachments :many
+SELECT * FROM data.attachments
+WHERE message_id IN (SELECT id FROM data.messages WHERE session_id = $1)
+ORDER BY message_id, id;
@@ -0,0 +1,15 @@
+-- name: UpsertChat :one
+INSERT INTO data.chats (telegram_id, type, title)
+VALUES ($1, $2, $3)
+ON CONFLICT (telegram_id) DO UPDATE
+SET type = EXCLUDED.type,
+    title = EXCLUDED.title,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: SetChatProfile :one
+UPDATE data.chats
+SET profile = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
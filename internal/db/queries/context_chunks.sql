@@ -0,0 +1,13 @@
+-- name: CreateContextChunk :exec
+INSERT INTO data.context_chunks (dir, path, chunk_index, heading, content, tokens)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: DeleteContextChunks :execrows
+DELETE FROM data.context_chunks;
+
+-- name: SearchContextChunks :many
+SELECT * FROM data.context_chunks
+WHERE dir = sqlc.arg(dir)
+  AND to_tsvector('english', heading || ' ' || content) @@ replace(plainto_tsquery('english', sqlc.arg(query)::TEXT)::TEXT, ' & ', ' | ')::TSQUERY
+ORDER BY ts_rank(to_tsvector('english', heading || ' ' || content), replace(plainto_tsquery('english', sqlc.arg(query)::TEXT)::TEXT, ' & ', ' | ')::TSQUERY) DESC, path, chunk_index
+LIMIT sqlc.arg(max_results);
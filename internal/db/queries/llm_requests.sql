@@ -1,6 +1,6 @@
 -- name: CreateLLMRequest :one
-INSERT INTO data.llm_requests (session_id, message_id, input_tokens, output_tokens, total_tokens, model)
-VALUES ($1, $2, $3, $4, $5, $6)
+INSERT INTO data.llm_requests (session_id, message_id, input_tokens, output_tokens, total_tokens, model, cost_usd, purpose, user_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING *;
 
 -- name: GetSessionLLMRequests :many
@@ -8,21 +8,69 @@ SELECT * FROM data.llm_requests
 WHERE session_id = $1
 ORDER BY created_at ASC;
 
--- name: GetSessionTokenUsage :one
+-- name: GetSessionUserTokenUsage :one
 SELECT
     COALESCE(SUM(input_tokens), 0)::INT AS total_input_tokens,
     COALESCE(SUM(output_tokens), 0)::INT AS total_output_tokens,
     COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
     COUNT(*)::INT AS request_count
 FROM data.llm_requests
-WHERE session_id = $1;
+WHERE session_id = $1 AND user_id = $2;
 
 -- name: GetUserTokenUsage :one
 SELECT
     COALESCE(SUM(lr.input_tokens), 0)::INT AS total_input_tokens,
     COALESCE(SUM(lr.output_tokens), 0)::INT AS total_output_tokens,
     COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd,
     COUNT(*)::INT AS request_count
 FROM data.llm_requests lr
-JOIN data.sessions s ON lr.session_id = s.id
-WHERE s.user_id = $1;
+WHERE lr.user_id = $1;
+
+-- name: ListUserTokenUsage :many
+SELECT
+    u.id AS user_id,
+    u.telegram_id,
+    u.username,
+    COALESCE(SUM(lr.input_tokens), 0)::INT AS total_input_tokens,
+    COALESCE(SUM(lr.output_tokens), 0)::INT AS total_output_tokens,
+    COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd,
+    COUNT(*)::INT AS request_count
+FROM data.llm_requests lr
+JOIN data.users u ON lr.user_id = u.id
+GROUP BY u.id
+ORDER BY total_cost_usd DESC, total_tokens DESC
+LIMIT $1;
+
+-- name: GetUserUsageSince :one
+SELECT
+    COALESCE(SUM(lr.total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(lr.cost_usd), 0)::FLOAT8 AS total_cost_usd
+FROM data.llm_requests lr
+WHERE lr.user_id = $1 AND lr.created_at >= $2;
+
+-- name: ListModelUsage :many
+SELECT
+    model,
+    COALESCE(SUM(input_tokens), 0)::INT AS total_input_tokens,
+    COALESCE(SUM(output_tokens), 0)::INT AS total_output_tokens,
+    COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
+    COUNT(*)::INT AS request_count
+FROM data.llm_requests
+WHERE created_at >= $1
+GROUP BY model
+ORDER BY total_cost_usd DESC, total_tokens DESC;
+
+-- name: ListDailyUsage :many
+SELECT
+    (created_at AT TIME ZONE 'UTC')::DATE AS day,
+    COALESCE(SUM(total_tokens), 0)::INT AS total_tokens,
+    COALESCE(SUM(cost_usd), 0)::FLOAT8 AS total_cost_usd,
+    COUNT(*)::INT AS request_count
+FROM data.llm_requests
+WHERE created_at >= $1
+GROUP BY day
+ORDER BY day DESC;
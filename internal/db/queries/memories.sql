@@ -0,0 +1,22 @@
+-- name: CreateMemory :execrows
+INSERT INTO data.memories (user_id, message_id, content)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, content) DO NOTHING;
+
+-- name: ListUserMemories :many
+SELECT * FROM data.memories
+WHERE user_id = $1
+ORDER BY created_at, id;
+
+-- name: SearchUserMemories :many
+SELECT * FROM data.memories
+WHERE user_id = sqlc.arg(user_id)
+  AND to_tsvector('english', content) @@ replace(plainto_tsquery('english', sqlc.arg(query)::TEXT)::TEXT, ' & ', ' | ')::TSQUERY
+ORDER BY ts_rank(to_tsvector('english', content), replace(plainto_tsquery('english', sqlc.arg(query)::TEXT)::TEXT, ' & ', ' | ')::TSQUERY) DESC, created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: DeleteUserMemory :execrows
+DELETE FROM data.memories WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteUserMemories :execrows
+DELETE FROM data.memories WHERE user_id = $1;
@@ -1,10 +1,10 @@
 -- name: AddMessage :one
-INSERT INTO data.messages (session_id, role, content)
-VALUES ($1, $2, $3)
+INSERT INTO data.messages (session_id, role, content, speaker)
+VALUES ($1, $2, $3, $4)
 RETURNING id;
 
 -- name: GetSessionMessages :many
-SELECT id, uuid, session_id, role, content, created_at
+SELECT id, uuid, session_id, role, content, created_at, speaker
 FROM data.messages
 WHERE session_id = $1
 ORDER BY created_at ASC;
@@ -1,12 +1,12 @@
 -- name: GetActiveSession :one
 SELECT * FROM data.sessions
-WHERE user_id = $1 AND ended_at IS NULL
+WHERE chat_id = $1 AND thread_id = $2 AND ended_at IS NULL
 ORDER BY created_at DESC
 LIMIT 1;
 
 -- name: CreateSession :one
-INSERT INTO data.sessions (user_id, system_prompt)
-VALUES ($1, $2)
+INSERT INTO data.sessions (user_id, chat_id, thread_id, system_prompt, agent_prompt, profile)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: EndSession :exec
@@ -17,3 +17,6 @@ SELECT * FROM data.sessions
 WHERE user_id = $1
 ORDER BY created_at DESC
 LIMIT $2;
+
+-- name: EndSessionWithSummary :exec
+UPDATE data.sessions SET ended_at = NOW(), summary = $2 WHERE id = $1;
@@ -7,8 +7,8 @@ VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
 -- name: UpsertUser :one
-INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO data.users (telegram_id, username, first_name, last_name, language_code, role)
+VALUES ($1, $2, $3, $4, $5, $6)
 ON CONFLICT (telegram_id) DO UPDATE
 SET username = EXCLUDED.username,
     first_name = EXCLUDED.first_name,
@@ -16,3 +16,44 @@ SET username = EXCLUDED.username,
     language_code = EXCLUDED.language_code,
     updated_at = NOW()
 RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE data.users
+SET role = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByUsername :one
+SELECT * FROM data.users WHERE LOWER(username) = LOWER(sqlc.arg(username));
+
+-- name: ListUsers :many
+SELECT * FROM data.users
+ORDER BY id ASC
+LIMIT $1 OFFSET $2;
+
+-- name: ListBroadcastRecipients :many
+SELECT telegram_id FROM data.users
+WHERE role <> 'blocked'
+ORDER BY id ASC;
+
+-- name: SetUserMode :one
+UPDATE data.users
+SET mode = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserModel :one
+UPDATE data.users
+SET model = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserProfile :one
+UPDATE data.users
+SET profile = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;